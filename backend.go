@@ -0,0 +1,598 @@
+package q
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+
+	"github.com/itsubaki/q/internal/index"
+	"github.com/itsubaki/q/internal/trajectory"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/quantum/density"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/mps"
+	"github.com/itsubaki/q/quantum/qubit"
+	"github.com/itsubaki/q/quantum/sparse"
+	"github.com/itsubaki/q/quantum/stabilizer"
+)
+
+// Backend is a simulator of the quantum state that Q operates on.
+// The qubits are indexed from zero in the order of allocation,
+// and the 0-th qubit is the most significant bit of the basis index.
+type Backend interface {
+	// NumQubits returns the number of qubits.
+	NumQubits() int
+
+	// Add appends a qubit in the state v, and returns its index.
+	// v is a vector of length 2**k, and appends k qubits at once.
+	Add(v ...complex128) int
+
+	// Apply applies the (2**k x 2**k) unitary u to the k target qubits
+	// if the control qubits are in the given state.
+	// state is a binary string such as "10", and state[i] is the state of control[i].
+	Apply(u *matrix.Matrix, control []int, state string, target ...int)
+
+	// ApplyKraus applies the channel of the Kraus operators to the target qubits.
+	// The density matrix applies the channel exactly, and the state vectors apply one of
+	// the operators chosen with its probability as a quantum trajectory.
+	// The trajectory panics with qubit.ErrZeroProbability if no operator has nonzero probability.
+	ApplyKraus(kraus []*matrix.Matrix, target ...int)
+
+	// Swap swaps the states of the i-th and j-th qubits.
+	Swap(i, j int)
+
+	// Permute maps the basis state |k> of the target qubits to |f(k)>
+	// if all control qubits are |1>.
+	// f must be a bijection on [0, 2**len(target)), otherwise it panics with qubit.ErrNotBijection.
+	Permute(f func(k int) int, control []int, target ...int)
+
+	// Measure measures the qubit, and returns the measured state.
+	Measure(index int) *qubit.Qubit
+
+	// Reset sets the qubit to the zero state.
+	Reset(index int)
+
+	// Amplitude returns the amplitudes of all basis states.
+	Amplitude() []complex128
+
+	// Probability returns the probabilities of all basis states.
+	Probability() []float64
+
+	// Marginal returns the marginal probability distribution of the qubits at the index.
+	Marginal(index ...int) []float64
+
+	// State returns the states of the qubits grouped by the index.
+	State(index ...[]int) []qubit.State
+
+	// Clone returns a clone of the backend.
+	Clone() Backend
+
+	// String returns the string representation of the backend.
+	String() string
+}
+
+// WithBackend sets the backend of the quantum state.
+// The qubits are appended to b, and the measurements use the random number generator of b.
+func WithBackend(b Backend) Option {
+	return func(q *Q) {
+		q.b = b
+	}
+}
+
+// WithMPS sets the matrix product state as the state of the qubits.
+// The qubits are appended to m, and the measurements use m.Rand.
+// m.MaxBond limits the bond dimension, and m.TruncationError reports the discarded weight.
+func WithMPS(m *mps.MPS) Option {
+	return WithBackend(&matrixProduct{m: m})
+}
+
+// WithSparse sets the sparse state vector as the state of the qubits.
+// It stores only the nonzero amplitudes, and is efficient for permutation and diagonal gates.
+func WithSparse() Option {
+	return func(q *Q) {
+		q.alloc = newSparse
+	}
+}
+
+// WithDensity sets the density matrix as the state of the qubits.
+// It simulates mixed states, and Amplitude returns nil since a mixed state has no amplitudes.
+// The reset traces out the qubit and prepares |0>, so it does not depend on Rand.
+func WithDensity() Option {
+	return func(q *Q) {
+		q.alloc = newDensity
+	}
+}
+
+// WithStabilizer sets the stabilizer tableau as the state of the qubits.
+// It simulates Clifford circuits of thousands of qubits, and the qubits are allocated in
+// the computational basis states or the single-qubit states |+>, |->, |+i> and |-i>.
+// The gates that are not Clifford, such as T and RX, panic with stabilizer.ErrNotClifford,
+// and so do the channels other than mixtures of Clifford gates, such as the amplitude damping.
+// Amplitude and Probability are exponential in the number of qubits, and the global phase is not kept.
+func WithStabilizer() Option {
+	return func(q *Q) {
+		q.alloc = newStabilizer
+	}
+}
+
+// newStateVector returns the state vector using the random number generator and the workers of q.
+func newStateVector(q *Q) Backend {
+	return &statevector{
+		rand:    q.Rand,
+		workers: q.workers,
+	}
+}
+
+// newSparse returns the sparse state vector using the random number generator of q.
+func newSparse(q *Q) Backend {
+	qb := sparse.New()
+	qb.Rand = q.Rand
+	return &sparseVector{qb: qb}
+}
+
+// newDensity returns the density matrix using the random number generator of q.
+func newDensity(q *Q) Backend {
+	return &densityMatrix{rand: q.Rand}
+}
+
+// newStabilizer returns the stabilizer tableau using the random number generator of q.
+func newStabilizer(q *Q) Backend {
+	t := stabilizer.Zero(0)
+	t.Rand = q.Rand
+	return &stabilizerTableau{t: t}
+}
+
+// statevector is the backend of the state vector.
+type statevector struct {
+	qb      *qubit.Qubit
+	rand    func() float64
+	workers int
+}
+
+func (s *statevector) NumQubits() int {
+	if s.qb == nil {
+		return 0
+	}
+
+	return s.qb.NumQubits()
+}
+
+func (s *statevector) Add(v ...complex128) int {
+	if s.qb == nil {
+		s.qb = qubit.New(v...)
+		s.qb.Rand = s.rand
+		s.qb.Workers = s.workers
+		return 0
+	}
+
+	s.qb.TensorProduct(qubit.New(v...))
+	return s.qb.NumQubits() - 1
+}
+
+func (s *statevector) Apply(u *matrix.Matrix, control []int, state string, target ...int) {
+	s.qb.ControlledState(u, control, state, target...)
+}
+
+func (s *statevector) ApplyKraus(kraus []*matrix.Matrix, target ...int) {
+	s.qb.ApplyKraus(kraus, target...)
+}
+
+func (s *statevector) Swap(i, j int) {
+	s.qb.Swap(i, j)
+}
+
+func (s *statevector) Permute(f func(k int) int, control []int, target ...int) {
+	s.qb.Permute(f, control, target...)
+}
+
+func (s *statevector) Measure(index int) *qubit.Qubit {
+	return s.qb.Measure(index)
+}
+
+func (s *statevector) Reset(index int) {
+	if s.qb.Measure(index).IsOne() {
+		s.qb.ApplyOn(gate.X(), index)
+	}
+}
+
+func (s *statevector) Amplitude() []complex128 {
+	return s.qb.Amplitude()
+}
+
+func (s *statevector) Probability() []float64 {
+	return s.qb.Probability()
+}
+
+func (s *statevector) Marginal(index ...int) []float64 {
+	return s.qb.Marginal(index...)
+}
+
+func (s *statevector) State(index ...[]int) []qubit.State {
+	return s.qb.State(index...)
+}
+
+func (s *statevector) Clone() Backend {
+	if s.qb == nil {
+		return &statevector{rand: s.rand, workers: s.workers}
+	}
+
+	return &statevector{qb: s.qb.Clone(), rand: s.rand, workers: s.workers}
+}
+
+func (s *statevector) String() string {
+	return s.qb.String()
+}
+
+// sparseVector is the backend of the sparse state vector.
+type sparseVector struct {
+	qb *sparse.Qubit
+}
+
+func (s *sparseVector) NumQubits() int {
+	return s.qb.NumQubits()
+}
+
+func (s *sparseVector) Add(v ...complex128) int {
+	s.qb.TensorProduct(sparse.New(v...))
+	return s.qb.NumQubits() - 1
+}
+
+func (s *sparseVector) Apply(u *matrix.Matrix, control []int, state string, target ...int) {
+	s.qb.ControlledState(u, control, state, target...)
+}
+
+func (s *sparseVector) ApplyKraus(kraus []*matrix.Matrix, target ...int) {
+	s.qb.ApplyKraus(kraus, target...)
+}
+
+func (s *sparseVector) Swap(i, j int) {
+	s.qb.Swap(i, j)
+}
+
+func (s *sparseVector) Permute(f func(k int) int, control []int, target ...int) {
+	s.qb.Permute(f, control, target...)
+}
+
+func (s *sparseVector) Measure(index int) *qubit.Qubit {
+	return s.qb.Measure(index)
+}
+
+func (s *sparseVector) Reset(index int) {
+	if s.qb.Measure(index).IsOne() {
+		s.qb.ApplyOn(gate.X(), index)
+	}
+}
+
+func (s *sparseVector) Amplitude() []complex128 {
+	return s.qb.Amplitude()
+}
+
+func (s *sparseVector) Probability() []float64 {
+	return s.qb.Probability()
+}
+
+func (s *sparseVector) Marginal(index ...int) []float64 {
+	return s.qb.Marginal(index...)
+}
+
+func (s *sparseVector) State(index ...[]int) []qubit.State {
+	return s.qb.State(index...)
+}
+
+func (s *sparseVector) Clone() Backend {
+	return &sparseVector{qb: s.qb.Clone()}
+}
+
+func (s *sparseVector) String() string {
+	return s.qb.String()
+}
+
+// matrixProduct is the backend of the matrix product state.
+type matrixProduct struct {
+	m *mps.MPS
+}
+
+func (p *matrixProduct) NumQubits() int {
+	return p.m.NumQubits()
+}
+
+func (p *matrixProduct) Add(v ...complex128) int {
+	p.m.TensorProduct(mps.New(v...))
+	return p.m.NumQubits() - 1
+}
+
+func (p *matrixProduct) Apply(u *matrix.Matrix, control []int, state string, target ...int) {
+	p.m.ControlledState(u, control, state, target...)
+}
+
+func (p *matrixProduct) ApplyKraus(kraus []*matrix.Matrix, target ...int) {
+	p.m.ApplyKraus(kraus, target...)
+}
+
+func (p *matrixProduct) Swap(i, j int) {
+	p.m.Swap(i, j)
+}
+
+func (p *matrixProduct) Permute(f func(k int) int, control []int, target ...int) {
+	p.m.Permute(f, control, target...)
+}
+
+func (p *matrixProduct) Measure(index int) *qubit.Qubit {
+	return p.m.Measure(index)
+}
+
+func (p *matrixProduct) Reset(index int) {
+	if p.m.Measure(index).IsOne() {
+		p.m.ApplyOn(gate.X(), index)
+	}
+}
+
+func (p *matrixProduct) Amplitude() []complex128 {
+	return p.m.Vector()
+}
+
+func (p *matrixProduct) Probability() []float64 {
+	amp := p.m.Vector()
+
+	prob := make([]float64, len(amp))
+	for i, a := range amp {
+		prob[i] = math.Pow(cmplx.Abs(a), 2)
+	}
+
+	return prob
+}
+
+func (p *matrixProduct) Marginal(index ...int) []float64 {
+	return p.m.Marginal(index...)
+}
+
+func (p *matrixProduct) State(index ...[]int) []qubit.State {
+	return qubit.New(p.m.Vector()...).State(index...)
+}
+
+func (p *matrixProduct) Clone() Backend {
+	return &matrixProduct{m: p.m.Clone()}
+}
+
+func (p *matrixProduct) String() string {
+	return p.m.String()
+}
+
+// stabilizerTableau is the backend of the stabilizer tableau.
+type stabilizerTableau struct {
+	t *stabilizer.Tableau
+}
+
+func (s *stabilizerTableau) NumQubits() int {
+	return s.t.NumQubits()
+}
+
+func (s *stabilizerTableau) Add(v ...complex128) int {
+	s.t.TensorProduct(stabilizer.New(v...))
+	return s.t.NumQubits() - 1
+}
+
+// Apply applies the gates of the tableau for X, Y, Z, H, S and the controlled X and Z,
+// and the conjugation of the Pauli operators by the controlled u for the others.
+func (s *stabilizerTableau) Apply(u *matrix.Matrix, control []int, state string, target ...int) {
+	if len(control) == 0 && len(target) == 1 {
+		for _, g := range []struct {
+			u     *matrix.Matrix
+			apply func(index ...int) *stabilizer.Tableau
+		}{
+			{gate.I(), func(index ...int) *stabilizer.Tableau { return s.t }},
+			{gate.X(), s.t.X},
+			{gate.Y(), s.t.Y},
+			{gate.Z(), s.t.Z},
+			{gate.H(), s.t.H},
+			{gate.S(), s.t.S},
+		} {
+			if u.Equals(g.u) {
+				g.apply(target...)
+				return
+			}
+		}
+	}
+
+	if len(control) == 1 && len(target) == 1 && (u.Equals(gate.X()) || u.Equals(gate.Z())) {
+		// negctrl is X on the control qubit before and after the gate
+		if state == "0" {
+			s.t.X(control[0])
+			defer s.t.X(control[0])
+		}
+
+		if u.Equals(gate.X()) {
+			s.t.CNOT(control[0], target[0])
+			return
+		}
+
+		s.t.CZ(control[0], target[0])
+		return
+	}
+
+	nc, nt := len(control), len(target)
+	c, t := make([]int, nc), make([]int, nt)
+	for i := range nc {
+		c[i] = i
+	}
+
+	for i := range nt {
+		t[i] = nc + i
+	}
+
+	g := gate.ControlledState(u, nc+nt, c, state, t...)
+	if err := s.t.ApplyOn(g, append(append([]int{}, control...), target...)...); err != nil {
+		panic(err)
+	}
+}
+
+// ApplyKraus applies one of the Kraus operators K = sqrt(p) * u chosen with the probability p,
+// where u is a Clifford gate. The probability does not depend on the state since K^dagger * K = p * I,
+// so it is given by the maximally mixed state instead of the reduced density matrix.
+func (s *stabilizerTableau) ApplyKraus(kraus []*matrix.Matrix, target ...int) {
+	d := 1 << len(target)
+	for _, m := range kraus {
+		p := real(m.Dagger().MatMul(m).Trace()) / float64(d)
+		if !m.Dagger().MatMul(m).Equals(matrix.Identity(d).Mul(complex(p, 0))) {
+			panic(fmt.Errorf("%w: kraus operator %v is not proportional to a unitary", stabilizer.ErrNotClifford, m))
+		}
+	}
+
+	k, p, err := trajectory.Choose(kraus, matrix.Identity(d).Mul(complex(1/float64(d), 0)), s.t.Rand())
+	if err != nil {
+		panic(err)
+	}
+
+	s.Apply(kraus[k].Mul(complex(1/math.Sqrt(p), 0)), nil, "", target...)
+}
+
+func (s *stabilizerTableau) Swap(i, j int) {
+	s.t.Swap(i, j)
+}
+
+func (s *stabilizerTableau) Permute(f func(k int) int, control []int, target ...int) {
+	if err := index.Bijection(f, len(target)); err != nil {
+		panic(err)
+	}
+
+	d := 1 << len(target)
+	u := matrix.Zero(d, d)
+	for k := range d {
+		u.Set(f(k), k, 1)
+	}
+
+	s.Apply(u, control, strings.Repeat("1", len(control)), target...)
+}
+
+func (s *stabilizerTableau) Measure(index int) *qubit.Qubit {
+	return s.t.Measure(index)
+}
+
+func (s *stabilizerTableau) Reset(index int) {
+	s.t.Reset(index)
+}
+
+func (s *stabilizerTableau) Amplitude() []complex128 {
+	return s.t.Vector()
+}
+
+func (s *stabilizerTableau) Probability() []float64 {
+	amp := s.t.Vector()
+
+	prob := make([]float64, len(amp))
+	for i, a := range amp {
+		prob[i] = math.Pow(cmplx.Abs(a), 2)
+	}
+
+	return prob
+}
+
+func (s *stabilizerTableau) Marginal(index ...int) []float64 {
+	return s.t.Marginal(index...)
+}
+
+func (s *stabilizerTableau) State(index ...[]int) []qubit.State {
+	return qubit.New(s.t.Vector()...).State(index...)
+}
+
+func (s *stabilizerTableau) Clone() Backend {
+	return &stabilizerTableau{t: s.t.Clone()}
+}
+
+func (s *stabilizerTableau) String() string {
+	return s.t.String()
+}
+
+// densityMatrix is the backend of the density matrix.
+type densityMatrix struct {
+	m    *density.Matrix
+	rand func() float64
+}
+
+func (d *densityMatrix) NumQubits() int {
+	if d.m == nil {
+		return 0
+	}
+
+	return d.m.NumQubits()
+}
+
+func (d *densityMatrix) Add(v ...complex128) int {
+	rho := density.NewPureState(qubit.New(v...))
+	if d.m == nil {
+		d.m = rho
+		d.m.Rand = d.rand
+		return 0
+	}
+
+	d.m = d.m.TensorProduct(rho)
+	return d.m.NumQubits() - 1
+}
+
+func (d *densityMatrix) Apply(u *matrix.Matrix, control []int, state string, target ...int) {
+	d.m.ControlledState(u, qubits(control), state, qubits(target)...)
+}
+
+func (d *densityMatrix) ApplyKraus(kraus []*matrix.Matrix, target ...int) {
+	d.m.ApplyKraus(kraus, qubits(target)...)
+}
+
+func (d *densityMatrix) Swap(i, j int) {
+	d.m.Swap(density.Qubit(i), density.Qubit(j))
+}
+
+func (d *densityMatrix) Permute(f func(k int) int, control []int, target ...int) {
+	d.m.Permute(f, qubits(control), qubits(target)...)
+}
+
+func (d *densityMatrix) Measure(index int) *qubit.Qubit {
+	return d.m.Measure(density.Qubit(index))
+}
+
+func (d *densityMatrix) Reset(index int) {
+	d.m.Reset(density.Qubit(index))
+}
+
+func (d *densityMatrix) Amplitude() []complex128 {
+	return nil
+}
+
+func (d *densityMatrix) Probability() []float64 {
+	return d.m.Diagonal()
+}
+
+func (d *densityMatrix) Marginal(index ...int) []float64 {
+	return d.m.Marginal(qubits(index)...)
+}
+
+func (d *densityMatrix) State(index ...[]int) []qubit.State {
+	idx := make([][]density.Qubit, len(index))
+	for i := range index {
+		idx[i] = qubits(index[i])
+	}
+
+	return d.m.State(idx...)
+}
+
+func (d *densityMatrix) Clone() Backend {
+	if d.m == nil {
+		return &densityMatrix{rand: d.rand}
+	}
+
+	return &densityMatrix{m: d.m.Clone(), rand: d.rand}
+}
+
+func (d *densityMatrix) String() string {
+	return d.m.String()
+}
+
+// qubits returns the index as the qubits of the density matrix.
+func qubits(index []int) []density.Qubit {
+	qb := make([]density.Qubit, len(index))
+	for i := range index {
+		qb[i] = density.Qubit(index[i])
+	}
+
+	return qb
+}
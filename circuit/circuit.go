@@ -0,0 +1,530 @@
+package circuit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/number"
+)
+
+// ErrUnknownOperation is returned when the name of an operation is not a method of q.Q.
+var ErrUnknownOperation = errors.New("unknown operation")
+
+// Clbit is a classical bit.
+type Clbit int
+
+// Index returns the index of classical bit.
+func (b Clbit) Index() int {
+	return int(b)
+}
+
+// Bits is a list of classical bits.
+// Bits[0] is the most significant bit.
+type Bits []Clbit
+
+// Eq returns the condition that the bits are equal to v.
+func (b Bits) Eq(v int) Cond {
+	return Cond{
+		Clbits: b,
+		Value:  v,
+	}
+}
+
+// IsZero returns the condition that all bits are zero.
+func (b Bits) IsZero() Cond {
+	return b.Eq(0)
+}
+
+// IsOne returns the condition that all bits are one.
+func (b Bits) IsOne() Cond {
+	return b.Eq(1<<len(b) - 1)
+}
+
+// Cond is a classical condition.
+// It is true if the integer represented by Clbits equals Value.
+type Cond struct {
+	Clbits Bits
+	Value  int
+}
+
+// Eval returns true if the condition holds for the given classical bits.
+func (c Cond) Eval(bits []int) bool {
+	var v int
+	for _, b := range c.Clbits {
+		v = v<<1 | bits[b.Index()]
+	}
+
+	return v == c.Value
+}
+
+// Op is an operation of a quantum circuit.
+type Op struct {
+	Name      string         // Name of the method of q.Q. For example, "H", "ControlledNot" and "Measure".
+	Params    []float64      // Parameters such as the rotation angle.
+	Matrix    *matrix.Matrix // Matrix of "Apply", "ApplyOn", "Controlled" and "ControlledState".
+	Amplitude []complex128   // Amplitude of "New".
+	Qubits    []q.Qubit      // Target qubits.
+	Controls  []q.Qubit      // Control qubits.
+	State     string         // Control state. If empty, all control qubits are |1>.
+	Clbits    Bits           // Classical bits that store the measurement results.
+	Cond      *Cond          // Classical condition. If nil, the operation is always applied.
+}
+
+// String returns the string representation of op.
+func (o Op) String() string {
+	var sb strings.Builder
+	if o.Cond != nil {
+		fmt.Fprintf(&sb, "if(%v==%d) ", o.Cond.Clbits, o.Cond.Value)
+	}
+
+	sb.WriteString(o.Name)
+	if len(o.Params) > 0 {
+		fmt.Fprintf(&sb, "%v", o.Params)
+	}
+
+	if len(o.Amplitude) > 0 {
+		fmt.Fprintf(&sb, "%v", o.Amplitude)
+	}
+
+	if len(o.Controls) > 0 {
+		fmt.Fprintf(&sb, " %v", o.Controls)
+		if o.State != "" {
+			fmt.Fprintf(&sb, "=%s", o.State)
+		}
+	}
+
+	fmt.Fprintf(&sb, " %v", o.Qubits)
+	if len(o.Clbits) > 0 {
+		fmt.Fprintf(&sb, " -> %v", o.Clbits)
+	}
+
+	return sb.String()
+}
+
+// Circuit is a quantum circuit.
+// It records operations instead of executing them, and Run executes them on a quantum computation simulator.
+type Circuit struct {
+	Ops       []Op
+	numQubits int
+	numClbits int
+}
+
+// New returns a new quantum circuit.
+func New() *Circuit {
+	return &Circuit{
+		Ops: make([]Op, 0),
+	}
+}
+
+// NumQubits returns the number of qubits.
+func (c *Circuit) NumQubits() int {
+	return c.numQubits
+}
+
+// NumClbits returns the number of classical bits.
+func (c *Circuit) NumClbits() int {
+	return c.numClbits
+}
+
+// Add appends the operations to the circuit.
+func (c *Circuit) Add(op ...Op) *Circuit {
+	for _, o := range op {
+		for _, qb := range append(append([]q.Qubit{}, o.Controls...), o.Qubits...) {
+			c.numQubits = max(c.numQubits, qb.Index()+1)
+		}
+
+		for _, b := range o.Clbits {
+			c.numClbits = max(c.numClbits, b.Index()+1)
+		}
+
+		if o.Cond != nil {
+			for _, b := range o.Cond.Clbits {
+				c.numClbits = max(c.numClbits, b.Index()+1)
+			}
+		}
+
+		c.Ops = append(c.Ops, o)
+	}
+
+	return c
+}
+
+// Clbits returns n new classical bits.
+func (c *Circuit) Clbits(n int) Bits {
+	b := make(Bits, n)
+	for i := range n {
+		b[i] = Clbit(c.numClbits)
+		c.numClbits++
+	}
+
+	return b
+}
+
+// New returns a new qubit.
+// v is a vector of length 2**k, and allocates k consecutive qubits at once.
+// It returns the first of them, that is, the most significant bit of v.
+func (c *Circuit) New(v ...complex128) q.Qubit {
+	qb := make([]q.Qubit, max(number.Log2(len(v)), 1))
+	for i := range qb {
+		qb[i] = q.Qubit(c.numQubits + i)
+	}
+
+	c.Add(Op{Name: "New", Amplitude: v, Qubits: qb})
+	return qb[0]
+}
+
+// Zero returns a qubit in the zero state.
+func (c *Circuit) Zero() q.Qubit {
+	return c.New(1, 0)
+}
+
+// One returns a qubit in the one state.
+func (c *Circuit) One() q.Qubit {
+	return c.New(0, 1)
+}
+
+// Zeros returns n qubits in the zero state.
+func (c *Circuit) Zeros(n int) []q.Qubit {
+	qb := make([]q.Qubit, n)
+	for i := range n {
+		qb[i] = c.Zero()
+	}
+
+	return qb
+}
+
+// Ones returns n qubits in the one state.
+func (c *Circuit) Ones(n int) []q.Qubit {
+	qb := make([]q.Qubit, n)
+	for i := range n {
+		qb[i] = c.One()
+	}
+
+	return qb
+}
+
+// ZeroLog2 returns n qubits in the zero state.
+// n is greater than or equal to log2(N).
+func (c *Circuit) ZeroLog2(N int) []q.Qubit {
+	return c.Zeros(number.Log2(N) + 1)
+}
+
+// Reset sets qubits to the zero state.
+func (c *Circuit) Reset(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Reset", Qubits: qb})
+}
+
+// U applies U gate.
+func (c *Circuit) U(theta, phi, lambda float64, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "U", Params: []float64{theta, phi, lambda}, Qubits: qb})
+}
+
+// I applies I gate.
+func (c *Circuit) I(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "I", Qubits: qb})
+}
+
+// X applies X gate.
+func (c *Circuit) X(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "X", Qubits: qb})
+}
+
+// Y applies Y gate.
+func (c *Circuit) Y(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Y", Qubits: qb})
+}
+
+// Z applies Z gate.
+func (c *Circuit) Z(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Z", Qubits: qb})
+}
+
+// H applies H gate.
+func (c *Circuit) H(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "H", Qubits: qb})
+}
+
+// S applies S gate.
+func (c *Circuit) S(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "S", Qubits: qb})
+}
+
+// T applies T gate.
+func (c *Circuit) T(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "T", Qubits: qb})
+}
+
+// R applies R gate with theta.
+func (c *Circuit) R(theta float64, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "R", Params: []float64{theta}, Qubits: qb})
+}
+
+// RX applies RX gate with theta.
+func (c *Circuit) RX(theta float64, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "RX", Params: []float64{theta}, Qubits: qb})
+}
+
+// RY applies RY gate with theta.
+func (c *Circuit) RY(theta float64, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "RY", Params: []float64{theta}, Qubits: qb})
+}
+
+// RZ applies RZ gate with theta.
+func (c *Circuit) RZ(theta float64, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "RZ", Params: []float64{theta}, Qubits: qb})
+}
+
+// Apply applies matrix to qubits.
+func (c *Circuit) Apply(m *matrix.Matrix, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Apply", Matrix: m, Qubits: qb})
+}
+
+// ApplyOn applies the (2**k x 2**k) unitary u to the k qubits in the given order.
+func (c *Circuit) ApplyOn(u *matrix.Matrix, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "ApplyOn", Matrix: u, Qubits: qb})
+}
+
+// Controlled applies controlled-m gate.
+func (c *Circuit) Controlled(m *matrix.Matrix, control []q.Qubit, target ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Controlled", Matrix: m, Controls: control, Qubits: target})
+}
+
+// ControlledState applies controlled-m gate with the control state.
+func (c *Circuit) ControlledState(m *matrix.Matrix, control []q.Qubit, state string, target ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "ControlledState", Matrix: m, Controls: control, State: state, Qubits: target})
+}
+
+// C applies controlled-m gate.
+func (c *Circuit) C(m *matrix.Matrix, control, target q.Qubit) *Circuit {
+	return c.Controlled(m, []q.Qubit{control}, target)
+}
+
+// ControlledNot applies CNOT gate.
+func (c *Circuit) ControlledNot(control []q.Qubit, target q.Qubit) *Circuit {
+	return c.Add(Op{Name: "ControlledNot", Controls: control, Qubits: []q.Qubit{target}})
+}
+
+// CNOT applies CNOT gate.
+func (c *Circuit) CNOT(control, target q.Qubit) *Circuit {
+	return c.ControlledNot([]q.Qubit{control}, target)
+}
+
+// CCNOT applies CCNOT gate.
+func (c *Circuit) CCNOT(control0, control1, target q.Qubit) *Circuit {
+	return c.ControlledNot([]q.Qubit{control0, control1}, target)
+}
+
+// CCCNOT applies CCCNOT gate.
+func (c *Circuit) CCCNOT(control0, control1, control2, target q.Qubit) *Circuit {
+	return c.ControlledNot([]q.Qubit{control0, control1, control2}, target)
+}
+
+// Toffoli applies Toffoli gate.
+func (c *Circuit) Toffoli(control0, control1, target q.Qubit) *Circuit {
+	return c.CCNOT(control0, control1, target)
+}
+
+// ControlledZ applies Controlled-Z gate.
+func (c *Circuit) ControlledZ(control []q.Qubit, target q.Qubit) *Circuit {
+	return c.Add(Op{Name: "ControlledZ", Controls: control, Qubits: []q.Qubit{target}})
+}
+
+// CZ applies Controlled-Z gate.
+func (c *Circuit) CZ(control, target q.Qubit) *Circuit {
+	return c.ControlledZ([]q.Qubit{control}, target)
+}
+
+// CCZ applies Controlled-Controlled-Z gate.
+func (c *Circuit) CCZ(control0, control1, target q.Qubit) *Circuit {
+	return c.ControlledZ([]q.Qubit{control0, control1}, target)
+}
+
+// ControlledR applies Controlled-R gate.
+func (c *Circuit) ControlledR(theta float64, control []q.Qubit, target q.Qubit) *Circuit {
+	return c.Add(Op{Name: "ControlledR", Params: []float64{theta}, Controls: control, Qubits: []q.Qubit{target}})
+}
+
+// CR applies Controlled-R gate.
+func (c *Circuit) CR(theta float64, control, target q.Qubit) *Circuit {
+	return c.ControlledR(theta, []q.Qubit{control}, target)
+}
+
+// ControlledModExp2 applies Controlled-ModExp2 gate.
+func (c *Circuit) ControlledModExp2(a, j, N int, control q.Qubit, target []q.Qubit) *Circuit {
+	return c.Add(Op{
+		Name:     "ControlledModExp2",
+		Params:   []float64{float64(a), float64(j), float64(N)},
+		Controls: []q.Qubit{control},
+		Qubits:   target,
+	})
+}
+
+// CModExp2 applies Controlled-ModExp2 gate.
+func (c *Circuit) CModExp2(a, N int, control []q.Qubit, target []q.Qubit) *Circuit {
+	for i, ctrl := range control {
+		c.ControlledModExp2(a, i, N, ctrl, target)
+	}
+
+	return c
+}
+
+// Cond applies m if condition is true.
+func (c *Circuit) Cond(condition Cond, m *matrix.Matrix, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Apply", Matrix: m, Qubits: qb, Cond: &condition})
+}
+
+// CondX applies X gate if condition is true.
+func (c *Circuit) CondX(condition Cond, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "X", Qubits: qb, Cond: &condition})
+}
+
+// CondZ applies Z gate if condition is true.
+func (c *Circuit) CondZ(condition Cond, qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Z", Qubits: qb, Cond: &condition})
+}
+
+// Swap applies Swap gate.
+func (c *Circuit) Swap(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "Swap", Qubits: qb})
+}
+
+// QFT applies Quantum Fourier Transform.
+func (c *Circuit) QFT(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "QFT", Qubits: qb})
+}
+
+// InverseQFT applies Inverse Quantum Fourier Transform.
+func (c *Circuit) InverseQFT(qb ...q.Qubit) *Circuit {
+	return c.Add(Op{Name: "InverseQFT", Qubits: qb})
+}
+
+// InvQFT applies Inverse Quantum Fourier Transform.
+func (c *Circuit) InvQFT(qb ...q.Qubit) *Circuit {
+	return c.InverseQFT(qb...)
+}
+
+// IQFT applies Inverse Quantum Fourier Transform.
+func (c *Circuit) IQFT(qb ...q.Qubit) *Circuit {
+	return c.InverseQFT(qb...)
+}
+
+// M measures qubits and returns the classical bits that store the results.
+func (c *Circuit) M(qb ...q.Qubit) Bits {
+	return c.Measure(qb...)
+}
+
+// Measure measures qubits and returns the classical bits that store the results.
+// If no qubit is given, it measures all qubits.
+func (c *Circuit) Measure(qb ...q.Qubit) Bits {
+	if len(qb) < 1 {
+		qb = make([]q.Qubit, c.numQubits)
+		for i := range c.numQubits {
+			qb[i] = q.Qubit(i)
+		}
+	}
+
+	b := c.Clbits(len(qb))
+	c.Add(Op{Name: "Measure", Qubits: qb, Clbits: b})
+	return b
+}
+
+// Run executes the operations on the quantum computation simulator and returns the classical bits.
+// The qubits allocated by New are mapped to the qubits allocated in qsim,
+// and the other qubits are used as is.
+// It panics with ErrUnknownOperation if the name of an operation is unknown.
+func (c *Circuit) Run(qsim *q.Q) []int {
+	bits := make([]int, c.numClbits)
+
+	index := make(map[q.Qubit]q.Qubit)
+	at := func(qb []q.Qubit) []q.Qubit {
+		out := make([]q.Qubit, len(qb))
+		for i := range qb {
+			out[i] = qb[i]
+			if v, ok := index[qb[i]]; ok {
+				out[i] = v
+			}
+		}
+
+		return out
+	}
+
+	for _, op := range c.Ops {
+		if op.Cond != nil && !op.Cond.Eval(bits) {
+			continue
+		}
+
+		qb, ctrl := at(op.Qubits), at(op.Controls)
+		switch op.Name {
+		case "New":
+			// the k qubits of the amplitude are the last k qubits of qsim.
+			qsim.New(op.Amplitude...)
+			n, k := qsim.NumQubits(), len(op.Qubits)
+			for i := range op.Qubits {
+				index[op.Qubits[i]] = q.Qubit(n - k + i)
+			}
+		case "Reset":
+			qsim.Reset(qb...)
+		case "U":
+			qsim.U(op.Params[0], op.Params[1], op.Params[2], qb...)
+		case "I":
+			qsim.I(qb...)
+		case "X":
+			qsim.X(qb...)
+		case "Y":
+			qsim.Y(qb...)
+		case "Z":
+			qsim.Z(qb...)
+		case "H":
+			qsim.H(qb...)
+		case "S":
+			qsim.S(qb...)
+		case "T":
+			qsim.T(qb...)
+		case "R":
+			qsim.R(op.Params[0], qb...)
+		case "RX":
+			qsim.RX(op.Params[0], qb...)
+		case "RY":
+			qsim.RY(op.Params[0], qb...)
+		case "RZ":
+			qsim.RZ(op.Params[0], qb...)
+		case "Apply":
+			qsim.Apply(op.Matrix, qb...)
+		case "ApplyOn":
+			qsim.ApplyOn(op.Matrix, qb...)
+		case "Controlled":
+			qsim.Controlled(op.Matrix, ctrl, qb...)
+		case "ControlledState":
+			qsim.ControlledState(op.Matrix, ctrl, op.State, qb...)
+		case "ControlledNot":
+			qsim.ControlledNot(ctrl, qb[0])
+		case "ControlledZ":
+			qsim.ControlledZ(ctrl, qb[0])
+		case "ControlledR":
+			qsim.ControlledR(op.Params[0], ctrl, qb[0])
+		case "ControlledModExp2":
+			a, j, N := int(op.Params[0]), int(op.Params[1]), int(op.Params[2])
+			qsim.ControlledModExp2(a, j, N, ctrl[0], qb)
+		case "Swap":
+			qsim.Swap(qb...)
+		case "QFT":
+			qsim.QFT(qb...)
+		case "InverseQFT":
+			qsim.InverseQFT(qb...)
+		case "Measure":
+			for i := range qb {
+				bits[op.Clbits[i].Index()] = 0
+				if qsim.Measure(qb[i]).IsOne() {
+					bits[op.Clbits[i].Index()] = 1
+				}
+			}
+		default:
+			panic(fmt.Errorf("%w: %v", ErrUnknownOperation, op))
+		}
+	}
+
+	return bits
+}
@@ -0,0 +1,350 @@
+package circuit_test
+
+import (
+	"errors"
+	"fmt"
+	"math/cmplx"
+	"testing"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+func ExampleCircuit() {
+	c := circuit.New()
+
+	q0 := c.Zero()
+	q1 := c.Zero()
+	c.H(q0).CNOT(q0, q1)
+
+	for _, op := range c.Ops {
+		fmt.Println(op)
+	}
+
+	qsim := q.New()
+	c.Run(qsim)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// New[(1+0i) (0+0i)] [0]
+	// New[(1+0i) (0+0i)] [1]
+	// H [0]
+	// ControlledNot [0] [1]
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+}
+
+func ExampleCircuit_Run() {
+	c := circuit.New()
+
+	q0 := c.Zero()
+	q1 := c.Zero()
+	c.H(q0).CNOT(q0, q1)
+	m := c.Measure(q0, q1)
+
+	qsim := q.New()
+	qsim.Rand = rand.Const()
+
+	for range 3 {
+		bits := c.Run(qsim)
+		fmt.Println(m, bits)
+	}
+
+	fmt.Println(qsim.NumQubits())
+
+	// Output:
+	// [0 1] [1 1]
+	// [0 1] [0 0]
+	// [0 1] [0 0]
+	// 6
+}
+
+func ExampleCircuit_CondX() {
+	c := circuit.New()
+
+	// generate qubits of |phi>|0>|0>
+	phi := c.New(1, 2)
+	q0 := c.Zero()
+	q1 := c.Zero()
+
+	c.H(q0).CNOT(q0, q1)
+	c.CNOT(phi, q0).H(phi)
+
+	// Alice send mz, mx to Bob
+	mz := c.Measure(phi)
+	mx := c.Measure(q0)
+
+	// Bob Apply X and Z
+	c.CondX(mx.IsOne(), q1)
+	c.CondZ(mz.IsOne(), q1)
+
+	qsim := q.New()
+	qsim.Rand = rand.Const()
+	c.Run(qsim)
+
+	// Bob got |phi> state with q1
+	for _, s := range qsim.State(q1) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [0][  0]( 0.4472 0.0000i): 0.2000
+	// [1][  1]( 0.8944 0.0000i): 0.8000
+}
+
+func ExampleBits_Eq() {
+	c := circuit.New()
+
+	q0 := c.New(1, 2) // (0.2, 0.8)
+
+	// encoding
+	q1 := c.Zero()
+	q2 := c.Zero()
+	c.CNOT(q0, q1).CNOT(q0, q2)
+
+	// error: first qubit is flipped
+	c.X(q0)
+
+	// add ancilla qubit
+	q3 := c.Zero()
+	q4 := c.Zero()
+
+	// error correction
+	c.CNOT(q0, q3).CNOT(q1, q3)
+	c.CNOT(q1, q4).CNOT(q2, q4)
+
+	m := c.Measure(q3, q4)
+	c.CondX(m.Eq(0b10), q0)
+	c.CondX(m.Eq(0b11), q1)
+	c.CondX(m.Eq(0b01), q2)
+
+	// decoding
+	c.CNOT(q0, q2).CNOT(q0, q1)
+
+	qsim := q.New()
+	c.Run(qsim)
+
+	for _, s := range qsim.State(q0) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [0][  0]( 0.4472 0.0000i): 0.2000
+	// [1][  1]( 0.8944 0.0000i): 0.8000
+}
+
+func ExampleCircuit_Add() {
+	// operations on the qubits already allocated in the simulator
+	c := circuit.New()
+	c.Add(circuit.Op{Name: "H", Qubits: []q.Qubit{0}})
+	c.Add(circuit.Op{Name: "ControlledNot", Controls: []q.Qubit{0}, Qubits: []q.Qubit{1}})
+
+	qsim := q.New()
+	qsim.Zeros(2)
+	c.Run(qsim)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+}
+
+func TestRun(t *testing.T) {
+	type program struct {
+		c *circuit.Circuit
+		q func(qsim *q.Q)
+	}
+
+	build := func(f func(c *circuit.Circuit, qb []q.Qubit), g func(qsim *q.Q, qb []q.Qubit)) program {
+		c := circuit.New()
+		f(c, c.Zeros(4))
+
+		return program{
+			c: c,
+			q: func(qsim *q.Q) { g(qsim, qsim.Zeros(4)) },
+		}
+	}
+
+	cases := []program{
+		build(
+			func(c *circuit.Circuit, qb []q.Qubit) {
+				c.H(qb...).T(qb[0]).S(qb[1]).X(qb[2]).Y(qb[3]).Z(qb[0]).I(qb[1])
+				c.U(1, 2, 3, qb[0]).R(0.1, qb[1]).RX(0.2, qb[2]).RY(0.3, qb[3]).RZ(0.4, qb[0])
+			},
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).T(qb[0]).S(qb[1]).X(qb[2]).Y(qb[3]).Z(qb[0]).I(qb[1])
+				qsim.U(1, 2, 3, qb[0]).R(0.1, qb[1]).RX(0.2, qb[2]).RY(0.3, qb[3]).RZ(0.4, qb[0])
+			},
+		),
+		build(
+			func(c *circuit.Circuit, qb []q.Qubit) {
+				c.H(qb...).CNOT(qb[0], qb[1]).CCNOT(qb[0], qb[1], qb[2]).CCCNOT(qb[0], qb[1], qb[2], qb[3]).Toffoli(qb[3], qb[2], qb[1])
+				c.CZ(qb[0], qb[3]).CCZ(qb[1], qb[2], qb[0]).CR(0.5, qb[2], qb[1]).Swap(qb[0], qb[3])
+				c.C(gate.Y(), qb[1], qb[2]).ControlledState(gate.H(), []q.Qubit{qb[0], qb[1]}, "01", qb[3])
+				c.Apply(gate.RX(0.7), qb[2]).ApplyOn(gate.Swap(2, 0, 1), qb[3], qb[1])
+			},
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).CNOT(qb[0], qb[1]).CCNOT(qb[0], qb[1], qb[2]).CCCNOT(qb[0], qb[1], qb[2], qb[3]).Toffoli(qb[3], qb[2], qb[1])
+				qsim.CZ(qb[0], qb[3]).CCZ(qb[1], qb[2], qb[0]).CR(0.5, qb[2], qb[1]).Swap(qb[0], qb[3])
+				qsim.C(gate.Y(), qb[1], qb[2]).ControlledState(gate.H(), []q.Qubit{qb[0], qb[1]}, "01", qb[3])
+				qsim.Apply(gate.RX(0.7), qb[2]).ApplyOn(gate.Swap(2, 0, 1), qb[3], qb[1])
+			},
+		),
+		build(
+			func(c *circuit.Circuit, qb []q.Qubit) {
+				c.X(qb[3]).H(qb[0]).CModExp2(2, 7, qb[:1], qb[1:]).QFT(qb...).InvQFT(qb[1:]...).IQFT(qb[:2]...)
+			},
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.X(qb[3]).H(qb[0]).CModExp2(2, 7, qb[:1], qb[1:]).QFT(qb...).InvQFT(qb[1:]...).IQFT(qb[:2]...)
+			},
+		),
+		build(
+			func(c *circuit.Circuit, qb []q.Qubit) {
+				c.H(qb...)
+				c.Reset(qb[0])
+				c.Measure(qb[1])
+				c.M(qb[2])
+			},
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...)
+				qsim.Reset(qb[0])
+				qsim.Measure(qb[1])
+				qsim.M(qb[2])
+			},
+		),
+	}
+
+	for _, c := range cases {
+		got := q.New()
+		got.Rand = rand.Const(1)
+		c.c.Run(got)
+
+		want := q.New()
+		want.Rand = rand.Const(1)
+		c.q(want)
+
+		for i, a := range got.Amplitude() {
+			if cmplx.Abs(a-want.Amplitude()[i]) > epsilon.E13() {
+				t.Errorf("got=%v, want=%v", got.Amplitude(), want.Amplitude())
+				break
+			}
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	c := circuit.New()
+	c.Ones(2)
+	c.Zero()
+	m := c.Measure()
+
+	if c.NumQubits() != 3 || c.NumClbits() != 3 {
+		t.Errorf("qubits=%d, clbits=%d", c.NumQubits(), c.NumClbits())
+	}
+
+	bits := c.Run(q.New())
+	if fmt.Sprint(m, bits) != "[0 1 2] [1 1 0]" {
+		t.Errorf("got=%v %v", m, bits)
+	}
+}
+
+func TestNew(t *testing.T) {
+	c := circuit.New()
+	q0 := c.Zero()
+	q1 := c.New(1, 2i, 0, -1)
+	q3 := c.Zero()
+	c.H(q0).CNOT(q0, q1+1).Swap(q1, q3)
+
+	if q1 != 1 || q3 != 3 || c.NumQubits() != 4 {
+		t.Errorf("q1=%v, q3=%v, qubits=%d", q1, q3, c.NumQubits())
+	}
+
+	// the qubits of the circuit are mapped to the qubits allocated after the existing ones.
+	got := q.New()
+	got.Zero()
+	c.Run(got)
+
+	want := q.New()
+	want.Zeros(2)
+	want.New(1, 2i, 0, -1)
+	want.Zero()
+	want.H(1).CNOT(1, 3).Swap(2, 4)
+
+	for i, a := range got.Amplitude() {
+		if cmplx.Abs(a-want.Amplitude()[i]) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got.Amplitude(), want.Amplitude())
+			break
+		}
+	}
+}
+
+func TestCond(t *testing.T) {
+	cases := []struct {
+		cond circuit.Cond
+		bits []int
+		want bool
+	}{
+		{circuit.Bits{0}.IsOne(), []int{1}, true},
+		{circuit.Bits{0}.IsOne(), []int{0}, false},
+		{circuit.Bits{0}.IsZero(), []int{0}, true},
+		{circuit.Bits{0, 1}.IsOne(), []int{1, 1}, true},
+		{circuit.Bits{0, 1}.IsOne(), []int{1, 0}, false},
+		{circuit.Bits{0, 1}.Eq(2), []int{1, 0}, true},
+		{circuit.Bits{1, 0}.Eq(2), []int{1, 0}, false},
+	}
+
+	for _, c := range cases {
+		if got := c.cond.Eval(c.bits); got != c.want {
+			t.Errorf("cond=%v, bits=%v, got=%v", c.cond, c.bits, got)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	c := circuit.New()
+	qb := c.Zeros(3)
+	m := c.Measure(qb[0])
+	c.ControlledState(gate.X(), []q.Qubit{qb[0], qb[1]}, "10", qb[2])
+	c.CondX(m.IsOne(), qb[1])
+	c.RX(0.5, qb[2])
+	c.ControlledModExp2(7, 1, 15, qb[0], qb[1:])
+
+	want := []string{
+		"New[(1+0i) (0+0i)] [0]",
+		"New[(1+0i) (0+0i)] [1]",
+		"New[(1+0i) (0+0i)] [2]",
+		"Measure [0] -> [0]",
+		"ControlledState [0 1]=10 [2]",
+		"if([0]==1) X [1]",
+		"RX[0.5] [2]",
+		"ControlledModExp2[7 1 15] [0] [1 2]",
+	}
+
+	for i, op := range c.Ops {
+		if op.String() != want[i] {
+			t.Errorf("got=%v, want=%v", op, want[i])
+		}
+	}
+}
+
+func TestRunPanics(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, circuit.ErrUnknownOperation) {
+			t.Errorf("got=%v, want=%v", err, circuit.ErrUnknownOperation)
+		}
+	}()
+
+	c := circuit.New()
+	c.Ops = append(c.Ops, circuit.Op{Name: "Unknown"})
+	c.Run(q.New())
+}
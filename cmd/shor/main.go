@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/math/epsilon"
 	"github.com/itsubaki/q/math/number"
 	"github.com/itsubaki/q/math/rand"
 )
@@ -84,8 +85,12 @@ func main() {
 	qsim.Measure(r1...)
 	print("measure reg1", qsim, r0, r1)
 
-	for _, st := range qsim.State(r0) {
-		i, m := st.Int(), st.BinaryString()
+	for i, p := range qsim.Marginal(r0...) {
+		if p < epsilon.E13() {
+			continue
+		}
+
+		m := fmt.Sprintf("%0*b", t, i)
 		s, r, d, ok := number.FindOrder(a, N, fmt.Sprintf("0.%s", m))
 		if !ok || number.IsOdd(r) {
 			fmt.Printf("  i=%3d: N=%d, a=%d, t=%d; s/r=%2d/%2d ([0.%v]~%.4f);\n", i, N, a, t, s, r, m, d)
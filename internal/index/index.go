@@ -0,0 +1,141 @@
+// Package index provides the qubit index helpers shared by the state representations.
+// Qubit 0 is the most significant bit of the basis state.
+package index
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+)
+
+var (
+	ErrInvalidDimension = errors.New("invalid dimension")
+	ErrInvalidIndex     = errors.New("invalid qubit index")
+	ErrInvalidState     = errors.New("invalid control state")
+	ErrNotBijection     = errors.New("not a bijection")
+)
+
+// Validate returns an error if u cannot be applied to the target qubits of n qubits,
+// or the control and target qubits are not distinct qubits in [0, n).
+// If u is nil, it only validates the qubit index.
+func Validate(u *matrix.Matrix, n int, control, target []int) error {
+	if u != nil {
+		rows, cols := u.Dimension()
+		if rows != cols || rows != 1<<len(target) {
+			return fmt.Errorf("%w: %dx%d matrix for %d target qubits", ErrInvalidDimension, rows, cols, len(target))
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, i := range append(append([]int{}, control...), target...) {
+		if i < 0 || i > n-1 || seen[i] {
+			return fmt.Errorf("%w: %d in control=%v, target=%v", ErrInvalidIndex, i, control, target)
+		}
+
+		seen[i] = true
+	}
+
+	return nil
+}
+
+// State returns an error if state is not a binary string of the same length as control.
+func State(state string, control []int) error {
+	if len(state) != len(control) || strings.Trim(state, "01") != "" {
+		return fmt.Errorf("%w: state=%q, control=%v", ErrInvalidState, state, control)
+	}
+
+	return nil
+}
+
+// Bijection returns an error if f is not a bijection on [0, 2**k).
+func Bijection(f func(k int) int, k int) error {
+	seen := make([]bool, 1<<k)
+	for i := range seen {
+		v := f(i)
+		if v < 0 || v > len(seen)-1 || seen[v] {
+			return fmt.Errorf("%w: f(%d)=%d on [0, %d)", ErrNotBijection, i, v, len(seen))
+		}
+
+		seen[v] = true
+	}
+
+	return nil
+}
+
+// Round returns a with the real and imaginary parts smaller than eps replaced by zero.
+func Round(a complex128, eps ...float64) complex128 {
+	e := epsilon.E13(eps...)
+
+	if math.Abs(real(a)) < e {
+		a = complex(0, imag(a))
+	}
+
+	if math.Abs(imag(a)) < e {
+		a = complex(real(a), 0)
+	}
+
+	return a
+}
+
+// Take returns the integer value of the bits of i at the given qubit index.
+// index[0] is the most significant bit.
+func Take(n, i int, index []int) int {
+	var v int
+	for _, bit := range index {
+		v = v<<1 | (i>>(n-1-bit))&1
+	}
+
+	return v
+}
+
+// Put returns i with the bits at the given qubit index replaced by v.
+// index[0] is the most significant bit.
+func Put(n, i int, index []int, v int) int {
+	k := len(index)
+	for j, bit := range index {
+		b := 1 << (n - 1 - bit)
+		if (v>>(k-1-j))&1 == 1 {
+			i |= b
+			continue
+		}
+
+		i &^= b
+	}
+
+	return i
+}
+
+// Offset returns the index offset of the basis state |r> of the target qubits of n qubits.
+// target[0] is the most significant bit of r.
+func Offset(n int, target []int) []int {
+	k := len(target)
+	offset := make([]int, 1<<k)
+	for r := range offset {
+		for j, t := range target {
+			if r&(1<<(k-1-j)) != 0 {
+				offset[r] |= 1 << (n - 1 - t)
+			}
+		}
+	}
+
+	return offset
+}
+
+// Binary returns the binary string of the bits of i at the given qubit index.
+func Binary(n, i int, index []int) string {
+	var sb strings.Builder
+	for _, bit := range index {
+		if (i & (1 << (n - 1 - bit))) == 0 {
+			sb.WriteByte('0')
+			continue
+		}
+
+		sb.WriteByte('1')
+	}
+
+	return sb.String()
+}
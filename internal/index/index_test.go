@@ -0,0 +1,159 @@
+package index_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/itsubaki/q/internal/index"
+	"github.com/itsubaki/q/math/matrix"
+)
+
+func ExampleTake() {
+	// |0110>, qubit 0 is the most significant bit
+	fmt.Println(index.Take(4, 0b0110, []int{1, 2}))
+	fmt.Println(index.Take(4, 0b0110, []int{3, 1}))
+
+	// Output:
+	// 3
+	// 1
+}
+
+func ExamplePut() {
+	fmt.Printf("%04b\n", index.Put(4, 0b0110, []int{1, 2}, 0b01))
+	fmt.Printf("%04b\n", index.Put(4, 0b0110, []int{3, 0}, 0b11))
+
+	// Output:
+	// 0010
+	// 1111
+}
+
+func ExampleOffset() {
+	for _, v := range index.Offset(4, []int{2, 0}) {
+		fmt.Printf("%04b\n", v)
+	}
+
+	// Output:
+	// 0000
+	// 1000
+	// 0010
+	// 1010
+}
+
+func ExampleBinary() {
+	fmt.Println(index.Binary(4, 0b0110, []int{0, 1, 2}))
+	fmt.Println(index.Binary(4, 0b0110, []int{2, 0}))
+
+	// Output:
+	// 011
+	// 10
+}
+
+func TestTakePut(t *testing.T) {
+	n := 5
+	cases := []struct {
+		index []int
+	}{
+		{[]int{0}},
+		{[]int{4}},
+		{[]int{1, 3}},
+		{[]int{3, 1}},
+		{[]int{4, 0, 2}},
+	}
+
+	for _, c := range cases {
+		for i := range 1 << n {
+			for v := range 1 << len(c.index) {
+				j := index.Put(n, i, c.index, v)
+				if got := index.Take(n, j, c.index); got != v {
+					t.Errorf("index=%v, i=%d, got=%v, want=%v", c.index, i, got, v)
+				}
+
+				if got := index.Put(n, j, c.index, index.Take(n, i, c.index)); got != i {
+					t.Errorf("index=%v, i=%d, got=%v, want=%v", c.index, i, got, i)
+				}
+			}
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		in   complex128
+		eps  []float64
+		want complex128
+	}{
+		{complex(1e-14, 1), nil, complex(0, 1)},
+		{complex(1, -1e-14), nil, complex(1, 0)},
+		{complex(1e-3, 1e-3), []float64{1e-2}, 0},
+		{complex(1e-3, 1e-3), nil, complex(1e-3, 1e-3)},
+	}
+
+	for _, c := range cases {
+		if got := index.Round(c.in, c.eps...); got != c.want {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		u       *matrix.Matrix
+		control []int
+		target  []int
+		want    error
+	}{
+		{matrix.Identity(2), []int{0}, []int{1}, nil},
+		{nil, nil, []int{0, 1, 2}, nil},
+		{matrix.Identity(2), nil, []int{0, 1}, index.ErrInvalidDimension},
+		{matrix.Zero(2, 4), nil, []int{0, 1}, index.ErrInvalidDimension},
+		{matrix.Identity(2), nil, []int{3}, index.ErrInvalidIndex},
+		{matrix.Identity(2), nil, []int{-1}, index.ErrInvalidIndex},
+		{matrix.Identity(2), []int{1}, []int{1}, index.ErrInvalidIndex},
+	}
+
+	for _, c := range cases {
+		if got := index.Validate(c.u, 3, c.control, c.target); !errors.Is(got, c.want) {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	cases := []struct {
+		state   string
+		control []int
+		want    error
+	}{
+		{"10", []int{0, 1}, nil},
+		{"", nil, nil},
+		{"1", []int{0, 1}, index.ErrInvalidState},
+		{"12", []int{0, 1}, index.ErrInvalidState},
+	}
+
+	for _, c := range cases {
+		if got := index.State(c.state, c.control); !errors.Is(got, c.want) {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
+
+func TestBijection(t *testing.T) {
+	cases := []struct {
+		f    func(k int) int
+		k    int
+		want error
+	}{
+		{func(k int) int { return k ^ 1 }, 2, nil},
+		{func(k int) int { return 3 - k }, 2, nil},
+		{func(k int) int { return k / 2 }, 2, index.ErrNotBijection},
+		{func(k int) int { return k + 1 }, 2, index.ErrNotBijection},
+		{func(k int) int { return k - 1 }, 2, index.ErrNotBijection},
+	}
+
+	for _, c := range cases {
+		if got := index.Bijection(c.f, c.k); !errors.Is(got, c.want) {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Package trajectory provides the choice of the Kraus operator in a quantum trajectory.
+package trajectory
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+)
+
+var ErrZeroProbability = errors.New("zero probability for all kraus operators")
+
+// Choose returns the index of the Kraus operator K chosen with the probability p = tr(K * rho * K^dagger), and p.
+// rho is the reduced density matrix of the target qubits, and r is a random number in [0, 1).
+// It returns ErrZeroProbability if no operator has nonzero probability.
+func Choose(kraus []*matrix.Matrix, rho *matrix.Matrix, r float64) (int, float64, error) {
+	k, p := -1, make([]float64, len(kraus))
+
+	var sum float64
+	for i, m := range kraus {
+		p[i] = real(m.MatMul(rho).MatMul(m.Dagger()).Trace())
+		if p[i] < epsilon.E13() {
+			continue
+		}
+
+		// the last operator with nonzero probability is chosen if r exceeds the sum by rounding.
+		k, sum = i, sum+p[i]
+		if r < sum {
+			break
+		}
+	}
+
+	if k < 0 {
+		return 0, 0, fmt.Errorf("%w: probability=%v", ErrZeroProbability, p)
+	}
+
+	return k, p[k], nil
+}
@@ -0,0 +1,86 @@
+package trajectory_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/itsubaki/q/internal/trajectory"
+	"github.com/itsubaki/q/math/matrix"
+)
+
+func ExampleChoose() {
+	// amplitude damping with gamma = 0.5 on |1>
+	kraus := []*matrix.Matrix{
+		matrix.New([]complex128{1, 0}, []complex128{0, complex(math.Sqrt(0.5), 0)}),
+		matrix.New([]complex128{0, complex(math.Sqrt(0.5), 0)}, []complex128{0, 0}),
+	}
+	rho := matrix.New([]complex128{0, 0}, []complex128{0, 1})
+
+	for _, r := range []float64{0.1, 0.9} {
+		k, p, err := trajectory.Choose(kraus, rho, r)
+		fmt.Printf("%d %.4f %v\n", k, p, err)
+	}
+
+	// Output:
+	// 0 0.5000 <nil>
+	// 1 0.5000 <nil>
+}
+
+func TestChoose(t *testing.T) {
+	cases := []struct {
+		kraus []*matrix.Matrix
+		rho   *matrix.Matrix
+		r     float64
+		k     int
+		p     float64
+		err   error
+	}{
+		{
+			// |0><0| is never chosen on |1>
+			[]*matrix.Matrix{
+				matrix.New([]complex128{1, 0}, []complex128{0, 0}),
+				matrix.New([]complex128{0, 0}, []complex128{0, 1}),
+			},
+			matrix.New([]complex128{0, 0}, []complex128{0, 1}),
+			0,
+			1, 1, nil,
+		},
+		{
+			// the last operator with nonzero probability is chosen if r exceeds the sum by rounding.
+			[]*matrix.Matrix{
+				matrix.New([]complex128{0, 0}, []complex128{0, 1}),
+				matrix.New([]complex128{1, 0}, []complex128{0, 0}),
+			},
+			matrix.New([]complex128{0, 0}, []complex128{0, 1}),
+			1,
+			0, 1, nil,
+		},
+		{
+			[]*matrix.Matrix{
+				matrix.New([]complex128{1, 0}, []complex128{0, 0}),
+			},
+			matrix.New([]complex128{0, 0}, []complex128{0, 1}),
+			0.5,
+			0, 0, trajectory.ErrZeroProbability,
+		},
+		{
+			nil,
+			matrix.New([]complex128{1, 0}, []complex128{0, 0}),
+			0.5,
+			0, 0, trajectory.ErrZeroProbability,
+		},
+	}
+
+	for _, c := range cases {
+		k, p, err := trajectory.Choose(c.kraus, c.rho, c.r)
+		if !errors.Is(err, c.err) {
+			t.Errorf("got=%v, want=%v", err, c.err)
+		}
+
+		if k != c.k || math.Abs(p-c.p) > 1e-13 {
+			t.Errorf("got=(%v, %v), want=(%v, %v)", k, p, c.k, c.p)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package matrix
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/itsubaki/q/math/epsilon"
+)
+
+// Eigen returns the eigenvalues and the eigenvectors of the Hermitian matrix m.
+// The eigenvalues are real and in ascending order,
+// and the i-th column of the eigenvectors is the orthonormal eigenvector of the i-th eigenvalue.
+// It uses the cyclic Jacobi method with complex rotations,
+// and stops when the off-diagonal elements are less than eps.
+func (m *Matrix) Eigen(eps ...float64) ([]float64, *Matrix) {
+	n, _ := m.Dimension()
+	a, v := m.Clone(), Identity(n)
+	e := epsilon.E13(eps...)
+
+	for range 100 {
+		var off float64
+		for p := range n {
+			for q := p + 1; q < n; q++ {
+				off += math.Pow(cmplx.Abs(a.At(p, q)), 2)
+			}
+		}
+
+		if math.Sqrt(off) < e {
+			break
+		}
+
+		for p := range n {
+			for q := p + 1; q < n; q++ {
+				rotate(a, v, p, q)
+			}
+		}
+	}
+
+	index := make([]int, n)
+	for i := range n {
+		index[i] = i
+	}
+
+	sort.SliceStable(index, func(i, j int) bool {
+		return real(a.At(index[i], index[i])) < real(a.At(index[j], index[j]))
+	})
+
+	w, vec := make([]float64, n), Zero(n, n)
+	for i, k := range index {
+		w[i] = real(a.At(k, k))
+		for r := range n {
+			vec.Set(r, i, v.At(r, k))
+		}
+	}
+
+	return w, vec
+}
+
+// rotate applies the Jacobi rotation that zeros a[p][q] to a, and accumulates it in v.
+func rotate(a, v *Matrix, p, q int) {
+	apq := a.At(p, q)
+	abs := cmplx.Abs(apq)
+	if abs == 0 {
+		return
+	}
+
+	// r = diag(1, e^{-i phi}) * [[c, s], [-s, c]] makes a[p][q] real and then zero.
+	phase := cmplx.Conj(apq) / complex(abs, 0)
+	theta := (real(a.At(q, q)) - real(a.At(p, p))) / (2 * abs)
+	t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+	if theta < 0 {
+		t = -t
+	}
+
+	c := 1 / math.Sqrt(t*t+1)
+	s := t * c
+
+	rpp, rpq := complex(c, 0), complex(s, 0)
+	rqp, rqq := complex(-s, 0)*phase, complex(c, 0)*phase
+
+	n, _ := a.Dimension()
+	for k := range n {
+		// a = a * r
+		akp, akq := a.At(k, p), a.At(k, q)
+		a.Set(k, p, akp*rpp+akq*rqp)
+		a.Set(k, q, akp*rpq+akq*rqq)
+
+		// v = v * r
+		vkp, vkq := v.At(k, p), v.At(k, q)
+		v.Set(k, p, vkp*rpp+vkq*rqp)
+		v.Set(k, q, vkp*rpq+vkq*rqq)
+	}
+
+	for k := range n {
+		// a = r^dagger * a
+		apk, aqk := a.At(p, k), a.At(q, k)
+		a.Set(p, k, cmplx.Conj(rpp)*apk+cmplx.Conj(rqp)*aqk)
+		a.Set(q, k, cmplx.Conj(rpq)*apk+cmplx.Conj(rqq)*aqk)
+	}
+}
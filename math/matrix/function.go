@@ -0,0 +1,177 @@
+package matrix
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/itsubaki/q/math/epsilon"
+)
+
+var (
+	// ErrNotNormal is returned when the matrix is not normal.
+	ErrNotNormal = errors.New("not normal matrix")
+
+	// ErrNotConverged is returned when the eigenvectors do not reconstruct the matrix.
+	ErrNotConverged = errors.New("eigen decomposition not converged")
+)
+
+// Exp returns the matrix exponential of m.
+// It uses the scaling and squaring method with the [6/6] Pade approximant.
+func (m *Matrix) Exp() *Matrix {
+	n, _ := m.Dimension()
+
+	// scale m so that the infinity norm is less than 1/2
+	var norm float64
+	for i := range n {
+		var sum float64
+		for j := range n {
+			sum += cmplx.Abs(m.At(i, j))
+		}
+
+		norm = max(norm, sum)
+	}
+
+	var s int
+	if norm > 0.5 {
+		s = int(math.Ceil(math.Log2(norm / 0.5)))
+	}
+
+	a := m.Mul(complex(math.Pow(2, -float64(s)), 0))
+
+	// exp(a) = d^-1 * n, where n = sum(c_k * a**k) and d = sum(c_k * (-a)**k)
+	q := 6
+	c := 1.0
+	x := Identity(n)
+	num, den := Identity(n), Identity(n)
+	for k := 1; k <= q; k++ {
+		c = c * float64(q-k+1) / float64(k*(2*q-k+1))
+		x = a.MatMul(x)
+
+		cx := x.Mul(complex(c, 0))
+		num = num.Add(cx)
+		if k%2 == 0 {
+			den = den.Add(cx)
+			continue
+		}
+
+		den = den.Sub(cx)
+	}
+
+	out := den.Solve(num)
+	for range s {
+		out = out.MatMul(out)
+	}
+
+	return out
+}
+
+// Log returns the principal logarithm of the normal matrix m such as Hermitian and unitary matrices.
+// It panics with ErrNotNormal if m is not normal, or ErrNotConverged if the eigen decomposition fails.
+func (m *Matrix) Log(eps ...float64) *Matrix {
+	return m.Func(cmplx.Log, eps...)
+}
+
+// Sqrt returns the principal square root of the normal matrix m such as Hermitian and unitary matrices.
+// For example, X.Sqrt() is the square root of X gate.
+// It panics with ErrNotNormal if m is not normal, or ErrNotConverged if the eigen decomposition fails.
+func (m *Matrix) Sqrt(eps ...float64) *Matrix {
+	return m.Func(cmplx.Sqrt, eps...)
+}
+
+// Pow returns the principal t-th power of the normal matrix m such as Hermitian and unitary matrices.
+// For example, U.Pow(0.5) is the square root of the unitary U.
+// It panics with ErrNotNormal if m is not normal, or ErrNotConverged if the eigen decomposition fails.
+func (m *Matrix) Pow(t float64, eps ...float64) *Matrix {
+	return m.Func(func(z complex128) complex128 {
+		if z == 0 {
+			return 0
+		}
+
+		return cmplx.Pow(z, complex(t, 0))
+	}, eps...)
+}
+
+// Func returns f(m) of the normal matrix m.
+// f is applied to the eigenvalues of m, and m = v * diag(w) * v^dagger returns v * diag(f(w)) * v^dagger.
+// It panics with ErrNotNormal if m is not normal, or ErrNotConverged if the eigen decomposition fails.
+func (m *Matrix) Func(f func(z complex128) complex128, eps ...float64) *Matrix {
+	w, v := m.eigen(eps...)
+
+	n := len(w)
+	d := Zero(n, n)
+	for i := range n {
+		d.Set(i, i, f(w[i]))
+	}
+
+	return MatMul(v, d, v.Dagger())
+}
+
+// eigen returns the eigenvalues and the eigenvectors of the normal matrix m.
+// m = h + ik for the commuting Hermitian matrices h and k,
+// and the eigenvectors are the eigenvectors of h with k diagonalized in each eigenspace of h.
+// It panics with ErrNotConverged if v * diag(w) * v^dagger does not match m within eps.
+func (m *Matrix) eigen(eps ...float64) ([]complex128, *Matrix) {
+	if !m.IsSquare() || !m.MatMul(m.Dagger()).Equals(m.Dagger().MatMul(m), eps...) {
+		panic(ErrNotNormal)
+	}
+
+	h := m.Add(m.Dagger()).Mul(0.5)
+	k := m.Sub(m.Dagger()).Mul(-0.5i)
+	wh, v := h.Eigen(eps...)
+
+	// the eigenvalues of h are in ascending order, so each eigenspace is a range of the columns of v.
+	n, e := len(wh), epsilon.E13(eps...)
+	for lo := 0; lo < n; {
+		hi := lo + 1
+		for hi < n && wh[hi]-wh[lo] < e {
+			hi++
+		}
+
+		if hi-lo > 1 {
+			diagonalize(v, k, lo, hi, eps...)
+		}
+
+		lo = hi
+	}
+
+	// the eigenvalues are the diagonal elements of v^dagger * m * v
+	d := MatMul(v.Dagger(), m, v)
+
+	w := make([]complex128, n)
+	for i := range n {
+		w[i] = d.At(i, i)
+	}
+
+	diag := Zero(n, n)
+	for i := range n {
+		diag.Set(i, i, w[i])
+	}
+
+	if !MatMul(v, diag, v.Dagger()).Equals(m, eps...) {
+		panic(ErrNotConverged)
+	}
+
+	return w, v
+}
+
+// diagonalize replaces the columns [lo, hi) of v with the eigenvectors of k in their span.
+// The span is invariant under k, since k commutes with the matrix whose eigenspace it is.
+func diagonalize(v, k *Matrix, lo, hi int, eps ...float64) {
+	n, g := v.Rows, hi-lo
+
+	p := Zero(n, g)
+	for r := range n {
+		for c := range g {
+			p.Set(r, c, v.At(r, lo+c))
+		}
+	}
+
+	_, u := MatMul(p.Dagger(), k, p).Eigen(eps...)
+	pu := p.MatMul(u)
+	for r := range n {
+		for c := range g {
+			v.Set(r, lo+c, pu.At(r, c))
+		}
+	}
+}
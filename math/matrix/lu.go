@@ -0,0 +1,131 @@
+package matrix
+
+import (
+	"errors"
+	"math/cmplx"
+
+	"github.com/itsubaki/q/math/epsilon"
+)
+
+// ErrSingular is returned when the matrix is singular.
+var ErrSingular = errors.New("singular matrix")
+
+// LU returns the LU decomposition with partial pivoting p * m = l * u.
+// p is a permutation matrix, l is a lower triangular matrix with the unit diagonal,
+// and u is an upper triangular matrix. The pivots less than eps are not eliminated.
+func (m *Matrix) LU(eps ...float64) (p, l, u *Matrix) {
+	n, _ := m.Dimension()
+	p, l, u = Identity(n), Identity(n), m.Clone()
+	e := epsilon.E13(eps...)
+
+	for k := range n {
+		// the row with the largest pivot
+		pivot := k
+		for i := k + 1; i < n; i++ {
+			if cmplx.Abs(u.At(i, k)) > cmplx.Abs(u.At(pivot, k)) {
+				pivot = i
+			}
+		}
+
+		if pivot != k {
+			u = u.Swap(k, pivot)
+			p = p.Swap(k, pivot)
+			for j := range k {
+				lk, lp := l.At(k, j), l.At(pivot, j)
+				l.Set(k, j, lp)
+				l.Set(pivot, j, lk)
+			}
+		}
+
+		if cmplx.Abs(u.At(k, k)) < e {
+			continue
+		}
+
+		for i := k + 1; i < n; i++ {
+			c := u.At(i, k) / u.At(k, k)
+			l.Set(i, k, c)
+			for j := k + 1; j < n; j++ {
+				u.SubAt(i, j, c*u.At(k, j))
+			}
+
+			u.Set(i, k, 0)
+		}
+	}
+
+	return p, l, u
+}
+
+// Det returns the determinant of m.
+// It is the product of the diagonal elements of u in the LU decomposition with the sign of the permutation.
+func (m *Matrix) Det() complex128 {
+	p, _, u := m.LU()
+	n, _ := m.Dimension()
+
+	det := complex(1, 0)
+	for i := range n {
+		det *= u.At(i, i)
+	}
+
+	// the sign of the permutation is the product of (-1)**(length - 1) for each cycle
+	perm := make([]int, n)
+	for i := range n {
+		for j := range n {
+			if p.At(i, j) == 1 {
+				perm[i] = j
+			}
+		}
+	}
+
+	visited := make([]bool, n)
+	for i := range n {
+		for j := perm[i]; !visited[i] && j != i; j = perm[j] {
+			det = -det
+		}
+
+		for j := i; !visited[j]; j = perm[j] {
+			visited[j] = true
+		}
+	}
+
+	return det
+}
+
+// Solve returns x such that m * x = b.
+// b is a (rows x k) matrix, and each column of x is the solution for the column of b.
+// It panics with ErrSingular if a pivot of the LU decomposition is less than eps.
+func (m *Matrix) Solve(b *Matrix, eps ...float64) *Matrix {
+	p, l, u := m.LU(eps...)
+	n, _ := m.Dimension()
+	_, k := b.Dimension()
+	e := epsilon.E13(eps...)
+
+	for i := range n {
+		if cmplx.Abs(u.At(i, i)) < e {
+			panic(ErrSingular)
+		}
+	}
+
+	// l * y = p * b
+	y := p.MatMul(b)
+	for c := range k {
+		for i := range n {
+			for j := range i {
+				y.SubAt(i, c, l.At(i, j)*y.At(j, c))
+			}
+		}
+	}
+
+	// u * x = y
+	x := y
+	for c := range k {
+		for i := n - 1; i >= 0; i-- {
+			for j := i + 1; j < n; j++ {
+				x.SubAt(i, c, u.At(i, j)*x.At(j, c))
+			}
+
+			x.DivAt(i, c, u.At(i, i))
+		}
+	}
+
+	return x
+}
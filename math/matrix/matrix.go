@@ -147,7 +147,7 @@ func (m *Matrix) Conjugate() *Matrix {
 
 // Dagger returns conjugate transpose matrix.
 func (m *Matrix) Dagger() *Matrix {
-	out := ZeroLike(m)
+	out := Zero(m.Cols, m.Rows)
 	for i := range m.Rows {
 		for j := range m.Cols {
 			out.Set(j, i, cmplx.Conj(m.At(i, j)))
@@ -1,9 +1,13 @@
 package matrix_test
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/cmplx"
 	"testing"
 
+	"github.com/itsubaki/q/math/epsilon"
 	"github.com/itsubaki/q/math/matrix"
 )
 
@@ -353,6 +357,616 @@ func TestMatrix_Inverse(t *testing.T) {
 	}
 }
 
+func ExampleMatrix_Eigen() {
+	// Pauli Y
+	y := matrix.New(
+		[]complex128{0, -1i},
+		[]complex128{1i, 0},
+	)
+
+	w, v := y.Eigen()
+	fmt.Printf("%.4f\n", w)
+	fmt.Println(v.MatMul(matrix.New(
+		[]complex128{complex(w[0], 0), 0},
+		[]complex128{0, complex(w[1], 0)},
+	)).MatMul(v.Dagger()).Equals(y))
+
+	// Output:
+	// [-1.0000 1.0000]
+	// true
+}
+
+func TestMatrix_Eigen(t *testing.T) {
+	cases := []struct {
+		in   *matrix.Matrix
+		want []float64
+	}{
+		{
+			matrix.Identity(3),
+			[]float64{1, 1, 1},
+		},
+		{
+			matrix.New(
+				[]complex128{2, 0},
+				[]complex128{0, -3},
+			),
+			[]float64{-3, 2},
+		},
+		{
+			matrix.New(
+				[]complex128{1, 1},
+				[]complex128{1, -1},
+			),
+			[]float64{-math.Sqrt2, math.Sqrt2},
+		},
+		{
+			matrix.New(
+				[]complex128{2, 1 - 1i, 0, 3i},
+				[]complex128{1 + 1i, 0, 2, 0},
+				[]complex128{0, 2, -1, 1 + 2i},
+				[]complex128{-3i, 0, 1 - 2i, 1},
+			),
+			nil,
+		},
+		{
+			// Hamiltonian of the Heisenberg model on two qubits
+			matrix.New(
+				[]complex128{1, 0, 0, 0},
+				[]complex128{0, -1, 2, 0},
+				[]complex128{0, 2, -1, 0},
+				[]complex128{0, 0, 0, 1},
+			),
+			[]float64{-3, 1, 1, 1},
+		},
+	}
+
+	for _, c := range cases {
+		w, v := c.in.Eigen()
+		if c.want != nil {
+			for i := range c.want {
+				if math.Abs(w[i]-c.want[i]) > epsilon.E13() {
+					t.Errorf("got=%v, want=%v", w, c.want)
+				}
+			}
+		}
+
+		for i := 1; i < len(w); i++ {
+			if w[i-1] > w[i] {
+				t.Errorf("got=%v", w)
+			}
+		}
+
+		if !v.IsUnitary() {
+			t.Errorf("got=%v", v)
+		}
+
+		// m * v_i = w_i * v_i
+		d := matrix.Zero(len(w), len(w))
+		for i := range w {
+			d.Set(i, i, complex(w[i], 0))
+		}
+
+		if !c.in.MatMul(v).Equals(v.MatMul(d)) {
+			t.Errorf("got=%v, want=%v", v.MatMul(d), c.in.MatMul(v))
+		}
+	}
+}
+
+func ExampleMatrix_SVD() {
+	m := matrix.New(
+		[]complex128{3, 0},
+		[]complex128{4, 5},
+	)
+
+	_, s, _ := m.SVD()
+	fmt.Printf("%.4f\n", s)
+
+	// Output:
+	// [6.7082 2.2361]
+}
+
+func ExampleMatrix_Det() {
+	m := matrix.New(
+		[]complex128{1, 2},
+		[]complex128{3, 4},
+	)
+
+	fmt.Printf("%.4f\n", real(m.Det()))
+
+	// Output:
+	// -2.0000
+}
+
+func ExampleMatrix_Solve() {
+	m := matrix.New(
+		[]complex128{2, 1},
+		[]complex128{1, 3},
+	)
+
+	b := matrix.New(
+		[]complex128{3},
+		[]complex128{5},
+	)
+
+	x := m.Solve(b)
+	fmt.Printf("%.4f\n", x.Real())
+
+	// Output:
+	// [[0.8000] [1.4000]]
+}
+
+// decompositions are the matrices of various shapes, ranks and phases.
+var decompositions = []*matrix.Matrix{
+	matrix.Identity(3),
+	matrix.New(
+		[]complex128{1, 2},
+		[]complex128{3, 4},
+	),
+	matrix.New(
+		[]complex128{0, 1},
+		[]complex128{1, 0},
+	),
+	matrix.New(
+		[]complex128{1, 2},
+		[]complex128{2, 4},
+	),
+	matrix.New(
+		[]complex128{1 + 1i, 2, 0},
+		[]complex128{-1i, 1 - 2i, 3},
+		[]complex128{2, 0, 1i},
+		[]complex128{0, 1, 1 + 1i},
+	),
+	matrix.New(
+		[]complex128{1 + 1i, 2, 0, 1},
+		[]complex128{-1i, 1 - 2i, 3, 0},
+	),
+	matrix.New(
+		[]complex128{0, 0},
+		[]complex128{0, 0},
+	),
+	matrix.New(
+		[]complex128{2, 1 - 1i, 0, 3i},
+		[]complex128{1 + 1i, 0, 2, 0},
+		[]complex128{0, 2, -1, 1 + 2i},
+		[]complex128{-3i, 0, 1 - 2i, 1},
+	),
+}
+
+func TestMatrix_SVD(t *testing.T) {
+	for _, m := range decompositions {
+		u, s, vh := m.SVD()
+
+		rows, cols := m.Dimension()
+		k := min(rows, cols)
+		if len(s) != k {
+			t.Errorf("got=%v, want=%v", len(s), k)
+		}
+
+		for i := 1; i < len(s); i++ {
+			if s[i-1] < s[i] || s[i] < 0 {
+				t.Errorf("got=%v", s)
+			}
+		}
+
+		if !u.Dagger().MatMul(u).Equals(matrix.Identity(k)) {
+			t.Errorf("got=%v", u.Dagger().MatMul(u))
+		}
+
+		if !vh.MatMul(vh.Dagger()).Equals(matrix.Identity(k)) {
+			t.Errorf("got=%v", vh.MatMul(vh.Dagger()))
+		}
+
+		d := matrix.Zero(k, k)
+		for i := range k {
+			d.Set(i, i, complex(s[i], 0))
+		}
+
+		if got := matrix.MatMul(u, d, vh); !got.Equals(m) {
+			t.Errorf("got=%v, want=%v", got, m)
+		}
+	}
+}
+
+func TestMatrix_QR(t *testing.T) {
+	for _, m := range decompositions {
+		q, r := m.QR()
+
+		if !q.IsUnitary() {
+			t.Errorf("got=%v", q)
+		}
+
+		rows, cols := r.Dimension()
+		for i := range rows {
+			for j := range min(i, cols) {
+				if r.At(i, j) != 0 {
+					t.Errorf("got=%v", r)
+				}
+			}
+		}
+
+		if got := q.MatMul(r); !got.Equals(m) {
+			t.Errorf("got=%v, want=%v", got, m)
+		}
+	}
+}
+
+func TestMatrix_LU(t *testing.T) {
+	for _, m := range decompositions {
+		if !m.IsSquare() {
+			continue
+		}
+
+		p, l, u := m.LU()
+
+		n, _ := m.Dimension()
+		for i := range n {
+			if l.At(i, i) != 1 {
+				t.Errorf("got=%v", l)
+			}
+
+			for j := i + 1; j < n; j++ {
+				if l.At(i, j) != 0 || u.At(j, i) != 0 {
+					t.Errorf("got=%v, %v", l, u)
+				}
+			}
+		}
+
+		if !p.IsUnitary() {
+			t.Errorf("got=%v", p)
+		}
+
+		if got, want := l.MatMul(u), p.MatMul(m); !got.Equals(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestMatrix_Det(t *testing.T) {
+	cases := []struct {
+		in   *matrix.Matrix
+		want complex128
+	}{
+		{matrix.Identity(3), 1},
+		{matrix.New([]complex128{0, 1}, []complex128{1, 0}), -1},
+		{matrix.New([]complex128{1, 2}, []complex128{2, 4}), 0},
+		{matrix.New([]complex128{1i, 0}, []complex128{0, 1i}), -1},
+		{
+			// cyclic permutation
+			matrix.New(
+				[]complex128{0, 1, 0},
+				[]complex128{0, 0, 1},
+				[]complex128{1, 0, 0},
+			),
+			1,
+		},
+		{
+			matrix.New(
+				[]complex128{2, 0, 1},
+				[]complex128{1, 3, 2},
+				[]complex128{1, 1, 2},
+			),
+			6,
+		},
+		{
+			matrix.New(
+				[]complex128{0, 0, 0, 1},
+				[]complex128{0, 0, 1, 0},
+				[]complex128{0, 1, 0, 0},
+				[]complex128{1, 0, 0, 0},
+			),
+			1,
+		},
+	}
+
+	for _, c := range cases {
+		if got := c.in.Det(); cmplx.Abs(got-c.want) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
+
+func TestMatrix_Solve(t *testing.T) {
+	cases := []struct {
+		m *matrix.Matrix
+		b *matrix.Matrix
+	}{
+		{
+			matrix.New(
+				[]complex128{0, 1},
+				[]complex128{1, 0},
+			),
+			matrix.New(
+				[]complex128{1, 2},
+				[]complex128{3, 4},
+			),
+		},
+		{
+			matrix.New(
+				[]complex128{2, 1 - 1i, 0, 3i},
+				[]complex128{1 + 1i, 0, 2, 0},
+				[]complex128{0, 2, -1, 1 + 2i},
+				[]complex128{-3i, 0, 1 - 2i, 1},
+			),
+			matrix.New(
+				[]complex128{1},
+				[]complex128{1i},
+				[]complex128{0},
+				[]complex128{2 - 1i},
+			),
+		},
+	}
+
+	for _, c := range cases {
+		x := c.m.Solve(c.b)
+		if got := c.m.MatMul(x); !got.Equals(c.b) {
+			t.Errorf("got=%v, want=%v", got, c.b)
+		}
+	}
+}
+
+func TestMatrix_SolvePanics(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, matrix.ErrSingular) {
+			t.Errorf("got=%v, want=%v", err, matrix.ErrSingular)
+		}
+	}()
+
+	matrix.New(
+		[]complex128{1, 2},
+		[]complex128{2, 4},
+	).Solve(matrix.New(
+		[]complex128{1},
+		[]complex128{1},
+	))
+}
+
+func ExampleMatrix_Sqrt() {
+	x := matrix.New(
+		[]complex128{0, 1},
+		[]complex128{1, 0},
+	)
+
+	sx := x.Sqrt()
+	for _, r := range sx.Seq2() {
+		fmt.Printf("%.4f\n", r)
+	}
+
+	fmt.Println(sx.MatMul(sx).Equals(x))
+
+	// Output:
+	// [(0.5000+0.5000i) (0.5000-0.5000i)]
+	// [(0.5000-0.5000i) (0.5000+0.5000i)]
+	// true
+}
+
+func ExampleMatrix_Exp() {
+	// exp(-i * theta/2 * X) is RX(theta)
+	theta := math.Pi / 3
+	x := matrix.New(
+		[]complex128{0, 1},
+		[]complex128{1, 0},
+	)
+
+	rx := x.Mul(complex(0, -theta/2)).Exp()
+	for _, r := range rx.Seq2() {
+		fmt.Printf("%.4f\n", r)
+	}
+
+	// Output:
+	// [(0.8660+0.0000i) (0.0000-0.5000i)]
+	// [(0.0000-0.5000i) (0.8660+0.0000i)]
+}
+
+// normals are the normal matrices such as Hermitian and unitary matrices.
+var normals = []*matrix.Matrix{
+	matrix.Identity(2),
+	matrix.New(
+		[]complex128{0, 1},
+		[]complex128{1, 0},
+	),
+	matrix.New(
+		[]complex128{1, 0},
+		[]complex128{0, 1i},
+	),
+	matrix.New(
+		[]complex128{1 / math.Sqrt2, 1 / math.Sqrt2},
+		[]complex128{1 / math.Sqrt2, -1 / math.Sqrt2},
+	),
+	matrix.New(
+		[]complex128{1, 0, 0, 0},
+		[]complex128{0, 1, 0, 0},
+		[]complex128{0, 0, 0, 1},
+		[]complex128{0, 0, 1, 0},
+	),
+	matrix.New(
+		[]complex128{0, 1, 0},
+		[]complex128{0, 0, 1},
+		[]complex128{1, 0, 0},
+	),
+	matrix.New(
+		[]complex128{2, 1 - 1i},
+		[]complex128{1 + 1i, 3},
+	),
+	matrix.New(
+		[]complex128{1, 2},
+		[]complex128{-2, 1},
+	),
+}
+
+func TestMatrix_Exp(t *testing.T) {
+	cases := []struct {
+		in   *matrix.Matrix
+		want *matrix.Matrix
+	}{
+		{
+			matrix.Zero(2, 2),
+			matrix.Identity(2),
+		},
+		{
+			matrix.New(
+				[]complex128{1, 0},
+				[]complex128{0, -2i},
+			),
+			matrix.New(
+				[]complex128{complex(math.E, 0), 0},
+				[]complex128{0, cmplx.Exp(-2i)},
+			),
+		},
+		{
+			// nilpotent
+			matrix.New(
+				[]complex128{0, 1},
+				[]complex128{0, 0},
+			),
+			matrix.New(
+				[]complex128{1, 1},
+				[]complex128{0, 1},
+			),
+		},
+		{
+			matrix.New(
+				[]complex128{2, 1 - 1i, 0, 3i},
+				[]complex128{1 + 1i, 0, 2, 0},
+				[]complex128{0, 2, -1, 1 + 2i},
+				[]complex128{-3i, 0, 1 - 2i, 1},
+			),
+			matrix.New(
+				[]complex128{2, 1 - 1i, 0, 3i},
+				[]complex128{1 + 1i, 0, 2, 0},
+				[]complex128{0, 2, -1, 1 + 2i},
+				[]complex128{-3i, 0, 1 - 2i, 1},
+			).Func(cmplx.Exp),
+		},
+	}
+
+	for _, c := range cases {
+		got := c.in.Exp()
+
+		// relative to the norm of the result
+		var norm float64
+		for _, v := range c.want.Data {
+			norm = max(norm, cmplx.Abs(v))
+		}
+
+		if !got.Equals(c.want, 1e-12*max(1, norm)) {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+
+	// exp(-iHt) is unitary for the Hermitian H
+	for _, m := range normals {
+		if !m.IsHermite() {
+			continue
+		}
+
+		if u := m.Mul(-0.7i).Exp(); !u.IsUnitary(1e-12) {
+			t.Errorf("got=%v", u)
+		}
+	}
+}
+
+func TestMatrix_Log(t *testing.T) {
+	for _, m := range normals {
+		if got := m.Log().Exp(); !got.Equals(m, 1e-12) {
+			t.Errorf("got=%v, want=%v", got, m)
+		}
+	}
+}
+
+func TestMatrix_Pow(t *testing.T) {
+	for _, m := range normals {
+		if got := m.Sqrt().MatMul(m.Sqrt()); !got.Equals(m, 1e-12) {
+			t.Errorf("got=%v, want=%v", got, m)
+		}
+
+		if got := m.Pow(1); !got.Equals(m, 1e-12) {
+			t.Errorf("got=%v, want=%v", got, m)
+		}
+
+		if got := m.Pow(0.25).MatMul(m.Pow(0.75)); !got.Equals(m, 1e-12) {
+			t.Errorf("got=%v, want=%v", got, m)
+		}
+
+		if m.IsUnitary() && !m.Pow(0.3).IsUnitary(1e-12) {
+			t.Errorf("got=%v", m.Pow(0.3))
+		}
+	}
+
+	// S**0.5 is T
+	s := matrix.New(
+		[]complex128{1, 0},
+		[]complex128{0, 1i},
+	)
+
+	tg := matrix.New(
+		[]complex128{1, 0},
+		[]complex128{0, cmplx.Exp(1i * math.Pi / 4)},
+	)
+
+	if got := s.Pow(0.5); !got.Equals(tg) {
+		t.Errorf("got=%v, want=%v", got, tg)
+	}
+}
+
+func TestMatrix_FuncDegenerate(t *testing.T) {
+	h := matrix.New(
+		[]complex128{1 / math.Sqrt2, 1 / math.Sqrt2},
+		[]complex128{1 / math.Sqrt2, -1 / math.Sqrt2},
+	)
+
+	u := matrix.New(
+		[]complex128{1, 2i, 0},
+		[]complex128{-2i, 3, 1 - 1i},
+		[]complex128{0, 1 + 1i, -1},
+	).Mul(1i).Exp()
+
+	// v * diag(w) * v^dagger
+	normal := func(v *matrix.Matrix, w ...complex128) *matrix.Matrix {
+		d := matrix.Zero(len(w), len(w))
+		for i := range w {
+			d.Set(i, i, w[i])
+		}
+
+		return matrix.MatMul(v, d, v.Dagger())
+	}
+
+	cases := []struct {
+		in *matrix.Matrix
+	}{
+		// the real and imaginary parts are degenerate in h + (sqrt(2)/pi) * k
+		{normal(h, complex(math.Sqrt2/math.Pi, 0), 1i)},
+		// the real part is degenerate
+		{normal(u, 1+1i, 1-1i, 2)},
+		{normal(h.TensorProduct(h), 1i, 1, -1i, 1)},
+		{normal(u, 1, 1, 1)},
+	}
+
+	for _, c := range cases {
+		if got := c.in.Sqrt().MatMul(c.in.Sqrt()); !got.Equals(c.in, 1e-12) {
+			t.Errorf("got=%v, want=%v", got, c.in)
+		}
+
+		if got := c.in.Log().Exp(); !got.Equals(c.in, 1e-12) {
+			t.Errorf("got=%v, want=%v", got, c.in)
+		}
+	}
+}
+
+func TestMatrix_FuncPanics(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, matrix.ErrNotNormal) {
+			t.Errorf("got=%v, want=%v", err, matrix.ErrNotNormal)
+		}
+	}()
+
+	matrix.New(
+		[]complex128{1, 1},
+		[]complex128{0, 1},
+	).Sqrt()
+}
+
 func TestMatrix_Swap(t *testing.T) {
 	cases := []struct {
 		in   *matrix.Matrix
@@ -423,6 +1037,12 @@ func TestMatrix_Dagger(t *testing.T) {
 				[]complex128{4 + 5i, 6 + 7i},
 			),
 		},
+		{
+			matrix.New(
+				[]complex128{1 + 1i, 2 + 3i, 4},
+				[]complex128{4 + 5i, 6 + 7i, 8i},
+			),
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,80 @@
+package matrix
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/itsubaki/q/math/epsilon"
+)
+
+// QR returns the QR decomposition m = q * r.
+// q is a (rows x rows) unitary matrix, and r is a (rows x cols) upper triangular matrix.
+// It uses the Householder reflections, and skips the columns with the norm less than eps.
+func (m *Matrix) QR(eps ...float64) (q, r *Matrix) {
+	rows, cols := m.Dimension()
+	q, r = Identity(rows), m.Clone()
+	e := epsilon.E13(eps...)
+
+	for k := range min(rows-1, cols) {
+		// v = x - alpha * e1, where x is the k-th column below the diagonal
+		v := make([]complex128, rows)
+		var norm float64
+		for i := k; i < rows; i++ {
+			v[i] = r.At(i, k)
+			norm += math.Pow(cmplx.Abs(v[i]), 2)
+		}
+
+		norm = math.Sqrt(norm)
+		if norm < e {
+			continue
+		}
+
+		// alpha has the opposite phase of x[0] to avoid the cancellation
+		phase := complex(1, 0)
+		if cmplx.Abs(v[k]) > 0 {
+			phase = v[k] / complex(cmplx.Abs(v[k]), 0)
+		}
+
+		v[k] += phase * complex(norm, 0)
+
+		var vnorm float64
+		for i := k; i < rows; i++ {
+			vnorm += math.Pow(cmplx.Abs(v[i]), 2)
+		}
+
+		for i := k; i < rows; i++ {
+			v[i] /= complex(math.Sqrt(vnorm), 0)
+		}
+
+		// r = (I - 2 v v^dagger) r
+		for j := range cols {
+			var dot complex128
+			for i := k; i < rows; i++ {
+				dot += cmplx.Conj(v[i]) * r.At(i, j)
+			}
+
+			for i := k; i < rows; i++ {
+				r.SubAt(i, j, 2*v[i]*dot)
+			}
+		}
+
+		// q = q (I - 2 v v^dagger)
+		for i := range rows {
+			var dot complex128
+			for j := k; j < rows; j++ {
+				dot += q.At(i, j) * v[j]
+			}
+
+			for j := k; j < rows; j++ {
+				q.SubAt(i, j, 2*dot*cmplx.Conj(v[j]))
+			}
+		}
+
+		// the elements below the diagonal are zero
+		for i := k + 1; i < rows; i++ {
+			r.Set(i, k, 0)
+		}
+	}
+
+	return q, r
+}
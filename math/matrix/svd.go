@@ -0,0 +1,157 @@
+package matrix
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/itsubaki/q/math/epsilon"
+)
+
+// SVD returns the singular value decomposition m = u * diag(s) * vh.
+// u is (rows x k) and vh is (k x cols) with orthonormal columns and rows, where k = min(rows, cols).
+// s is in descending order. It uses the one-sided Jacobi method,
+// and the columns are rotated until their overlaps are less than eps relative to their norms.
+func (m *Matrix) SVD(eps ...float64) (u *Matrix, s []float64, vh *Matrix) {
+	rows, cols := m.Dimension()
+	if rows < cols {
+		// m^dagger = v * s * u^dagger
+		v, s, uh := m.Dagger().SVD(eps...)
+		return uh.Dagger(), s, v.Dagger()
+	}
+
+	// w = m * v, where the columns of w are orthogonal
+	w, v := m.Clone(), Identity(cols)
+	e := epsilon.E13(eps...)
+
+	for range 64 {
+		var rotated bool
+		for p := range cols - 1 {
+			for q := p + 1; q < cols; q++ {
+				var alpha, beta float64
+				var gamma complex128
+				for i := range rows {
+					wp, wq := w.At(i, p), w.At(i, q)
+					alpha += real(wp)*real(wp) + imag(wp)*imag(wp)
+					beta += real(wq)*real(wq) + imag(wq)*imag(wq)
+					gamma += cmplx.Conj(wp) * wq
+				}
+
+				g := cmplx.Abs(gamma)
+				if g < e*math.Sqrt(alpha*beta) || g == 0 {
+					continue
+				}
+
+				rotated = true
+				zeta := (beta - alpha) / (2 * g)
+				t := 1 / (math.Abs(zeta) + math.Sqrt(1+zeta*zeta))
+				if zeta < 0 {
+					t = -t
+				}
+
+				c := 1 / math.Sqrt(1+t*t)
+				sn, ph := complex(c*t, 0), cmplx.Conj(gamma)/complex(g, 0)
+				givens(w, p, q, complex(c, 0), sn, ph)
+				givens(v, p, q, complex(c, 0), sn, ph)
+			}
+		}
+
+		if !rotated {
+			break
+		}
+	}
+
+	// sort the columns by the singular values
+	sigma := make([]float64, cols)
+	for j := range cols {
+		var sum float64
+		for i := range rows {
+			sum += math.Pow(cmplx.Abs(w.At(i, j)), 2)
+		}
+
+		sigma[j] = math.Sqrt(sum)
+	}
+
+	order := make([]int, cols)
+	for j := range cols {
+		order[j] = j
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return sigma[order[i]] > sigma[order[j]] })
+
+	u, s, vh = Zero(rows, cols), make([]float64, cols), Zero(cols, cols)
+	for k, j := range order {
+		s[k] = sigma[j]
+		for i := range cols {
+			vh.Set(k, i, cmplx.Conj(v.At(i, j)))
+		}
+
+		if s[k] < e {
+			continue
+		}
+
+		for i := range rows {
+			u.Set(i, k, w.At(i, j)/complex(s[k], 0))
+		}
+	}
+
+	// the columns of u for the zero singular values
+	for k := range cols {
+		if s[k] < e {
+			complete(u, k, e)
+		}
+	}
+
+	return u, s, vh
+}
+
+// givens applies the rotation to the p-th and q-th columns of m.
+// The q-th column is multiplied by the phase before the rotation.
+func givens(m *Matrix, p, q int, c, s, phase complex128) {
+	rows, _ := m.Dimension()
+	for i := range rows {
+		mp, mq := m.At(i, p), m.At(i, q)*phase
+		m.Set(i, p, c*mp-s*mq)
+		m.Set(i, q, s*mp+c*mq)
+	}
+}
+
+// complete sets the k-th column of m to a unit vector orthogonal to the other nonzero columns.
+// It orthogonalizes the standard basis vectors by the Gram-Schmidt process.
+func complete(m *Matrix, k int, eps float64) {
+	rows, cols := m.Dimension()
+	for b := range rows {
+		col := make([]complex128, rows)
+		col[b] = 1
+
+		for j := range cols {
+			if j == k {
+				continue
+			}
+
+			var dot complex128
+			for i := range rows {
+				dot += cmplx.Conj(m.At(i, j)) * col[i]
+			}
+
+			for i := range rows {
+				col[i] -= dot * m.At(i, j)
+			}
+		}
+
+		var norm float64
+		for i := range rows {
+			norm += math.Pow(cmplx.Abs(col[i]), 2)
+		}
+
+		if math.Sqrt(norm) < math.Sqrt(eps) {
+			continue
+		}
+
+		for i := range rows {
+			m.Set(i, k, col[i]/complex(math.Sqrt(norm), 0))
+		}
+
+		return
+	}
+}
@@ -210,3 +210,20 @@ func TensorProductN(v *Vector, n ...int) *Vector {
 
 	return out
 }
+
+// Eigen returns the eigenvalues and the orthonormal eigenvectors of the Hermitian matrix m.
+// The eigenvalues are real and in ascending order, and the i-th vector corresponds to the i-th eigenvalue.
+func Eigen(m *matrix.Matrix, eps ...float64) ([]float64, []*Vector) {
+	w, v := m.Eigen(eps...)
+
+	rows, cols := v.Dimension()
+	out := make([]*Vector, cols)
+	for j := range cols {
+		out[j] = Zero(rows)
+		for i := range rows {
+			out[j].Data[i] = v.At(i, j)
+		}
+	}
+
+	return w, out
+}
@@ -275,3 +275,20 @@ func TestEquals(t *testing.T) {
 		}
 	}
 }
+
+func ExampleEigen() {
+	// Pauli X
+	x := matrix.New(
+		[]complex128{0, 1},
+		[]complex128{1, 0},
+	)
+
+	w, v := vector.Eigen(x)
+	for i := range w {
+		fmt.Printf("%.4f: %.4f\n", w[i], v[i].Real())
+	}
+
+	// Output:
+	// -1.0000: [0.7071 -0.7071]
+	// 1.0000: [0.7071 0.7071]
+}
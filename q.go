@@ -1,10 +1,16 @@
 package q
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/itsubaki/q/math/matrix"
 	"github.com/itsubaki/q/math/number"
 	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/density"
 	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
 	"github.com/itsubaki/q/quantum/qubit"
 )
 
@@ -33,28 +39,56 @@ func Theta(k int) float64 {
 
 // Q is a quantum computation simulator.
 type Q struct {
-	qb   *qubit.Qubit
-	Rand func() float64
+	b       Backend
+	alloc   func(q *Q) Backend
+	noise   *noise.Model
+	workers int
+	Rand    func() float64
+}
+
+// Option is an option of a quantum computation simulator.
+type Option func(q *Q)
+
+// WithWorkers sets the number of goroutines used to update the amplitudes.
+// The results are identical regardless of the number of workers.
+func WithWorkers(n int) Option {
+	return func(q *Q) {
+		q.workers = n
+	}
+}
+
+// WithNoise sets the noise model.
+// The channels are applied after each gate, and the readout errors flip the measured results.
+// The density matrix backend applies the channels exactly,
+// and the other backends apply one of the Kraus operators chosen at random as a quantum trajectory.
+func WithNoise(m *noise.Model) Option {
+	return func(q *Q) {
+		q.noise = m
+	}
 }
 
 // New returns a new quantum computation simulator.
-func New() *Q {
-	return &Q{
-		qb:   nil,
-		Rand: rand.Float64,
+func New(opts ...Option) *Q {
+	q := &Q{
+		b:     nil,
+		alloc: newStateVector,
+		Rand:  rand.Float64,
+	}
+
+	for _, opt := range opts {
+		opt(q)
 	}
+
+	return q
 }
 
 // New returns a new qubit.
 func (q *Q) New(v ...complex128) Qubit {
-	if q.qb == nil {
-		q.qb = qubit.New(v...)
-		q.qb.Rand = q.Rand
-		return Qubit(0)
+	if q.b == nil {
+		q.b = q.alloc(q)
 	}
 
-	q.qb.TensorProduct(qubit.New(v...))
-	return Qubit(q.NumQubits() - 1)
+	return Qubit(q.b.Add(v...))
 }
 
 // Zero returns a qubit in the zero state.
@@ -95,118 +129,212 @@ func (q *Q) ZeroLog2(N int) []Qubit {
 
 // NumQubits returns the number of qubits.
 func (q *Q) NumQubits() int {
-	return q.qb.NumQubits()
+	return q.b.NumQubits()
 }
 
 // Amplitude returns the amplitude of qubits.
 func (q *Q) Amplitude() []complex128 {
-	return q.qb.Amplitude()
+	return q.b.Amplitude()
 }
 
 // Probability returns the probability of qubits.
 func (q *Q) Probability() []float64 {
-	return q.qb.Probability()
+	return q.b.Probability()
+}
+
+// Marginal returns the marginal probability distribution of qubits.
+// The unlisted qubits are summed out, and qb[0] is the most significant bit.
+// If no qubit is given, it returns the probability of all qubits.
+func (q *Q) Marginal(qb ...Qubit) []float64 {
+	return q.b.Marginal(Index(qb...)...)
+}
+
+// Sample returns the histogram of the outcomes of measuring qubits shots times.
+// The key is the binary string of the outcome, and qb[0] is the most significant bit.
+// If no qubit is given, it samples all qubits.
+// The state is not collapsed, and the outcomes are drawn using Rand.
+func (q *Q) Sample(shots int, qb ...Qubit) map[string]int {
+	n := len(qb)
+	if n < 1 {
+		n = q.NumQubits()
+	}
+
+	hist := make(map[string]int)
+	for k, v := range q.SampleInt(shots, qb...) {
+		hist[fmt.Sprintf("%0*b", n, k)] = v
+	}
+
+	return hist
+}
+
+// SampleInt returns the histogram of the outcomes of measuring qubits shots times.
+// The key is the integer of the outcome, and qb[0] is the most significant bit.
+// If no qubit is given, it samples all qubits.
+// The state is not collapsed, and the outcomes are drawn using Rand.
+// The readout errors of the noise model flip the bits of the outcomes.
+func (q *Q) SampleInt(shots int, qb ...Qubit) map[int]int {
+	// cumulative distribution of the outcomes of qb
+	cdf := q.Marginal(qb...)
+	for i := 1; i < len(cdf); i++ {
+		cdf[i] += cdf[i-1]
+	}
+
+	index := Index(qb...)
+	if len(index) < 1 {
+		index = make([]int, q.NumQubits())
+		for i := range index {
+			index[i] = i
+		}
+	}
+
+	hist := make(map[int]int)
+	for range shots {
+		r := q.Rand() * cdf[len(cdf)-1]
+		k := sort.Search(len(cdf), func(i int) bool { return cdf[i] > r })
+		hist[q.readout(min(k, len(cdf)-1), index)]++
+	}
+
+	return hist
 }
 
 // Reset sets qubits to the zero state.
 func (q *Q) Reset(qb ...Qubit) {
 	for i := range qb {
-		if q.Measure(qb[i]).IsOne() {
-			q.X(qb[i])
-		}
+		q.b.Reset(qb[i].Index())
 	}
 }
 
 // U applies U gate.
 func (q *Q) U(theta, phi, lambda float64, qb ...Qubit) *Q {
-	return q.Apply(gate.U(theta, phi, lambda), qb...)
+	return q.apply("U", gate.U(theta, phi, lambda), qb...)
 }
 
 // I applies I gate.
 func (q *Q) I(qb ...Qubit) *Q {
-	return q.Apply(gate.I(), qb...)
+	return q.apply("I", gate.I(), qb...)
 }
 
 // X applies X gate.
 func (q *Q) X(qb ...Qubit) *Q {
-	return q.Apply(gate.X(), qb...)
+	return q.apply("X", gate.X(), qb...)
 }
 
 // Y applies Y gate.
 func (q *Q) Y(qb ...Qubit) *Q {
-	return q.Apply(gate.Y(), qb...)
+	return q.apply("Y", gate.Y(), qb...)
 }
 
 // Z applies Z gate.
 func (q *Q) Z(qb ...Qubit) *Q {
-	return q.Apply(gate.Z(), qb...)
+	return q.apply("Z", gate.Z(), qb...)
 }
 
 // H applies H gate.
 func (q *Q) H(qb ...Qubit) *Q {
-	return q.Apply(gate.H(), qb...)
+	return q.apply("H", gate.H(), qb...)
 }
 
 // S applies S gate.
 func (q *Q) S(qb ...Qubit) *Q {
-	return q.Apply(gate.S(), qb...)
+	return q.apply("S", gate.S(), qb...)
 }
 
 // T applies T gate.
 func (q *Q) T(qb ...Qubit) *Q {
-	return q.Apply(gate.T(), qb...)
+	return q.apply("T", gate.T(), qb...)
 }
 
 // R applies R gate with theta.
 func (q *Q) R(theta float64, qb ...Qubit) *Q {
-	return q.Apply(gate.R(theta), qb...)
+	return q.apply("R", gate.R(theta), qb...)
 }
 
 // RX applies RX gate with theta.
 func (q *Q) RX(theta float64, qb ...Qubit) *Q {
-	return q.Apply(gate.RX(theta), qb...)
+	return q.apply("RX", gate.RX(theta), qb...)
 }
 
 // RY applies RY gate with theta.
 func (q *Q) RY(theta float64, qb ...Qubit) *Q {
-	return q.Apply(gate.RY(theta), qb...)
+	return q.apply("RY", gate.RY(theta), qb...)
 }
 
 // RZ applies RZ gate with theta.
 func (q *Q) RZ(theta float64, qb ...Qubit) *Q {
-	return q.Apply(gate.RZ(theta), qb...)
+	return q.apply("RZ", gate.RZ(theta), qb...)
 }
 
 // Apply applies matrix to qubits.
 func (q *Q) Apply(m *matrix.Matrix, qb ...Qubit) *Q {
+	return q.apply("Apply", m, qb...)
+}
+
+// apply applies the gate of the name to each qubit, or to all qubits if no qubit is given.
+func (q *Q) apply(name string, m *matrix.Matrix, qb ...Qubit) *Q {
 	if len(qb) < 1 {
-		q.qb.Apply(m)
+		all := make([]int, q.NumQubits())
+		for i := range all {
+			all[i] = i
+		}
+
+		q.b.Apply(m, nil, "", all...)
+		q.noisy(name, all...)
 		return q
 	}
 
-	n := q.NumQubits()
-	g := gate.TensorProduct(m, n, Index(qb...))
-	q.qb.Apply(g)
+	for i := range qb {
+		q.b.Apply(m, nil, "", qb[i].Index())
+		q.noisy(name, qb[i].Index())
+	}
+
+	return q
+}
+
+// ApplyOn applies the (2**k x 2**k) unitary u to the k qubits in the given order.
+// qb[0] corresponds to the most significant bit of u.
+// For example, ApplyOn(gate.CNOT(2, 0, 1), q3, q0) applies CNOT with control q3 and target q0.
+func (q *Q) ApplyOn(u *matrix.Matrix, qb ...Qubit) *Q {
+	q.b.Apply(u, nil, "", Index(qb...)...)
+	q.noisy("ApplyOn", Index(qb...)...)
+	return q
+}
+
+// ApplyChannel applies the channel to the qubits in the given order.
+// The density matrix backend applies the channel exactly, and the other backends apply
+// one of the Kraus operators chosen with its probability using Rand as a quantum trajectory.
+func (q *Q) ApplyChannel(c *noise.Channel, qb ...Qubit) *Q {
+	q.b.ApplyKraus(c.Kraus, Index(qb...)...)
 	return q
 }
 
-func (q *Q) Controlled(m *matrix.Matrix, control []Qubit, target Qubit) *Q {
-	n := q.NumQubits()
-	g := gate.Controlled(m, n, Index(control...), target.Index())
-	q.qb.Apply(g)
+// Controlled applies controlled-m gate.
+// m is a (2**k x 2**k) unitary matrix acting on the k target qubits.
+func (q *Q) Controlled(m *matrix.Matrix, control []Qubit, target ...Qubit) *Q {
+	return q.controlled("Controlled", m, control, strings.Repeat("1", len(control)), target...)
+}
+
+// ControlledState applies controlled-m gate with the control state.
+// state is a binary string such as "10", and state[i] is the state of control[i] for which m is applied.
+// For example, the state "0" applies m if the control qubit is |0>.
+func (q *Q) ControlledState(m *matrix.Matrix, control []Qubit, state string, target ...Qubit) *Q {
+	return q.controlled("ControlledState", m, control, state, target...)
+}
+
+// controlled applies the controlled gate of the name.
+func (q *Q) controlled(name string, m *matrix.Matrix, control []Qubit, state string, target ...Qubit) *Q {
+	q.b.Apply(m, Index(control...), state, Index(target...)...)
+	q.noisy(name, append(Index(control...), Index(target...)...)...)
 	return q
 }
 
+// C applies controlled-m gate.
 func (q *Q) C(m *matrix.Matrix, control, target Qubit) *Q {
 	return q.Controlled(m, []Qubit{control}, target)
 }
 
 // ControlledNot applies CNOT gate.
 func (q *Q) ControlledNot(control []Qubit, target Qubit) *Q {
-	n := q.NumQubits()
-	g := gate.ControlledNot(n, Index(control...), target.Index())
-	q.qb.Apply(g)
-	return q
+	return q.controlled("ControlledNot", gate.X(), control, strings.Repeat("1", len(control)), target)
 }
 
 // CNOT applies CNOT gate.
@@ -231,10 +359,7 @@ func (q *Q) Toffoli(control0, control1, target Qubit) *Q {
 
 // ControlledZ applies Controlled-Z gate.
 func (q *Q) ControlledZ(control []Qubit, target Qubit) *Q {
-	n := q.NumQubits()
-	g := gate.ControlledZ(n, Index(control...), target.Index())
-	q.qb.Apply(g)
-	return q
+	return q.controlled("ControlledZ", gate.Z(), control, strings.Repeat("1", len(control)), target)
 }
 
 func (q *Q) CZ(control, target Qubit) *Q {
@@ -246,10 +371,7 @@ func (q *Q) CCZ(control0, control1, target Qubit) *Q {
 }
 
 func (q *Q) ControlledR(theta float64, control []Qubit, target Qubit) *Q {
-	n := q.NumQubits()
-	g := gate.ControlledR(theta, n, Index(control...), target.Index())
-	q.qb.Apply(g)
-	return q
+	return q.controlled("ControlledR", gate.R(theta), control, strings.Repeat("1", len(control)), target)
 }
 
 // CR applies Controlled-R gate.
@@ -258,10 +380,19 @@ func (q *Q) CR(theta float64, control, target Qubit) *Q {
 }
 
 // ControlledModExp2 applies Controlled-ModExp2 gate.
+// a must be coprime to N, otherwise the map is not a permutation and the state vector panics.
 func (q *Q) ControlledModExp2(a, j, N int, control Qubit, target []Qubit) *Q {
-	n := q.NumQubits()
-	g := gate.ControlledModExp2(n, a, j, N, control.Index(), Index(target...))
-	q.qb.Apply(g)
+	a2jmodN := number.ModExp2(a, j, N)
+	f := func(k int) int {
+		if k > N-1 {
+			return k
+		}
+
+		return a2jmodN * k % N
+	}
+
+	q.b.Permute(f, []int{control.Index()}, Index(target...)...)
+	q.noisy("ControlledModExp2", append([]int{control.Index()}, Index(target...)...)...)
 	return q
 }
 
@@ -285,23 +416,29 @@ func (q *Q) Cond(condition bool, m *matrix.Matrix, qb ...Qubit) *Q {
 
 // CondX applies X gate if condition is true.
 func (q *Q) CondX(condition bool, qb ...Qubit) *Q {
-	return q.Cond(condition, gate.X(), qb...)
+	if condition {
+		return q.X(qb...)
+	}
+
+	return q
 }
 
 // CondZ applies Z gate if condition is true.
 func (q *Q) CondZ(condition bool, qb ...Qubit) *Q {
-	return q.Cond(condition, gate.Z(), qb...)
+	if condition {
+		return q.Z(qb...)
+	}
+
+	return q
 }
 
 // Swap applies Swap gate.
 func (q *Q) Swap(qb ...Qubit) *Q {
-	n := q.NumQubits()
 	l := len(qb)
-
 	for i := range l / 2 {
 		q0, q1 := qb[i], qb[(l-1)-i]
-		g := gate.Swap(n, q0.Index(), q1.Index())
-		q.qb.Apply(g)
+		q.b.Swap(q0.Index(), q1.Index())
+		q.noisy("Swap", q0.Index(), q1.Index())
 	}
 
 	return q
@@ -360,7 +497,7 @@ func (q *Q) Measure(qb ...Qubit) *qubit.Qubit {
 		n := q.NumQubits()
 		m := make([]*qubit.Qubit, n)
 		for i := range n {
-			m[i] = q.qb.Measure(i)
+			m[i] = q.measure(i)
 		}
 
 		return qubit.TensorProduct(m...)
@@ -368,40 +505,124 @@ func (q *Q) Measure(qb ...Qubit) *qubit.Qubit {
 
 	m := make([]*qubit.Qubit, len(qb))
 	for i := range qb {
-		m[i] = q.qb.Measure(qb[i].Index())
+		m[i] = q.measure(qb[i].Index())
 	}
 
 	return qubit.TensorProduct(m...)
 }
 
+// measure returns the measured state of the qubit with the readout error of the noise model.
+// The readout error flips the result, and does not change the collapsed state.
+func (q *Q) measure(index int) *qubit.Qubit {
+	if q.noise == nil {
+		return q.b.Measure(index)
+	}
+
+	q.noisy(noise.Measure, index)
+	if q.b.Measure(index).IsOne() {
+		if q.readout(1, []int{index}) == 1 {
+			return qubit.One()
+		}
+
+		return qubit.Zero()
+	}
+
+	if q.readout(0, []int{index}) == 1 {
+		return qubit.One()
+	}
+
+	return qubit.Zero()
+}
+
+// readout returns the outcome k of the qubits at the index with the bits flipped by the readout errors.
+// index[0] is the most significant bit of k.
+func (q *Q) readout(k int, index []int) int {
+	if q.noise == nil {
+		return k
+	}
+
+	n := len(index)
+	for j, i := range index {
+		b := 1 << (n - 1 - j)
+
+		p, _ := q.noise.Readout(i)
+		if k&b != 0 {
+			_, p = q.noise.Readout(i)
+		}
+
+		if p > 0 && q.Rand() < p {
+			k ^= b
+		}
+	}
+
+	return k
+}
+
+// noisy applies the channels of the noise model after the gate of the name on the qubits.
+func (q *Q) noisy(name string, index ...int) {
+	if q.noise == nil {
+		return
+	}
+
+	for _, op := range q.noise.Gate(name, index...) {
+		q.b.ApplyKraus(op.Channel.Kraus, op.Qubit...)
+	}
+}
+
 // Clone returns a clone of a quantum computation simulator.
 func (q *Q) Clone() *Q {
-	if q.qb == nil {
+	if q.b == nil {
 		return &Q{
-			qb:   nil,
-			Rand: q.Rand,
+			b:       nil,
+			alloc:   q.alloc,
+			noise:   q.noise,
+			workers: q.workers,
+			Rand:    q.Rand,
 		}
 	}
 
 	return &Q{
-		qb:   q.qb.Clone(),
-		Rand: q.Rand,
+		b:       q.b.Clone(),
+		alloc:   q.alloc,
+		noise:   q.noise,
+		workers: q.workers,
+		Rand:    q.Rand,
 	}
 }
 
+// Backend returns the backend of the quantum state.
+func (q *Q) Backend() Backend {
+	return q.b
+}
+
 // Underlying returns the internal qubit.
+// It returns nil if the backend is not a state vector.
 func (q *Q) Underlying() *qubit.Qubit {
-	return q.qb
+	if s, ok := q.b.(*statevector); ok {
+		return s.qb
+	}
+
+	return nil
+}
+
+// Density returns the internal density matrix.
+// It returns nil if the backend is not a density matrix.
+func (q *Q) Density() *density.Matrix {
+	if d, ok := q.b.(*densityMatrix); ok {
+		return d.m
+	}
+
+	return nil
 }
 
 // String returns the string representation of a quantum computation simulator.
 func (q *Q) String() string {
-	return q.qb.String()
+	return q.b.String()
 }
 
 // State returns the state of qubits.
 func (q *Q) State(reg ...any) []qubit.State {
-	if q.qb == nil {
+	if q.b == nil {
 		return nil
 	}
 
@@ -415,5 +636,5 @@ func (q *Q) State(reg ...any) []qubit.State {
 		}
 	}
 
-	return q.qb.State(idx...)
+	return q.b.State(idx...)
 }
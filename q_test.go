@@ -1,10 +1,12 @@
 package q_test
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/cmplx"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/itsubaki/q"
@@ -12,8 +14,12 @@ import (
 	"github.com/itsubaki/q/math/matrix"
 	"github.com/itsubaki/q/math/number"
 	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/math/vector"
 	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/mps"
+	"github.com/itsubaki/q/quantum/noise"
 	"github.com/itsubaki/q/quantum/qubit"
+	"github.com/itsubaki/q/quantum/stabilizer"
 )
 
 func ExampleQ_Zero() {
@@ -159,6 +165,163 @@ func ExampleQ_Probability() {
 	// 0.5000
 }
 
+func ExampleQ_Sample() {
+	qsim := q.New()
+	qsim.Rand = rand.Const()
+
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+	q2 := qsim.Zero()
+
+	qsim.H(q0)
+	qsim.CNOT(q0, q1)
+
+	fmt.Println(qsim.Sample(1000))
+	fmt.Println(qsim.Sample(1000, q2, q0))
+
+	// Output:
+	// map[000:494 110:506]
+	// map[00:487 01:513]
+}
+
+func ExampleQ_SampleInt() {
+	qsim := q.New()
+	qsim.Rand = rand.Const()
+
+	r := qsim.Zeros(3)
+	qsim.H(r...)
+
+	fmt.Println(qsim.SampleInt(800, r...))
+
+	// Output:
+	// map[0:84 1:108 2:102 3:90 4:127 5:82 6:103 7:104]
+}
+
+func ExampleQ_Marginal() {
+	qsim := q.New()
+
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+	q2 := qsim.Zero()
+	qsim.H(q0).CNOT(q0, q1).RY(math.Pi/3, q2)
+
+	for _, p := range qsim.Marginal(q2, q0) {
+		fmt.Printf("%.4f\n", p)
+	}
+
+	// Output:
+	// 0.3750
+	// 0.3750
+	// 0.1250
+	// 0.1250
+}
+
+func TestMarginal(t *testing.T) {
+	cases := []struct {
+		qb   []q.Qubit
+		want []float64
+	}{
+		{nil, []float64{0.1, 0, 0, 0.1, 0.4, 0, 0, 0.4}},
+		{[]q.Qubit{0}, []float64{0.2, 0.8}},
+		{[]q.Qubit{2}, []float64{0.5, 0.5}},
+		{[]q.Qubit{2, 0}, []float64{0.1, 0.4, 0.1, 0.4}},
+		{[]q.Qubit{1, 2}, []float64{0.5, 0, 0, 0.5}},
+		{[]q.Qubit{2, 1, 0}, []float64{0.1, 0.4, 0, 0, 0, 0, 0.1, 0.4}},
+	}
+
+	for _, c := range cases {
+		qsim := q.New()
+		qsim.New(1, 2) // (0.2, 0.8)
+		q1 := qsim.Zero()
+		q2 := qsim.Zero()
+		qsim.H(q1).CNOT(q1, q2)
+
+		got := qsim.Marginal(c.qb...)
+		if len(got) != len(c.want) {
+			t.Errorf("qb=%v, got=%v, want=%v", c.qb, got, c.want)
+			continue
+		}
+
+		for i := range c.want {
+			if math.Abs(got[i]-c.want[i]) > epsilon.E13() {
+				t.Errorf("qb=%v, got=%v, want=%v", c.qb, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	cases := []struct {
+		qb    []q.Qubit
+		shots int
+		want  map[string]float64
+	}{
+		{nil, 10000, map[string]float64{"000": 0.1, "011": 0.1, "100": 0.4, "111": 0.4}},
+		{[]q.Qubit{0}, 10000, map[string]float64{"0": 0.2, "1": 0.8}},
+		{[]q.Qubit{2, 0}, 10000, map[string]float64{"00": 0.1, "10": 0.1, "01": 0.4, "11": 0.4}},
+		{[]q.Qubit{1, 2}, 10000, map[string]float64{"00": 0.5, "11": 0.5}},
+	}
+
+	for _, c := range cases {
+		qsim := q.New()
+		qsim.Rand = rand.Const(1)
+
+		qsim.New(1, 2) // (0.2, 0.8)
+		q1 := qsim.Zero()
+		q2 := qsim.Zero()
+		qsim.H(q1).CNOT(q1, q2)
+
+		amp := qsim.Amplitude()
+		got := qsim.Sample(c.shots, c.qb...)
+
+		var sum int
+		for k, v := range got {
+			sum += v
+			if _, ok := c.want[k]; !ok {
+				t.Errorf("qb=%v, unexpected outcome=%v", c.qb, k)
+			}
+		}
+
+		if sum != c.shots {
+			t.Errorf("qb=%v, got=%v, want=%v", c.qb, sum, c.shots)
+		}
+
+		for k, p := range c.want {
+			if math.Abs(float64(got[k])/float64(c.shots)-p) > 0.02 {
+				t.Errorf("qb=%v, outcome=%v, got=%v, want=%v", c.qb, k, got[k], p)
+			}
+		}
+
+		// the state is not collapsed
+		for i, a := range qsim.Amplitude() {
+			if a != amp[i] {
+				t.Errorf("got=%v, want=%v", qsim.Amplitude(), amp)
+				break
+			}
+		}
+	}
+}
+
+func TestSampleReproducible(t *testing.T) {
+	sample := func() map[int]int {
+		qsim := q.New()
+		qsim.Rand = rand.Const(1, 2)
+
+		r := qsim.Zeros(4)
+		qsim.H(r...).RY(0.3, r[0]).CNOT(r[0], r[3])
+
+		return qsim.SampleInt(100, r...)
+	}
+
+	want := sample()
+	for range 3 {
+		if got := sample(); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
 func ExampleQ_Measure() {
 	qsim := q.New()
 	qsim.Rand = rand.Const()
@@ -216,6 +379,44 @@ func ExampleQ_Apply() {
 	// [11][  3]( 0.7071 0.0000i): 0.5000
 }
 
+func ExampleQ_Apply_sqrtX() {
+	qsim := q.New()
+
+	q0 := qsim.Zero()
+	sx := gate.X().Sqrt()
+
+	qsim.Apply(sx, q0)
+	qsim.Apply(sx, q0)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [1][  1]( 1.0000 0.0000i): 1.0000
+}
+
+func ExampleQ_Apply_hamiltonian() {
+	qsim := q.New()
+
+	q0 := qsim.Zero()
+	q1 := qsim.One()
+
+	// exp(-iHt) for the exchange interaction H = (XX + YY)/2 at t = pi/4 splits |01> into |01> and |10>
+	h := gate.X().TensorProduct(gate.X()).Add(gate.Y().TensorProduct(gate.Y())).Mul(0.5)
+	u := h.Mul(complex(0, -math.Pi/4)).Exp()
+
+	qsim.ApplyOn(u, q0, q1)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [01][  1]( 0.7071 0.0000i): 0.5000
+	// [10][  2]( 0.0000-0.7071i): 0.5000
+}
+
 func ExampleQ_U() {
 	qsim := q.New()
 
@@ -507,6 +708,143 @@ func ExampleQ_C() {
 	// [11][  3]( 0.7071 0.0000i): 0.5000
 }
 
+func ExampleQ_ApplyOn() {
+	qsim := q.New()
+
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+	q2 := qsim.Zero()
+
+	qsim.H(q2)
+	qsim.ApplyOn(gate.CNOT(2, 0, 1), q2, q0)
+
+	for _, s := range qsim.State(q0, q1, q2) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [0 0 0][  0   0   0]( 0.7071 0.0000i): 0.5000
+	// [1 0 1][  1   0   1]( 0.7071 0.0000i): 0.5000
+}
+
+func ExampleQ_Controlled() {
+	qsim := q.New()
+
+	q0 := qsim.Zero()
+	q1 := qsim.One()
+	q2 := qsim.Zero()
+
+	// controlled-swap
+	qsim.H(q0)
+	qsim.Controlled(gate.Swap(2, 0, 1), []q.Qubit{q0}, q1, q2)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [010][  2]( 0.7071 0.0000i): 0.5000
+	// [101][  5]( 0.7071 0.0000i): 0.5000
+}
+
+func ExampleQ_ControlledState() {
+	qsim := q.New()
+
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+	q2 := qsim.Zero()
+
+	// oracle for |01>
+	qsim.H(q0, q1)
+	qsim.ControlledState(gate.X(), []q.Qubit{q0, q1}, "01", q2)
+
+	for _, s := range qsim.State([]q.Qubit{q0, q1}, q2) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [00 0][  0   0]( 0.5000 0.0000i): 0.2500
+	// [01 1][  1   1]( 0.5000 0.0000i): 0.2500
+	// [10 0][  2   0]( 0.5000 0.0000i): 0.2500
+	// [11 0][  3   0]( 0.5000 0.0000i): 0.2500
+}
+
+func TestApplyOn(t *testing.T) {
+	fsim := func(theta, phi float64) *matrix.Matrix {
+		c, s := complex(math.Cos(theta), 0), complex(0, -math.Sin(theta))
+		return gate.New(
+			[]complex128{1, 0, 0, 0},
+			[]complex128{0, c, s, 0},
+			[]complex128{0, s, c, 0},
+			[]complex128{0, 0, 0, cmplx.Exp(complex(0, -phi))},
+		)
+	}
+
+	cases := []struct {
+		u    *matrix.Matrix
+		qb   []q.Qubit
+		swap [][]int
+	}{
+		{fsim(0.3, 0.5), []q.Qubit{2, 3}, [][]int{}},
+		{fsim(0.3, 0.5), []q.Qubit{3, 2}, [][]int{{2, 3}}},
+		{fsim(0.3, 0.5), []q.Qubit{3, 0}, [][]int{{0, 3}, {0, 2}}},
+		{fsim(0.3, 0.5), []q.Qubit{1, 2}, [][]int{{1, 3}, {2, 3}}},
+	}
+
+	for _, c := range cases {
+		in := q.New()
+		qb := in.Zeros(4)
+		in.H(qb...).T(qb[1], qb[2]).RX(0.4, qb[0])
+
+		got := in.Clone()
+		got.ApplyOn(c.u, c.qb...)
+
+		// move the qubits to the last two positions, apply u and move them back.
+		want := in.Clone()
+		for _, s := range c.swap {
+			want.Swap(q.Qubit(s[0]), q.Qubit(s[1]))
+		}
+
+		want.Apply(matrix.TensorProduct(gate.I(2), c.u))
+		for i := len(c.swap) - 1; i > -1; i-- {
+			want.Swap(q.Qubit(c.swap[i][0]), q.Qubit(c.swap[i][1]))
+		}
+
+		for i, a := range got.Amplitude() {
+			if cmplx.Abs(a-want.Amplitude()[i]) > epsilon.E13() {
+				t.Errorf("qb=%v: got=%v, want=%v", c.qb, got.Amplitude(), want.Amplitude())
+				break
+			}
+		}
+	}
+}
+
+func TestApplyOnPanic(t *testing.T) {
+	cases := []struct {
+		u  *matrix.Matrix
+		qb []q.Qubit
+	}{
+		{gate.H(), []q.Qubit{0, 1}},
+		{gate.CNOT(2, 0, 1), []q.Qubit{0}},
+		{gate.CNOT(2, 0, 1), []q.Qubit{1, 1}},
+		{gate.CNOT(2, 0, 1), []q.Qubit{0, 2}},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if rec := recover(); rec == nil {
+					t.Errorf("no panic: u=%v, qb=%v", c.u, c.qb)
+				}
+			}()
+
+			qsim := q.New()
+			qsim.Zeros(2)
+			qsim.ApplyOn(c.u, c.qb...)
+		}()
+	}
+}
+
 func ExampleQ_CondX() {
 	qsim := q.New()
 
@@ -1290,6 +1628,666 @@ func ExampleQ_Underlying() {
 	// 1
 }
 
+func ExampleWithWorkers() {
+	qsim := q.New(q.WithWorkers(4))
+
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+	qsim.H(q0).CNOT(q0, q1)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+}
+
+func TestWithWorkers(t *testing.T) {
+	shor := func(workers int) []float64 {
+		qsim := q.New(q.WithWorkers(workers))
+		qsim.Rand = rand.Const()
+
+		r0 := qsim.Zeros(8)
+		r1 := qsim.ZeroLog2(21)
+
+		qsim.X(r1[len(r1)-1])
+		qsim.H(r0...)
+		qsim.CModExp2(2, 21, r0, r1)
+		qsim.InvQFT(r0...)
+		qsim.Measure(r1...)
+
+		return qsim.Probability()
+	}
+
+	want := shor(1)
+	for _, w := range []int{2, 4, 8} {
+		got := shor(w)
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("workers=%d: got=%v, want=%v", w, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestWithNoise_alias(t *testing.T) {
+	m := noise.NewModel().Add(noise.Rule{Channel: noise.BitFlip(1), Gate: []string{"CNOT"}})
+
+	qsim := q.New(q.WithNoise(m))
+	q0, q1 := qsim.Zero(), qsim.Zero()
+	qsim.CNOT(q0, q1)
+
+	if got := qsim.Probability(); math.Abs(got[3]-1) > epsilon.E13() {
+		t.Errorf("got=%v, want=|11>", got)
+	}
+}
+
+func TestWithWorkers_noise(t *testing.T) {
+	defer func(v int) { qubit.MinShard = v }(qubit.MinShard)
+	qubit.MinShard = 4
+
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.AmplitudeDamping(0.2), Arity: 1},
+		noise.Rule{Channel: noise.Depolarizing(0.1), Gate: []string{"ControlledNot"}},
+	)
+
+	run := func(workers int) []complex128 {
+		qsim := q.New(q.WithWorkers(workers), q.WithNoise(m))
+		qsim.Rand = rand.Const(1)
+
+		r := qsim.Zeros(6)
+		qsim.H(r...)
+		for i := range len(r) - 1 {
+			qsim.CNOT(r[i], r[i+1])
+		}
+
+		qsim.RX(0.3, r...)
+		return qsim.Amplitude()
+	}
+
+	want := run(1)
+	for _, w := range []int{2, 4, 8} {
+		got := run(w)
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("workers=%d: got=%v, want=%v", w, got[i], want[i])
+			}
+		}
+	}
+}
+
+func ExampleWithMPS() {
+	m := mps.New()
+	qsim := q.New(q.WithMPS(m))
+
+	r := qsim.Zeros(40)
+	qsim.H(r[0])
+	for i := 1; i < len(r); i++ {
+		qsim.CNOT(r[i-1], r[i])
+	}
+
+	zeros, ones := strings.Repeat("0", 40), strings.Repeat("1", 40)
+	for _, s := range m.State(zeros, ones) {
+		fmt.Printf("%.4f\n", s.Probability())
+	}
+
+	fmt.Println(m.TruncationError())
+
+	// Output:
+	// 0.5000
+	// 0.5000
+	// 0
+}
+
+func TestWithMPS(t *testing.T) {
+	shor := func(opts ...q.Option) *q.Q {
+		qsim := q.New(opts...)
+
+		r0 := qsim.Zeros(4)
+		r1 := qsim.ZeroLog2(15)
+
+		qsim.X(r1[len(r1)-1])
+		qsim.H(r0...)
+		qsim.CModExp2(7, 15, r0, r1)
+		qsim.Swap(r0...)
+		qsim.QFT(r0...)
+		qsim.ControlledState(gate.H(), []q.Qubit{r0[0]}, "0", r1[1])
+		qsim.RX(0.3, r1...)
+		qsim.InvQFT(r0...)
+
+		return qsim
+	}
+
+	cases := []struct {
+		maxBond int
+	}{
+		{0},
+		{16},
+	}
+
+	for _, c := range cases {
+		m := mps.New()
+		m.MaxBond = c.maxBond
+
+		got, want := shor(q.WithMPS(m)), shor()
+		for i, a := range want.Amplitude() {
+			if cmplx.Abs(got.Amplitude()[i]-a) > 1e-10 {
+				t.Errorf("maxBond=%d, got=%v, want=%v", c.maxBond, got.Amplitude()[i], a)
+				break
+			}
+		}
+
+		r1 := []q.Qubit{4, 5, 6, 7}
+		mg, mw := got.Marginal(r1...), want.Marginal(r1...)
+		for i := range mw {
+			if math.Abs(mg[i]-mw[i]) > 1e-10 {
+				t.Errorf("maxBond=%d, got=%v, want=%v", c.maxBond, mg, mw)
+				break
+			}
+		}
+
+		if got.Underlying() != nil {
+			t.Errorf("got=%v, want=nil", got.Underlying())
+		}
+	}
+}
+
+func ExampleWithSparse() {
+	qsim := q.New(q.WithSparse())
+	qsim.Rand = rand.Const()
+
+	r0 := qsim.Zeros(3)
+	r1 := qsim.ZeroLog2(15)
+
+	qsim.X(r1[len(r1)-1])
+	qsim.H(r0...)
+	qsim.CModExp2(7, 15, r0, r1)
+	qsim.InvQFT(r0...)
+	qsim.Measure(r1...)
+
+	for _, s := range qsim.State(r0, r1) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [000 1101][  0  13]( 0.5000 0.0000i): 0.2500
+	// [010 1101][  2  13]( 0.0000 0.5000i): 0.2500
+	// [100 1101][  4  13](-0.5000 0.0000i): 0.2500
+	// [110 1101][  6  13]( 0.0000-0.5000i): 0.2500
+}
+
+func TestWithSparse(t *testing.T) {
+	shor := func(opts ...q.Option) *q.Q {
+		qsim := q.New(opts...)
+		qsim.Rand = rand.Const(1)
+
+		r0 := qsim.Zeros(6)
+		r1 := qsim.ZeroLog2(21)
+
+		qsim.X(r1[len(r1)-1])
+		qsim.H(r0...)
+		qsim.CModExp2(2, 21, r0, r1)
+		qsim.ControlledState(gate.H(), []q.Qubit{r0[0]}, "0", r1[0])
+		qsim.InvQFT(r0...)
+		qsim.Measure(r1...)
+		qsim.Reset(r0[0])
+
+		return qsim
+	}
+
+	got, want := shor(q.WithSparse()), shor()
+	for i, p := range want.Probability() {
+		if math.Abs(got.Probability()[i]-p) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got.Probability()[i], p)
+			break
+		}
+	}
+
+	if got.Underlying() != nil {
+		t.Errorf("got=%v, want=nil", got.Underlying())
+	}
+}
+
+func ExampleWithDensity() {
+	qsim := q.New(q.WithDensity())
+	qsim.Rand = rand.Const()
+
+	r0 := qsim.Zeros(3)
+	r1 := qsim.ZeroLog2(15)
+
+	qsim.X(r1[len(r1)-1])
+	qsim.H(r0...)
+	qsim.CModExp2(7, 15, r0, r1)
+	qsim.InvQFT(r0...)
+	qsim.Reset(r1...)
+
+	for _, s := range qsim.State(r0, r1) {
+		fmt.Println(s)
+	}
+
+	fmt.Printf("purity: %.2v\n", qsim.Density().Purity())
+
+	// Output:
+	// [000 0000][  0   0]( 0.5000 0.0000i): 0.2500
+	// [010 0000][  2   0]( 0.5000 0.0000i): 0.2500
+	// [100 0000][  4   0]( 0.5000 0.0000i): 0.2500
+	// [110 0000][  6   0]( 0.5000 0.0000i): 0.2500
+	// purity: 0.25
+}
+
+func TestWithDensity(t *testing.T) {
+	shor := func(opts ...q.Option) *q.Q {
+		qsim := q.New(opts...)
+		qsim.Rand = rand.Const(1)
+
+		r0 := qsim.Zeros(3)
+		r1 := qsim.ZeroLog2(15)
+
+		qsim.X(r1[len(r1)-1])
+		qsim.H(r0...)
+		qsim.CModExp2(7, 15, r0, r1)
+		qsim.ControlledState(gate.H(), []q.Qubit{r0[0]}, "0", r1[0])
+		qsim.Swap(r0[0], r0[2])
+		qsim.InvQFT(r0...)
+		qsim.Measure(r1...)
+		qsim.Measure(r0[1])
+
+		return qsim
+	}
+
+	got, want := shor(q.WithDensity()), shor()
+	for i, p := range want.Probability() {
+		if math.Abs(got.Probability()[i]-p) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got.Probability()[i], p)
+			break
+		}
+	}
+
+	if !got.Density().IsPure() {
+		t.Errorf("purity=%v", got.Density().Purity())
+	}
+
+	if got.Amplitude() != nil || got.Underlying() != nil || want.Density() != nil {
+		t.Errorf("got=%v, %v, %v", got.Amplitude(), got.Underlying(), want.Density())
+	}
+}
+
+func ExampleWithStabilizer() {
+	qsim := q.New(q.WithStabilizer())
+	qsim.Rand = rand.Const()
+
+	r := qsim.Zeros(1000)
+	qsim.H(r[0])
+	for i := 1; i < len(r); i++ {
+		qsim.CNOT(r[i-1], r[i])
+	}
+
+	var sb strings.Builder
+	for i := range r {
+		sb.WriteString(qsim.Measure(r[i]).BinaryString())
+	}
+
+	m := sb.String()
+	fmt.Println(len(m), strings.Count(m, m[:1]))
+
+	// Output:
+	// 1000 1000
+}
+
+func ExampleWithStabilizer_teleportation() {
+	qsim := q.New(q.WithStabilizer())
+	qsim.Rand = rand.Const()
+
+	phi := qsim.New(1, 1i)
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+
+	qsim.H(q0).CNOT(q0, q1)
+	qsim.CNOT(phi, q0).H(phi)
+
+	mz := qsim.Measure(phi)
+	mx := qsim.Measure(q0)
+
+	qsim.CondX(mx.IsOne(), q1)
+	qsim.CondZ(mz.IsOne(), q1)
+
+	// Bob got |+i> state with q1
+	for _, s := range qsim.State(q1) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [0][  0]( 0.7071 0.0000i): 0.5000
+	// [1][  1]( 0.0000 0.7071i): 0.5000
+}
+
+func TestWithStabilizer(t *testing.T) {
+	clifford := func(opts ...q.Option) *q.Q {
+		qsim := q.New(opts...)
+		qsim.Rand = rand.Const(1)
+
+		qb := qsim.Zeros(3)
+		p := qsim.New(1, -1)
+		qsim.H(qb...).S(qb[0]).X(qb[1]).Y(qb[2]).Z(qb[0])
+		qsim.CNOT(qb[0], qb[1]).CZ(qb[1], p).CNOT(p, qb[2])
+		qsim.ControlledState(gate.X(), []q.Qubit{qb[2]}, "0", qb[0])
+		qsim.ControlledState(gate.Z(), []q.Qubit{qb[1]}, "0", p)
+		qsim.ControlledState(gate.Y(), []q.Qubit{qb[0]}, "1", qb[1])
+		qsim.Apply(gate.S().Dagger(), qb[1]).ApplyOn(gate.CZ(2, 0, 1).MatMul(gate.H(2)), p, qb[0])
+		qsim.RZ(math.Pi/2, qb[2]).Swap(qb[0], p)
+
+		return qsim
+	}
+
+	got, want := clifford(q.WithStabilizer()), clifford()
+	for i, p := range want.Probability() {
+		if math.Abs(got.Probability()[i]-p) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got.Probability(), want.Probability())
+			break
+		}
+	}
+
+	for _, qb := range [][]q.Qubit{{0}, {3, 1}, {2, 0, 3}} {
+		mg, mw := got.Marginal(qb...), want.Marginal(qb...)
+		for i := range mw {
+			if math.Abs(mg[i]-mw[i]) > epsilon.E13() {
+				t.Errorf("qubits=%v, got=%v, want=%v", qb, mg, mw)
+				break
+			}
+		}
+	}
+
+	// the amplitudes are equal up to the global phase
+	var overlap complex128
+	for i, a := range want.Amplitude() {
+		overlap += cmplx.Conj(a) * got.Amplitude()[i]
+	}
+
+	if math.Abs(cmplx.Abs(overlap)-1) > epsilon.E13() {
+		t.Errorf("overlap=%v", overlap)
+	}
+
+	// the measured qubits are in the same state
+	m := got.Clone().Measure(0, 1, 2, 3)
+	if want.Probability()[m.Int()] < epsilon.E13() {
+		t.Errorf("got=%v, want=%v", m, want.Probability())
+	}
+
+	if got.Underlying() != nil || got.Density() != nil {
+		t.Errorf("got=%v, %v", got.Underlying(), got.Density())
+	}
+}
+
+func TestWithStabilizer_noise(t *testing.T) {
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.Depolarizing(0.1), Arity: 1},
+		noise.Rule{Channel: noise.Unitary(0.2, gate.CNOT(2, 1, 0)), Gate: []string{"ControlledNot"}},
+		noise.Rule{Channel: noise.PhaseFlip(0.3), Qubit: []int{2}},
+	)
+
+	circuit := func(seed uint64, opts ...q.Option) *q.Q {
+		qsim := q.New(opts...)
+		qsim.Rand = rand.Const(seed)
+
+		qb := qsim.Zeros(3)
+		qsim.H(qb[0]).S(qb[2]).H(qb[2])
+		qsim.CNOT(qb[0], qb[1]).CNOT(qb[2], qb[1])
+		qsim.H(qb[2])
+
+		return qsim
+	}
+
+	want := circuit(0, q.WithDensity(), q.WithNoise(m)).Probability()
+
+	n := 2000
+	got := make([]float64, len(want))
+	for i := range n {
+		for j, p := range circuit(uint64(i), q.WithStabilizer(), q.WithNoise(m)).Probability() {
+			got[j] += p / float64(n)
+		}
+	}
+
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 0.03 {
+			t.Errorf("got=%v, want=%v", got, want)
+			break
+		}
+	}
+}
+
+func TestWithStabilizer_panics(t *testing.T) {
+	cases := []struct {
+		f    func(qsim *q.Q, qb []q.Qubit)
+		want error
+	}{
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.T(qb[0]) }, stabilizer.ErrNotClifford},
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.RX(0.1, qb[1]) }, stabilizer.ErrNotClifford},
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.CR(q.Theta(3), qb[0], qb[1]) }, stabilizer.ErrNotClifford},
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.CCNOT(qb[0], qb[1], qb[2]) }, stabilizer.ErrNotClifford},
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.QFT(qb...) }, stabilizer.ErrNotClifford},
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.ApplyChannel(noise.AmplitudeDamping(0.1), qb[0]) }, stabilizer.ErrNotClifford},
+		{func(qsim *q.Q, qb []q.Qubit) { qsim.New(1, 2) }, stabilizer.ErrNotStabilizer},
+	}
+
+	for i, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, c.want) {
+					t.Errorf("case=%d, got=%v, want=%v", i, err, c.want)
+				}
+			}()
+
+			qsim := q.New(q.WithStabilizer())
+			c.f(qsim, qsim.Zeros(3))
+		}()
+	}
+}
+
+func ExampleWithNoise() {
+	m := noise.NewModel().Add(noise.Rule{
+		Channel: noise.BitFlip(0.1),
+		Gate:    []string{"ControlledNot"},
+	})
+
+	qsim := q.New(q.WithDensity(), q.WithNoise(m))
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+
+	qsim.H(q0).CNOT(q0, q1)
+
+	for _, s := range qsim.State() {
+		fmt.Printf("%v: %.4f\n", s.BinaryString(), s.Probability())
+	}
+
+	// Output:
+	// 00: 0.4100
+	// 01: 0.0900
+	// 10: 0.0900
+	// 11: 0.4100
+}
+
+func TestWithNoise(t *testing.T) {
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.Depolarizing(0.1), Arity: 1},
+		noise.Rule{Channel: noise.Unitary(0.2, gate.CNOT(2, 1, 0)), Gate: []string{"ControlledNot"}},
+		noise.Rule{Channel: noise.PhaseFlip(0.3), Qubit: []int{2}},
+	)
+
+	circuit := func(seed uint64, opts ...q.Option) *q.Q {
+		qsim := q.New(opts...)
+		qsim.Rand = rand.Const(seed)
+
+		qb := qsim.Zeros(3)
+		qsim.H(qb[0]).RY(0.7, qb[2])
+		qsim.CNOT(qb[0], qb[1]).CNOT(qb[2], qb[1])
+		qsim.H(qb[2])
+
+		return qsim
+	}
+
+	want := circuit(0, q.WithDensity(), q.WithNoise(m)).Probability()
+
+	cases := []struct {
+		opts []q.Option
+		n    int
+		eps  float64
+	}{
+		{[]q.Option{q.WithNoise(m)}, 2000, 0.03},
+		{[]q.Option{q.WithNoise(m), q.WithSparse()}, 2000, 0.03},
+	}
+
+	for _, c := range cases {
+		got := make([]float64, len(want))
+		for i := range c.n {
+			for j, p := range circuit(uint64(i), c.opts...).Probability() {
+				got[j] += p / float64(c.n)
+			}
+		}
+
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > c.eps {
+				t.Errorf("got=%v, want=%v", got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestWithNoise_readout(t *testing.T) {
+	cases := []struct {
+		p0, p1 float64
+		one    bool
+		want   bool
+	}{
+		{0, 0, false, false},
+		{0, 0, true, true},
+		{1, 0, false, true},
+		{0, 1, true, false},
+		{1, 0, true, true},
+	}
+
+	for _, c := range cases {
+		qsim := q.New(q.WithNoise(noise.NewModel().AddReadout(c.p0, c.p1)))
+		q0 := qsim.Zero()
+		if c.one {
+			qsim.X(q0)
+		}
+
+		if got := qsim.Measure(q0).IsOne(); got != c.want {
+			t.Errorf("p0=%v, p1=%v, got=%v, want=%v", c.p0, c.p1, got, c.want)
+		}
+
+		if got := qsim.State()[0].BinaryString() == "1"; got != c.one {
+			t.Errorf("p0=%v, p1=%v, state=%v", c.p0, c.p1, qsim.State())
+		}
+	}
+}
+
+// counter is a backend that counts the applied gates.
+type counter struct {
+	q.Backend
+	gates int
+}
+
+func (c *counter) Apply(u *matrix.Matrix, control []int, state string, target ...int) {
+	c.gates++
+	c.Backend.Apply(u, control, state, target...)
+}
+
+func ExampleWithBackend() {
+	c := &counter{Backend: q.New(q.WithMPS(mps.New())).Backend()}
+	qsim := q.New(q.WithBackend(c))
+
+	q0 := qsim.Zero()
+	q1 := qsim.Zero()
+
+	qsim.H(q0).CNOT(q0, q1)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	fmt.Println(c.gates, qsim.Backend() == c)
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+	// 2 true
+}
+
+func TestWithBackend(t *testing.T) {
+	cases := []struct {
+		opts []q.Option
+	}{
+		{nil},
+		{[]q.Option{q.WithSparse()}},
+		{[]q.Option{q.WithMPS(mps.New())}},
+		{[]q.Option{q.WithDensity()}},
+		{[]q.Option{q.WithStabilizer()}},
+	}
+
+	for _, c := range cases {
+		qsim := q.New(c.opts...)
+		qsim.Rand = rand.Const(1)
+
+		q0 := qsim.One()
+		q1 := qsim.Zero()
+		q2 := qsim.Zero()
+
+		qsim.H(q0).CNOT(q0, q1).ControlledState(gate.X(), []q.Qubit{q1}, "0", q2)
+		qsim.Reset(q0, q2)
+
+		b := qsim.Backend()
+		if b.NumQubits() != 3 {
+			t.Errorf("got=%v, want=3", b.NumQubits())
+		}
+
+		if p := b.Marginal(q0.Index(), q2.Index()); math.Abs(p[0]-1) > epsilon.E13() {
+			t.Errorf("got=%v, want=1", p[0])
+		}
+
+		clone := qsim.Clone()
+		clone.X(q0)
+		if math.Abs(b.Marginal(q0.Index())[0]-1) > epsilon.E13() {
+			t.Errorf("got=%v, want=1", b.Marginal(q0.Index()))
+		}
+	}
+
+	if q.New().Backend() != nil {
+		t.Errorf("got=%v, want=nil", q.New().Backend())
+	}
+}
+
+func TestBackend_Permute(t *testing.T) {
+	cases := []struct {
+		opts []q.Option
+	}{
+		{nil},
+		{[]q.Option{q.WithSparse()}},
+		{[]q.Option{q.WithMPS(mps.New())}},
+		{[]q.Option{q.WithDensity()}},
+		{[]q.Option{q.WithStabilizer()}},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, qubit.ErrNotBijection) {
+					t.Errorf("got=%v, want=%v", err, qubit.ErrNotBijection)
+				}
+			}()
+
+			qsim := q.New(c.opts...)
+			qsim.Zeros(3)
+			qsim.Backend().Permute(func(k int) int { return k / 2 }, []int{0}, 1, 2)
+		}()
+	}
+}
+
 func TestEigenVector(t *testing.T) {
 	cases := []struct {
 		N, a, t int
@@ -1335,5 +2333,46 @@ func TestEigenVector(t *testing.T) {
 				t.Fail()
 			}
 		}
+
+		// U|y> = |a*y mod N> for y < N, and (U + U^dagger)/2 has the eigenvalue cos(2*pi*s/r) for |u_s>.
+		d := 1 << len(r1)
+		u := matrix.Zero(d, d)
+		for y := range d {
+			if y < c.N {
+				u.Set(c.a*y%c.N, y, 1)
+				continue
+			}
+
+			u.Set(y, y, 1)
+		}
+
+		w, v := vector.Eigen(u.Add(u.Dagger()).Mul(0.5))
+
+		// the state of r1 for the measured r0 is in the eigenspace of cos(2*pi*k/2**t)
+		psi := make(map[int64]*vector.Vector)
+		for _, s := range qsim.State(r0, r1) {
+			if _, ok := psi[s.Int(0)]; !ok {
+				psi[s.Int(0)] = vector.Zero(d)
+			}
+
+			psi[s.Int(0)].Data[s.Int(1)] = s.Amplitude()
+		}
+
+		for k, p := range psi {
+			lambda := math.Cos(2 * math.Pi * float64(k) / float64(number.Pow(2, c.t)))
+
+			var sum float64
+			for i := range w {
+				if math.Abs(w[i]-lambda) > 1e-10 {
+					continue
+				}
+
+				sum += math.Pow(cmplx.Abs(v[i].InnerProduct(p)), 2)
+			}
+
+			if math.Abs(sum-math.Pow(real(p.Norm()), 2)) > 1e-10 {
+				t.Errorf("got=%v, want=%v", sum, math.Pow(real(p.Norm()), 2))
+			}
+		}
 	}
 }
@@ -0,0 +1,467 @@
+package qasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/number"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+var ErrUnknownOperation = errors.New("unknown operation")
+
+// names are the gates of stdgates.inc recognized by their matrices.
+var names = []struct {
+	name string
+	u    *matrix.Matrix
+}{
+	{"id", gate.I()},
+	{"x", gate.X()},
+	{"y", gate.Y()},
+	{"z", gate.Z()},
+	{"h", gate.H()},
+	{"s", gate.S()},
+	{"sdg", gate.S().Dagger()},
+	{"t", gate.T()},
+	{"tdg", gate.T().Dagger()},
+	{"sx", sx()},
+}
+
+// Emit returns the OpenQASM 3 program of the circuit.
+// The qubits are declared as qubit[n] q, and the classical bits as bit[m] c.
+// The matrices that are not in stdgates.inc are written with U and gphase,
+// and the multi-qubit matrices are decomposed into the controlled single-qubit gates.
+func Emit(c *circuit.Circuit) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("OPENQASM 3.0;\n")
+	sb.WriteString("include \"stdgates.inc\";\n")
+
+	if c.NumQubits() > 0 {
+		fmt.Fprintf(&sb, "qubit[%d] q;\n", c.NumQubits())
+	}
+
+	if c.NumClbits() > 0 {
+		fmt.Fprintf(&sb, "bit[%d] c;\n", c.NumClbits())
+	}
+
+	for _, op := range c.Ops {
+		lines, err := statements(op)
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", op, err)
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		if op.Cond == nil {
+			for _, l := range lines {
+				fmt.Fprintf(&sb, "%s\n", l)
+			}
+
+			continue
+		}
+
+		if len(lines) == 1 {
+			fmt.Fprintf(&sb, "if (%s) %s\n", condition(op.Cond, c.NumClbits()), lines[0])
+			continue
+		}
+
+		fmt.Fprintf(&sb, "if (%s) {\n", condition(op.Cond, c.NumClbits()))
+		for _, l := range lines {
+			fmt.Fprintf(&sb, "  %s\n", l)
+		}
+
+		sb.WriteString("}\n")
+	}
+
+	return sb.String(), nil
+}
+
+// statements returns the statements of the operation.
+func statements(op circuit.Op) ([]string, error) {
+	var lines []string
+	each := func(format string, a ...any) {
+		for _, qb := range op.Qubits {
+			lines = append(lines, fmt.Sprintf(format, append(a, qubit(qb))...))
+		}
+	}
+
+	switch op.Name {
+	case "New":
+		return prepare(op.Amplitude, op.Qubits), nil
+	case "Reset":
+		each("reset %s;")
+	case "U":
+		each("U(%s, %s, %s) %s;", float(op.Params[0]), float(op.Params[1]), float(op.Params[2]))
+	case "I", "X", "Y", "Z", "H", "S", "T":
+		each("%s %s;", map[string]string{"I": "id", "X": "x", "Y": "y", "Z": "z", "H": "h", "S": "s", "T": "t"}[op.Name])
+	case "R":
+		each("p(%s) %s;", float(op.Params[0]))
+	case "RX", "RY", "RZ":
+		each("%s(%s) %s;", strings.ToLower(op.Name), float(op.Params[0]))
+	case "Apply":
+		if len(op.Qubits) == 0 {
+			d, _ := op.Matrix.Dimension()
+			all := make([]q.Qubit, number.Log2(d))
+			for i := range all {
+				all[i] = q.Qubit(i)
+			}
+
+			return unitary(op.Matrix, nil, "", all), nil
+		}
+
+		for _, qb := range op.Qubits {
+			lines = append(lines, unitary(op.Matrix, nil, "", []q.Qubit{qb})...)
+		}
+	case "ApplyOn":
+		return unitary(op.Matrix, nil, "", op.Qubits), nil
+	case "Controlled":
+		return unitary(op.Matrix, op.Controls, strings.Repeat("1", len(op.Controls)), op.Qubits), nil
+	case "ControlledState":
+		return unitary(op.Matrix, op.Controls, op.State, op.Qubits), nil
+	case "ControlledNot":
+		return []string{ctrlgate("x", "cx", op.Controls, op.Qubits[0])}, nil
+	case "ControlledZ":
+		return []string{ctrlgate("z", "cz", op.Controls, op.Qubits[0])}, nil
+	case "ControlledR":
+		p := fmt.Sprintf("(%s)", float(op.Params[0]))
+		return []string{ctrlgate("p"+p, "cp"+p, op.Controls, op.Qubits[0])}, nil
+	case "ControlledModExp2":
+		a, j, N := int(op.Params[0]), int(op.Params[1]), int(op.Params[2])
+		return unitary(modexp2(a, j, N, len(op.Qubits)), op.Controls, "1", op.Qubits), nil
+	case "Swap":
+		l := len(op.Qubits)
+		for i := range l / 2 {
+			lines = append(lines, fmt.Sprintf("swap %s, %s;", qubit(op.Qubits[i]), qubit(op.Qubits[l-1-i])))
+		}
+	case "QFT":
+		qb := op.Qubits
+		for i := range qb {
+			lines = append(lines, fmt.Sprintf("h %s;", qubit(qb[i])))
+			for j, k := i+1, 2; j < len(qb); j, k = j+1, k+1 {
+				lines = append(lines, fmt.Sprintf("cp(%s) %s, %s;", float(q.Theta(k)), qubit(qb[j]), qubit(qb[i])))
+			}
+		}
+	case "InverseQFT":
+		qb := op.Qubits
+		for i := len(qb) - 1; i > -1; i-- {
+			for j, k := len(qb)-1, len(qb)-i; j > i; j, k = j-1, k-1 {
+				lines = append(lines, fmt.Sprintf("cp(%s) %s, %s;", float(-1*q.Theta(k)), qubit(qb[j]), qubit(qb[i])))
+			}
+
+			lines = append(lines, fmt.Sprintf("h %s;", qubit(qb[i])))
+		}
+	case "Measure":
+		for i, qb := range op.Qubits {
+			lines = append(lines, fmt.Sprintf("c[%d] = measure %s;", op.Clbits[i], qubit(qb)))
+		}
+	default:
+		return nil, ErrUnknownOperation
+	}
+
+	return lines, nil
+}
+
+// prepare returns the statements that prepare the qubits in the state v from |0...0>.
+// The multi-qubit state is prepared by the unitary whose first column is v.
+func prepare(v []complex128, qbs []q.Qubit) []string {
+	if len(v) != 2 {
+		return unitary(preparation(v), nil, "", qbs)
+	}
+
+	qb := qbs[0]
+	a, b := v[0], v[1]
+	n := complex(math.Sqrt(real(a*cmplx.Conj(a)+b*cmplx.Conj(b))), 0)
+	a, b = a/n, b/n
+
+	if cmplx.Abs(a-1) < epsilon.E13() {
+		return nil
+	}
+
+	if cmplx.Abs(b-1) < epsilon.E13() {
+		return []string{fmt.Sprintf("x %s;", qubit(qb))}
+	}
+
+	// (a, b) = exp(i * gamma) * U(theta, phi, 0)|0>
+	theta, phi, gamma := 2*math.Atan2(cmplx.Abs(b), cmplx.Abs(a)), cmplx.Phase(b)-cmplx.Phase(a), cmplx.Phase(a)
+	if cmplx.Abs(a) < epsilon.E13() {
+		phi, gamma = 0, cmplx.Phase(b)
+	}
+
+	return append([]string{fmt.Sprintf("U(%s, %s, 0) %s;", float(theta), float(phi), qubit(qb))}, gphase(gamma, nil, "")...)
+}
+
+// preparation returns the unitary whose first column is the normalized v.
+// It is the q of the QR decomposition of [v, e1, e2, ...] with the phase of r[0][0] moved to q.
+func preparation(v []complex128) *matrix.Matrix {
+	d := len(v)
+	m := matrix.Identity(d)
+	for i := range d {
+		m.Set(i, 0, v[i])
+	}
+
+	u, r := m.QR()
+	phase := r.At(0, 0) / complex(cmplx.Abs(r.At(0, 0)), 0)
+	for i := range d {
+		u.MulAt(i, 0, phase)
+	}
+
+	return u
+}
+
+// unitary returns the statements of u on the target qubits controlled by the qubits ctrl in the state.
+func unitary(u *matrix.Matrix, ctrl []q.Qubit, state string, target []q.Qubit) []string {
+	if len(target) == 1 {
+		return single(u, ctrl, state, target[0])
+	}
+
+	if len(target) == 2 && u.Equals(gate.Swap(2, 0, 1), epsilon.E13()) {
+		return []string{fmt.Sprintf("%sswap %s;", modifiers(state), arguments(append(slices.Clone(ctrl), target...)))}
+	}
+
+	var lines []string
+	for _, g := range decompose(u) {
+		// the other target qubits control the two-level unitary.
+		c, s := slices.Clone(ctrl), state
+		for i, qb := range target {
+			if i == g.bit {
+				continue
+			}
+
+			c, s = append(c, qb), s+string(g.index[i])
+		}
+
+		lines = append(lines, single(g.u, c, s, target[g.bit])...)
+	}
+
+	return lines
+}
+
+// single returns the statements of the single-qubit gate u controlled by the qubits ctrl in the state.
+func single(u *matrix.Matrix, ctrl []q.Qubit, state string, target q.Qubit) []string {
+	args := arguments(append(slices.Clone(ctrl), target))
+	for _, g := range names {
+		if u.Equals(g.u, epsilon.E13()) {
+			if g.name == "id" && len(ctrl) > 0 {
+				return nil
+			}
+
+			return []string{fmt.Sprintf("%s%s %s;", modifiers(state), g.name, args)}
+		}
+	}
+
+	theta, phi, lambda, gamma := zyz(u)
+
+	var lines []string
+	if math.Abs(theta) > epsilon.E13() || math.Abs(phi) > epsilon.E13() || math.Abs(lambda) > epsilon.E13() {
+		lines = append(lines, fmt.Sprintf("%sU(%s, %s, %s) %s;", modifiers(state), float(theta), float(phi), float(lambda), args))
+	}
+
+	return append(lines, gphase(gamma, ctrl, state)...)
+}
+
+// gphase returns the global phase statement controlled by the qubits ctrl in the state.
+func gphase(gamma float64, ctrl []q.Qubit, state string) []string {
+	if math.Abs(gamma) < epsilon.E13() {
+		return nil
+	}
+
+	if len(ctrl) == 0 {
+		return []string{fmt.Sprintf("gphase(%s);", float(gamma))}
+	}
+
+	return []string{fmt.Sprintf("%sgphase(%s) %s;", modifiers(state), float(gamma), arguments(ctrl))}
+}
+
+// ctrlgate returns the statement of the gate controlled by the qubits ctrl.
+// short is the name of the gate with a single control qubit such as cx.
+func ctrlgate(name, short string, ctrl []q.Qubit, target q.Qubit) string {
+	args := arguments(append(slices.Clone(ctrl), target))
+	if len(ctrl) == 1 {
+		return fmt.Sprintf("%s %s;", short, args)
+	}
+
+	return fmt.Sprintf("%s%s %s;", modifiers(strings.Repeat("1", len(ctrl))), name, args)
+}
+
+// modifiers returns the control modifiers such as "ctrl(2) @ negctrl @ " of the state.
+func modifiers(state string) string {
+	var sb strings.Builder
+	for i := 0; i < len(state); {
+		j := i
+		for j < len(state) && state[j] == state[i] {
+			j++
+		}
+
+		name := map[byte]string{'1': "ctrl", '0': "negctrl"}[state[i]]
+		if j-i > 1 {
+			name = fmt.Sprintf("%s(%d)", name, j-i)
+		}
+
+		fmt.Fprintf(&sb, "%s @ ", name)
+		i = j
+	}
+
+	return sb.String()
+}
+
+// condition returns the condition such as c == 2 or c[0] == 1 && c[2] == 0.
+func condition(cond *circuit.Cond, clbits int) string {
+	all := make(circuit.Bits, clbits)
+	for i := range clbits {
+		all[i] = circuit.Clbit(clbits - 1 - i)
+	}
+
+	if slices.Equal(cond.Clbits, all) {
+		return fmt.Sprintf("c == %d", cond.Value)
+	}
+
+	terms := make([]string, len(cond.Clbits))
+	for i, b := range cond.Clbits {
+		terms[i] = fmt.Sprintf("c[%d] == %d", b, (cond.Value>>(len(cond.Clbits)-1-i))&1)
+	}
+
+	return strings.Join(terms, " && ")
+}
+
+func arguments(qb []q.Qubit) string {
+	args := make([]string, len(qb))
+	for i := range qb {
+		args[i] = qubit(qb[i])
+	}
+
+	return strings.Join(args, ", ")
+}
+
+func qubit(qb q.Qubit) string {
+	return fmt.Sprintf("q[%d]", qb)
+}
+
+func float(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// zyz returns theta, phi, lambda and gamma such that u = exp(i * gamma) * U(theta, phi, lambda).
+func zyz(u *matrix.Matrix) (theta, phi, lambda, gamma float64) {
+	c, s := cmplx.Abs(u.At(0, 0)), cmplx.Abs(u.At(1, 0))
+	theta = 2 * math.Atan2(s, c)
+
+	if c > epsilon.E13() {
+		gamma = cmplx.Phase(u.At(0, 0))
+	}
+
+	if s < epsilon.E13() {
+		return theta, 0, cmplx.Phase(u.At(1, 1)) - gamma, gamma
+	}
+
+	return theta, cmplx.Phase(u.At(1, 0)) - gamma, cmplx.Phase(-1*u.At(0, 1)) - gamma, gamma
+}
+
+// modexp2 returns the matrix of |k> -> |a**(2**j) * k mod N> on n qubits.
+func modexp2(a, j, N, n int) *matrix.Matrix {
+	a2jmodN := number.ModExp2(a, j, N)
+
+	d := 1 << n
+	m := matrix.Zero(d, d)
+	for k := range d {
+		if k > N-1 {
+			m.Set(k, k, 1)
+			continue
+		}
+
+		m.Set(a2jmodN*k%N, k, 1)
+	}
+
+	return m
+}
+
+// twolevel is a unitary that acts non-trivially only on two basis states differing in one bit.
+type twolevel struct {
+	u     *matrix.Matrix // u is the (2 x 2) unitary on the basis states with bit 0 and 1.
+	index string         // index is the binary string of the basis states. index[bit] is ignored.
+	bit   int            // bit is the position of the differing bit. 0 is the most significant bit.
+}
+
+// decompose returns the two-level unitaries whose product is u in the order of application.
+// The pairs of the basis states are adjacent in the Gray code, so each two-level unitary is
+// the single-qubit gate controlled by the other qubits.
+func decompose(u *matrix.Matrix) []twolevel {
+	d, _ := u.Dimension()
+	n := number.Log2(d)
+	gray := func(r int) int { return r ^ (r >> 1) }
+
+	pair := func(a, b int, g *matrix.Matrix) twolevel {
+		bit := n - 1 - number.Log2(a^b)
+		if a > b {
+			// reorder to the basis states with bit 0 and 1.
+			g = matrix.New(
+				[]complex128{g.At(1, 1), g.At(1, 0)},
+				[]complex128{g.At(0, 1), g.At(0, 0)},
+			)
+		}
+
+		return twolevel{u: g, index: fmt.Sprintf("%0*b", n, min(a, b)), bit: bit}
+	}
+
+	// Givens rotations G such that G_m...G_1 u = D.
+	m, givens := u.Clone(), make([]twolevel, 0)
+	for col := range d - 1 {
+		c := gray(col)
+		for r := d - 1; r > col; r-- {
+			a, b := gray(r-1), gray(r)
+			x, y := m.At(a, c), m.At(b, c)
+			if cmplx.Abs(y) < epsilon.E13() {
+				continue
+			}
+
+			norm := complex(math.Hypot(cmplx.Abs(x), cmplx.Abs(y)), 0)
+			g := matrix.New(
+				[]complex128{cmplx.Conj(x) / norm, cmplx.Conj(y) / norm},
+				[]complex128{-1 * y / norm, x / norm},
+			)
+
+			for k := range d {
+				ma, mb := m.At(a, k), m.At(b, k)
+				m.Set(a, k, g.At(0, 0)*ma+g.At(0, 1)*mb)
+				m.Set(b, k, g.At(1, 0)*ma+g.At(1, 1)*mb)
+			}
+
+			givens = append(givens, pair(a, b, g))
+		}
+	}
+
+	// u = G_1^dagger...G_m^dagger D, and D is applied first.
+	out := make([]twolevel, 0)
+	for r := range d {
+		e := m.At(gray(r), gray(r))
+		if cmplx.Abs(e-1) < epsilon.E13() {
+			continue
+		}
+
+		a, b := gray(r), gray(r+1)
+		if r > 0 {
+			a, b = gray(r), gray(r-1)
+		}
+
+		out = append(out, pair(a, b, matrix.New(
+			[]complex128{e, 0},
+			[]complex128{0, 1},
+		)))
+	}
+
+	for i := len(givens) - 1; i > -1; i-- {
+		out = append(out, twolevel{u: givens[i].u.Dagger(), index: givens[i].index, bit: givens[i].bit})
+	}
+
+	return out
+}
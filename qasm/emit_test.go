@@ -0,0 +1,311 @@
+package qasm_test
+
+import (
+	"errors"
+	"fmt"
+	"math/cmplx"
+	"testing"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/qasm"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+func ExampleEmit() {
+	c := circuit.New()
+
+	// generate qubits of |phi>|0>|0>
+	phi := c.New(1, 2)
+	q0 := c.Zero()
+	q1 := c.Zero()
+
+	c.H(q0).CNOT(q0, q1)
+	c.CNOT(phi, q0).H(phi)
+
+	mz := c.Measure(phi)
+	mx := c.Measure(q0)
+
+	c.CondX(mx.IsOne(), q1)
+	c.CondZ(mz.IsOne(), q1)
+
+	s, err := qasm.Emit(c)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print(s)
+
+	// Output:
+	// OPENQASM 3.0;
+	// include "stdgates.inc";
+	// qubit[3] q;
+	// bit[2] c;
+	// U(2.214297435588181, 0, 0) q[0];
+	// h q[1];
+	// cx q[1], q[2];
+	// cx q[0], q[1];
+	// h q[0];
+	// c[0] = measure q[0];
+	// c[1] = measure q[1];
+	// if (c[1] == 1) x q[2];
+	// if (c[0] == 1) z q[2];
+}
+
+func ExampleEmit_controlled() {
+	c := circuit.New()
+	qb := c.Zeros(4)
+	c.CCCNOT(qb[0], qb[1], qb[2], qb[3])
+	c.ControlledState(gate.H(), qb[:3], "101", qb[3])
+	c.CR(q.Theta(2), qb[0], qb[1])
+	c.QFT(qb[2], qb[3])
+	m := c.Measure(qb...)
+	c.CondX(m.Eq(3), qb[0])
+
+	s, err := qasm.Emit(c)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print(s)
+
+	// Output:
+	// OPENQASM 3.0;
+	// include "stdgates.inc";
+	// qubit[4] q;
+	// bit[4] c;
+	// ctrl(3) @ x q[0], q[1], q[2], q[3];
+	// ctrl @ negctrl @ ctrl @ h q[0], q[1], q[2], q[3];
+	// cp(1.5707963267948966) q[0], q[1];
+	// h q[2];
+	// cp(1.5707963267948966) q[3], q[2];
+	// h q[3];
+	// c[0] = measure q[0];
+	// c[1] = measure q[1];
+	// c[2] = measure q[2];
+	// c[3] = measure q[3];
+	// if (c[0] == 0 && c[1] == 0 && c[2] == 1 && c[3] == 1) x q[0];
+}
+
+func TestEmit(t *testing.T) {
+	u := matrix.TensorProduct(gate.U(1, 2, 3), gate.RY(0.4)).Apply(gate.CNOT(2, 0, 1)).Apply(gate.QFT(2))
+
+	cases := []func(c *circuit.Circuit, qb []q.Qubit){
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.H(qb...).T(qb[0]).S(qb[1]).X(qb[2]).Y(qb[3]).Z(qb[0]).I(qb[1])
+			c.U(1, 2, 3, qb[0]).R(0.1, qb[1]).RX(0.2, qb[2]).RY(0.3, qb[3]).RZ(0.4, qb[0])
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.H(qb...).CNOT(qb[0], qb[1]).CCNOT(qb[0], qb[1], qb[2]).CCCNOT(qb[0], qb[1], qb[2], qb[3])
+			c.CZ(qb[0], qb[3]).CCZ(qb[1], qb[2], qb[0]).CR(0.5, qb[2], qb[1]).ControlledR(0.6, qb[:3], qb[3]).Swap(qb[0], qb[3])
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.H(qb...).C(gate.Y(), qb[1], qb[2]).C(gate.U(1, 2, 3).Mul(1i), qb[0], qb[3])
+			c.ControlledState(gate.RX(0.7), []q.Qubit{qb[0], qb[1]}, "01", qb[3])
+			c.ControlledState(gate.S(), []q.Qubit{qb[2], qb[3], qb[1]}, "100", qb[0])
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.H(qb...).Apply(gate.S().Dagger(), qb[0], qb[1]).Apply(gate.RX(0.7).Mul(cmplx.Exp(0.3i)), qb[2])
+			c.ApplyOn(u, qb[3], qb[1]).ApplyOn(gate.Swap(2, 0, 1), qb[0], qb[2])
+			c.Controlled(u, []q.Qubit{qb[0]}, qb[2], qb[3]).ControlledState(gate.QFT(3), []q.Qubit{qb[1]}, "0", qb[3], qb[0], qb[2])
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.X(qb[3]).H(qb[0]).CModExp2(2, 7, qb[:1], qb[1:]).QFT(qb...).InvQFT(qb[1:]...).IQFT(qb[:2]...)
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			a := c.New(1i, 2)
+			b := c.New(0, -1)
+			c.H(qb...).CNOT(a, qb[0]).CZ(b, qb[1])
+			c.Apply(gate.QFT(6))
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			a := c.New(1, 2i, 0, -1)
+			b := c.New(0.5, 0, 0, 0, 1i, -1, 0, 2)
+			c.H(qb...).CNOT(a, qb[0]).CZ(a+1, qb[1]).Swap(b+2, qb[3])
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.H(qb...)
+			c.Reset(qb[0])
+			m := c.Measure(qb[1], qb[2])
+			c.CondX(m.IsOne(), qb[0])
+			c.CondZ(m.Eq(2), qb[3])
+			c.Cond(m.IsZero(), gate.U(0.1, 0.2, 0.3).Mul(-1i), qb[3])
+			c.Add(circuit.Op{Name: "QFT", Qubits: qb, Cond: &circuit.Cond{Clbits: m, Value: 1}})
+			c.M(qb[3])
+		},
+		func(c *circuit.Circuit, qb []q.Qubit) {
+			c.H(qb...)
+			m := c.Measure(qb[0], qb[1])
+			c.CondX(circuit.Bits{m[1], m[0]}.Eq(1), qb[2])
+			c.Cond(circuit.Bits{m[1], m[0]}.Eq(2), gate.H(), qb[3])
+		},
+	}
+
+	for i, f := range cases {
+		c := circuit.New()
+		f(c, c.Zeros(4))
+
+		s, err := qasm.Emit(c)
+		if err != nil {
+			t.Errorf("case=%d, err=%v", i, err)
+			continue
+		}
+
+		p, err := qasm.Parse(s)
+		if err != nil {
+			t.Errorf("case=%d, err=%v\n%s", i, err, s)
+			continue
+		}
+
+		for _, seed := range []uint64{1, 2, 3} {
+			want := q.New()
+			want.Rand = rand.Const(seed)
+			wbits := c.Run(want)
+
+			got := q.New()
+			got.Rand = rand.Const(seed)
+			gbits := p.Run(got)
+
+			if fmt.Sprint(gbits) != fmt.Sprint(wbits) {
+				t.Errorf("case=%d, got=%v, want=%v", i, gbits, wbits)
+			}
+
+			for j, a := range got.Amplitude() {
+				if cmplx.Abs(a-want.Amplitude()[j]) > epsilon.E13() {
+					t.Errorf("case=%d, got=%v, want=%v\n%s", i, got.Amplitude(), want.Amplitude(), s)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestEmitQASM2(t *testing.T) {
+	src := `
+OPENQASM 2.0;
+include "qelib1.inc";
+
+gate g(theta) a, b { rx(theta) a; cx a, b; }
+qreg q[3];
+creg c[3];
+
+h q;
+u2(0.1, 0.2) q[0];
+sx q[1];
+rzz(0.3) q[0], q[2];
+rxx(0.4) q[1], q[0];
+cu(0.1, 0.2, 0.3, 0.4) q[2], q[1];
+crz(0.5) q[0], q[2];
+cswap q[1], q[0], q[2];
+g(0.6) q[2], q[0];
+measure q[0] -> c[0];
+if(c==1) ccx q[0], q[1], q[2];
+`
+
+	c, err := qasm.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := qasm.Emit(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := qasm.Parse(s)
+	if err != nil {
+		t.Fatalf("err=%v\n%s", err, s)
+	}
+
+	for _, seed := range []uint64{1, 2} {
+		want := q.New()
+		want.Rand = rand.Const(seed)
+		c.Run(want)
+
+		got := q.New()
+		got.Rand = rand.Const(seed)
+		p.Run(got)
+
+		for j, a := range got.Amplitude() {
+			if cmplx.Abs(a-want.Amplitude()[j]) > epsilon.E13() {
+				t.Errorf("got=%v, want=%v\n%s", got.Amplitude(), want.Amplitude(), s)
+				break
+			}
+		}
+	}
+}
+
+func TestParseQASM3(t *testing.T) {
+	cases := []struct {
+		src  string
+		want func(qsim *q.Q, qb []q.Qubit)
+	}{
+		{
+			`h q; ctrl @ x q[0], q[1]; negctrl @ ctrl @ z q[1], q[0], q[2]; ctrl(2) @ rx(0.3) q[2], q[1], q[0];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).CNOT(qb[0], qb[1]).ControlledState(gate.Z(), []q.Qubit{qb[1], qb[0]}, "01", qb[2])
+				qsim.Controlled(gate.RX(0.3), []q.Qubit{qb[2], qb[1]}, qb[0])
+			},
+		},
+		{
+			`h q; gphase(0.3); ctrl @ gphase(0.4) q[1]; negctrl(2) @ gphase(0.5) q[0], q[2]; ctrl @ swap q[1], q[0], q[2];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).Apply(gate.I().Mul(cmplx.Exp(0.3i)), qb[0]).R(0.4, qb[1])
+				qsim.ControlledState(gate.New([]complex128{cmplx.Exp(0.5i), 0}, []complex128{0, 1}), []q.Qubit{qb[0]}, "0", qb[2])
+				qsim.Controlled(gate.Swap(2, 0, 1), []q.Qubit{qb[1]}, qb[0], qb[2])
+			},
+		},
+		{
+			`gate g(a) x, y { ctrl @ ry(a) x, y; gphase(a); } h q; ctrl @ g(0.2) q[2], q[0], q[1]; negctrl @ g(0.7) q[0], q[1], q[2];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).Controlled(gate.RY(0.2), []q.Qubit{qb[2], qb[0]}, qb[1]).R(0.2, qb[2])
+				qsim.ControlledState(gate.RY(0.7), []q.Qubit{qb[0], qb[1]}, "01", qb[2])
+				qsim.Apply(gate.New([]complex128{cmplx.Exp(0.7i), 0}, []complex128{0, 1}), qb[0])
+			},
+		},
+		{
+			`bit[2] c; bit d; x q[0]; c[1] = measure q[0]; d = measure q[1]; if (c == 2) { x q[1]; h q[2]; } if (c[1] && !d) x q[2]; if (c[0] == 1) x q;`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.X(qb[0]).Measure(qb[0])
+				qsim.Measure(qb[1])
+				qsim.X(qb[1]).H(qb[2]).X(qb[2])
+			},
+		},
+	}
+
+	for _, c := range cases {
+		src := `OPENQASM 3.0; include "stdgates.inc"; qubit[3] q;` + c.src
+		circ, err := qasm.Parse(src)
+		if err != nil {
+			t.Errorf("src=%q, err=%v", c.src, err)
+			continue
+		}
+
+		got := q.New()
+		got.Rand = rand.Const(1)
+		circ.Run(got)
+
+		want := q.New()
+		want.Rand = rand.Const(1)
+		c.want(want, want.Zeros(3))
+
+		for i, a := range got.Amplitude() {
+			if cmplx.Abs(a-want.Amplitude()[i]) > epsilon.E13() {
+				t.Errorf("src=%q, got=%v, want=%v", c.src, got.Amplitude(), want.Amplitude())
+				break
+			}
+		}
+	}
+}
+
+func TestEmitUnknownOperation(t *testing.T) {
+	c := circuit.New()
+	c.Add(circuit.Op{Name: "Unknown", Qubits: []q.Qubit{0}})
+
+	if _, err := qasm.Emit(c); !errors.Is(err, qasm.ErrUnknownOperation) {
+		t.Errorf("got=%v, want=%v", err, qasm.ErrUnknownOperation)
+	}
+}
@@ -0,0 +1,170 @@
+package qasm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// expr is a real-valued expression of the gate parameters.
+type expr func(env map[string]float64) float64
+
+// funcs are the unary functions available in expressions.
+var funcs = map[string]func(float64) float64{
+	"sin":  math.Sin,
+	"cos":  math.Cos,
+	"tan":  math.Tan,
+	"exp":  math.Exp,
+	"ln":   math.Log,
+	"sqrt": math.Sqrt,
+}
+
+// expr parses an expression.
+// scope is the set of the parameter names that may appear in the expression.
+func (p *parser) expr(scope map[string]bool) (expr, error) {
+	return p.additive(scope)
+}
+
+func (p *parser) additive(scope map[string]bool) (expr, error) {
+	x, err := p.multiplicative(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("+") || p.peek("-") {
+		op := p.next().text
+		y, err := p.multiplicative(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		x = binary(op, x, y)
+	}
+
+	return x, nil
+}
+
+func (p *parser) multiplicative(scope map[string]bool) (expr, error) {
+	x, err := p.unary(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("*") || p.peek("/") {
+		op := p.next().text
+		y, err := p.unary(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		x = binary(op, x, y)
+	}
+
+	return x, nil
+}
+
+func (p *parser) unary(scope map[string]bool) (expr, error) {
+	if p.peek("-") || p.peek("+") {
+		op := p.next().text
+		x, err := p.unary(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "+" {
+			return x, nil
+		}
+
+		return func(env map[string]float64) float64 { return -x(env) }, nil
+	}
+
+	return p.power(scope)
+}
+
+func (p *parser) power(scope map[string]bool) (expr, error) {
+	x, err := p.primary(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.peek("^") {
+		return x, nil
+	}
+
+	// right associative
+	p.next()
+	y, err := p.unary(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return binary("^", x, y), nil
+}
+
+func (p *parser) primary(scope map[string]bool) (expr, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokInt || t.kind == tokReal:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %v", t)
+		}
+
+		return func(map[string]float64) float64 { return v }, nil
+	case t.kind == tokIdent && (t.text == "pi" || t.text == "π"):
+		return func(map[string]float64) float64 { return math.Pi }, nil
+	case t.kind == tokIdent && funcs[t.text] != nil:
+		f := funcs[t.text]
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+
+		x, err := p.expr(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return func(env map[string]float64) float64 { return f(x(env)) }, nil
+	case t.kind == tokIdent:
+		if !scope[t.text] {
+			return nil, p.errorf(t, "undefined parameter %v", t)
+		}
+
+		name := t.text
+		return func(env map[string]float64) float64 { return env[name] }, nil
+	case t.kind == tokSymbol && t.text == "(":
+		x, err := p.expr(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return x, nil
+	}
+
+	return nil, p.errorf(t, "unexpected %v in expression", t)
+}
+
+func binary(op string, x, y expr) expr {
+	switch op {
+	case "+":
+		return func(env map[string]float64) float64 { return x(env) + y(env) }
+	case "-":
+		return func(env map[string]float64) float64 { return x(env) - y(env) }
+	case "*":
+		return func(env map[string]float64) float64 { return x(env) * y(env) }
+	case "/":
+		return func(env map[string]float64) float64 { return x(env) / y(env) }
+	case "^":
+		return func(env map[string]float64) float64 { return math.Pow(x(env), y(env)) }
+	}
+
+	panic(fmt.Sprintf("invalid operator %q", op))
+}
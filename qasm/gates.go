@@ -0,0 +1,242 @@
+package qasm
+
+import (
+	"math"
+	"math/cmplx"
+	"slices"
+	"strings"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+// builtin is a gate implemented by the operations of the circuit.
+type builtin struct {
+	params int
+	qubits int
+	ops    func(p []float64, qb []q.Qubit) []circuit.Op
+}
+
+// language are the gates built into the language.
+// gphase has no ops since it depends on the control qubits.
+var language = map[string]builtin{
+	"U":      {3, 1, named("U")},
+	"CX":     {0, 2, cnot(1)},
+	"gphase": {1, 0, nil},
+}
+
+// qelib1 are the gates of qelib1.inc.
+var qelib1 = map[string]builtin{
+	"u3":    {3, 1, named("U")},
+	"u2":    {2, 1, u2},
+	"u1":    {1, 1, named("R")},
+	"u0":    {1, 1, u0},
+	"u":     {3, 1, named("U")},
+	"p":     {1, 1, named("R")},
+	"cx":    {0, 2, cnot(1)},
+	"id":    {0, 1, named("I")},
+	"x":     {0, 1, named("X")},
+	"y":     {0, 1, named("Y")},
+	"z":     {0, 1, named("Z")},
+	"h":     {0, 1, named("H")},
+	"s":     {0, 1, named("S")},
+	"sdg":   {0, 1, apply(func([]float64) *matrix.Matrix { return gate.S().Dagger() })},
+	"t":     {0, 1, named("T")},
+	"tdg":   {0, 1, apply(func([]float64) *matrix.Matrix { return gate.T().Dagger() })},
+	"sx":    {0, 1, apply(func([]float64) *matrix.Matrix { return sx() })},
+	"sxdg":  {0, 1, apply(func([]float64) *matrix.Matrix { return sx().Dagger() })},
+	"rx":    {1, 1, named("RX")},
+	"ry":    {1, 1, named("RY")},
+	"rz":    {1, 1, named("RZ")},
+	"cz":    {0, 2, cz},
+	"cy":    {0, 2, controlled(1, func([]float64) *matrix.Matrix { return gate.Y() })},
+	"ch":    {0, 2, controlled(1, func([]float64) *matrix.Matrix { return gate.H() })},
+	"swap":  {0, 2, named("Swap")},
+	"ccx":   {0, 3, cnot(2)},
+	"c3x":   {0, 4, cnot(3)},
+	"c4x":   {0, 5, cnot(4)},
+	"cswap": {0, 3, controlled(1, func([]float64) *matrix.Matrix { return gate.Swap(2, 0, 1) })},
+	"crx":   {1, 2, controlled(1, func(p []float64) *matrix.Matrix { return gate.RX(p[0]) })},
+	"cry":   {1, 2, controlled(1, func(p []float64) *matrix.Matrix { return gate.RY(p[0]) })},
+	"crz":   {1, 2, controlled(1, func(p []float64) *matrix.Matrix { return gate.RZ(p[0]) })},
+	"cu1":   {1, 2, controlledR},
+	"cp":    {1, 2, controlledR},
+	"cu3":   {3, 2, controlled(1, func(p []float64) *matrix.Matrix { return gate.U(p[0], p[1], p[2]) })},
+	"cu":    {4, 2, controlled(1, func(p []float64) *matrix.Matrix { return gate.U(p[0], p[1], p[2]).Mul(cmplx.Exp(complex(0, p[3]))) })},
+	"rxx":   {1, 2, applyOn(rxx)},
+	"rzz":   {1, 2, applyOn(rzz)},
+}
+
+// stdgates are the gates of stdgates.inc.
+var stdgates = map[string]builtin{
+	"p":      qelib1["p"],
+	"phase":  qelib1["p"],
+	"x":      qelib1["x"],
+	"y":      qelib1["y"],
+	"z":      qelib1["z"],
+	"h":      qelib1["h"],
+	"s":      qelib1["s"],
+	"sdg":    qelib1["sdg"],
+	"t":      qelib1["t"],
+	"tdg":    qelib1["tdg"],
+	"sx":     qelib1["sx"],
+	"rx":     qelib1["rx"],
+	"ry":     qelib1["ry"],
+	"rz":     qelib1["rz"],
+	"cx":     qelib1["cx"],
+	"cy":     qelib1["cy"],
+	"cz":     qelib1["cz"],
+	"cp":     qelib1["cp"],
+	"cphase": qelib1["cp"],
+	"crx":    qelib1["crx"],
+	"cry":    qelib1["cry"],
+	"crz":    qelib1["crz"],
+	"ch":     qelib1["ch"],
+	"cu":     qelib1["cu"],
+	"swap":   qelib1["swap"],
+	"ccx":    qelib1["ccx"],
+	"cswap":  qelib1["cswap"],
+	"id":     qelib1["id"],
+	"u1":     qelib1["u1"],
+	"u2":     qelib1["u2"],
+	"u3":     qelib1["u3"],
+}
+
+// sx returns the square root of the Pauli-X gate.
+func sx() *matrix.Matrix {
+	return matrix.New(
+		[]complex128{(1 + 1i) / 2, (1 - 1i) / 2},
+		[]complex128{(1 - 1i) / 2, (1 + 1i) / 2},
+	)
+}
+
+// rxx returns exp(-i * theta/2 * X⊗X).
+func rxx(theta float64) *matrix.Matrix {
+	c, s := complex(math.Cos(theta/2), 0), complex(0, -math.Sin(theta/2))
+	return matrix.New(
+		[]complex128{c, 0, 0, s},
+		[]complex128{0, c, s, 0},
+		[]complex128{0, s, c, 0},
+		[]complex128{s, 0, 0, c},
+	)
+}
+
+// rzz returns exp(-i * theta/2 * Z⊗Z).
+func rzz(theta float64) *matrix.Matrix {
+	e, f := cmplx.Exp(complex(0, -theta/2)), cmplx.Exp(complex(0, theta/2))
+	return matrix.New(
+		[]complex128{e, 0, 0, 0},
+		[]complex128{0, f, 0, 0},
+		[]complex128{0, 0, f, 0},
+		[]complex128{0, 0, 0, e},
+	)
+}
+
+func named(name string) func(p []float64, qb []q.Qubit) []circuit.Op {
+	return func(p []float64, qb []q.Qubit) []circuit.Op {
+		return []circuit.Op{{Name: name, Params: p, Qubits: qb}}
+	}
+}
+
+// u2 is U(pi/2, phi, lambda).
+func u2(p []float64, qb []q.Qubit) []circuit.Op {
+	return []circuit.Op{{Name: "U", Params: []float64{math.Pi / 2, p[0], p[1]}, Qubits: qb}}
+}
+
+// u0 is the identity gate that idles for a duration.
+func u0(_ []float64, qb []q.Qubit) []circuit.Op {
+	return []circuit.Op{{Name: "I", Qubits: qb}}
+}
+
+func cz(_ []float64, qb []q.Qubit) []circuit.Op {
+	return []circuit.Op{{Name: "ControlledZ", Controls: qb[:1], Qubits: qb[1:]}}
+}
+
+func cnot(c int) func(p []float64, qb []q.Qubit) []circuit.Op {
+	return func(_ []float64, qb []q.Qubit) []circuit.Op {
+		return []circuit.Op{{Name: "ControlledNot", Controls: qb[:c], Qubits: qb[c:]}}
+	}
+}
+
+func controlledR(p []float64, qb []q.Qubit) []circuit.Op {
+	return []circuit.Op{{Name: "ControlledR", Params: p, Controls: qb[:1], Qubits: qb[1:]}}
+}
+
+func apply(u func(p []float64) *matrix.Matrix) func(p []float64, qb []q.Qubit) []circuit.Op {
+	return func(p []float64, qb []q.Qubit) []circuit.Op {
+		return []circuit.Op{{Name: "Apply", Matrix: u(p), Qubits: qb}}
+	}
+}
+
+func applyOn(u func(theta float64) *matrix.Matrix) func(p []float64, qb []q.Qubit) []circuit.Op {
+	return func(p []float64, qb []q.Qubit) []circuit.Op {
+		return []circuit.Op{{Name: "ApplyOn", Matrix: u(p[0]), Qubits: qb}}
+	}
+}
+
+func controlled(c int, u func(p []float64) *matrix.Matrix) func(p []float64, qb []q.Qubit) []circuit.Op {
+	return func(p []float64, qb []q.Qubit) []circuit.Op {
+		return []circuit.Op{{Name: "Controlled", Matrix: u(p), Controls: qb[:c], Qubits: qb[c:]}}
+	}
+}
+
+// target returns the matrix that the operation applies to the target qubits.
+func target(op circuit.Op) *matrix.Matrix {
+	switch op.Name {
+	case "U":
+		return gate.U(op.Params[0], op.Params[1], op.Params[2])
+	case "I":
+		return gate.I()
+	case "X", "ControlledNot":
+		return gate.X()
+	case "Y":
+		return gate.Y()
+	case "Z", "ControlledZ":
+		return gate.Z()
+	case "H":
+		return gate.H()
+	case "S":
+		return gate.S()
+	case "T":
+		return gate.T()
+	case "R", "ControlledR":
+		return gate.R(op.Params[0])
+	case "RX":
+		return gate.RX(op.Params[0])
+	case "RY":
+		return gate.RY(op.Params[0])
+	case "RZ":
+		return gate.RZ(op.Params[0])
+	case "Swap":
+		return gate.Swap(2, 0, 1)
+	}
+
+	return op.Matrix
+}
+
+// control returns the operation controlled by the qubits ctrl.
+// state[i] is the state of ctrl[i] for which the operation is applied.
+func control(op circuit.Op, ctrl []q.Qubit, state string) circuit.Op {
+	s := op.State
+	if s == "" {
+		s = strings.Repeat("1", len(op.Controls))
+	}
+
+	ctrl, state = append(slices.Clone(ctrl), op.Controls...), state+s
+	if strings.Contains(state, "0") {
+		return circuit.Op{Name: "ControlledState", Matrix: target(op), Controls: ctrl, State: state, Qubits: op.Qubits}
+	}
+
+	switch op.Name {
+	case "X", "ControlledNot":
+		return circuit.Op{Name: "ControlledNot", Controls: ctrl, Qubits: op.Qubits}
+	case "Z", "ControlledZ":
+		return circuit.Op{Name: "ControlledZ", Controls: ctrl, Qubits: op.Qubits}
+	case "R", "ControlledR":
+		return circuit.Op{Name: "ControlledR", Params: op.Params, Controls: ctrl, Qubits: op.Qubits}
+	}
+
+	return circuit.Op{Name: "Controlled", Matrix: target(op), Controls: ctrl, Qubits: op.Qubits}
+}
@@ -0,0 +1,147 @@
+package qasm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type kind int
+
+const (
+	tokEOF kind = iota
+	tokIdent
+	tokInt
+	tokReal
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind   kind
+	text   string
+	line   int
+	column int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "EOF"
+	}
+
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Error is an error with the position in the source.
+type Error struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+// Error returns the error message prefixed with line and column.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// symbols are the two-character symbols. The others are single characters.
+var symbols = []string{"->", "==", "!=", "<=", ">=", "&&", "||", "++", "<<", ">>"}
+
+// lex splits the source into tokens.
+func lex(src string) ([]token, error) {
+	r := []rune(src)
+	digit := func(i int) bool { return i < len(r) && unicode.IsDigit(r[i]) }
+
+	tokens := make([]token, 0)
+	line, col := 1, 1
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case c == '\n':
+			i, line, col = i+1, line+1, 1
+		case unicode.IsSpace(c):
+			i, col = i+1, col+1
+		case strings.HasPrefix(string(r[i:min(i+2, len(r))]), "//"):
+			for i < len(r) && r[i] != '\n' {
+				i, col = i+1, col+1
+			}
+		case strings.HasPrefix(string(r[i:min(i+2, len(r))]), "/*"):
+			start, l, cl := i, line, col
+			for i, col = i+2, col+2; !strings.HasPrefix(string(r[i:min(i+2, len(r))]), "*/"); i, col = i+1, col+1 {
+				if i >= len(r) {
+					return nil, &Error{l, cl, fmt.Sprintf("unterminated comment %q", string(r[start:min(start+2, len(r))]))}
+				}
+
+				if r[i] == '\n' {
+					line, col = line+1, 0
+				}
+			}
+
+			i, col = i+2, col+2
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_') {
+				j++
+			}
+
+			tokens = append(tokens, token{tokIdent, string(r[i:j]), line, col})
+			i, col = j, col+j-i
+		case digit(i) || (c == '.' && digit(i+1)):
+			j, k := i, tokInt
+			for digit(j) {
+				j++
+			}
+
+			if j < len(r) && r[j] == '.' {
+				j, k = j+1, tokReal
+				for digit(j) {
+					j++
+				}
+			}
+
+			if j < len(r) && (r[j] == 'e' || r[j] == 'E') {
+				e := j + 1
+				if e < len(r) && (r[e] == '+' || r[e] == '-') {
+					e++
+				}
+
+				if digit(e) {
+					j, k = e, tokReal
+					for digit(j) {
+						j++
+					}
+				}
+			}
+
+			tokens = append(tokens, token{k, string(r[i:j]), line, col})
+			i, col = j, col+j-i
+		case c == '"':
+			j := i + 1
+			for j < len(r) && r[j] != '"' && r[j] != '\n' {
+				j++
+			}
+
+			if j == len(r) || r[j] != '"' {
+				return nil, &Error{line, col, "unterminated string"}
+			}
+
+			tokens = append(tokens, token{tokString, string(r[i+1 : j]), line, col})
+			i, col = j+1, col+j+1-i
+		case strings.ContainsRune(";,()[]{}+-*/^=<>@:!&|%~", c):
+			s := string(c)
+			for _, sym := range symbols {
+				if strings.HasPrefix(string(r[i:min(i+2, len(r))]), sym) {
+					s = sym
+					break
+				}
+			}
+
+			tokens = append(tokens, token{tokSymbol, s, line, col})
+			i, col = i+len(s), col+len(s)
+		default:
+			return nil, &Error{line, col, fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+
+	return append(tokens, token{tokEOF, "", line, col}), nil
+}
@@ -0,0 +1,877 @@
+package qasm
+
+import (
+	"fmt"
+	"math/cmplx"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+// definition is a gate definition.
+type definition struct {
+	builtin
+	name   string
+	args   []string    // formal parameter names of the gate declaration.
+	body   []statement // body of the gate declaration.
+	opaque bool
+}
+
+// statement is a gate call in the body of a gate declaration.
+type statement struct {
+	tok    token
+	name   string
+	state  string // state of the control qubits given by the modifiers.
+	params []expr
+	qubits []int // index of the formal qubit arguments. The control qubits come first.
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	c      *circuit.Circuit
+	qreg   map[string][]q.Qubit
+	creg   map[string]circuit.Bits
+	gates  map[string]*definition
+}
+
+// Parse parses the OpenQASM 2.0 program and returns the circuit.
+// The gates are mapped to the operations of the circuit, and the circuit can be run on q.Q.
+// The subset of OpenQASM 3 written by Emit is also accepted.
+//
+//	OPENQASM 2.0;
+//	include "qelib1.inc";
+//	qreg q[2];
+//	creg c[2];
+//	h q[0];
+//	cx q[0], q[1];
+//	measure q -> c;
+func Parse(src string) (*circuit.Circuit, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		c:      circuit.New(),
+		qreg:   make(map[string][]q.Qubit),
+		creg:   make(map[string]circuit.Bits),
+		gates:  make(map[string]*definition),
+	}
+
+	for name, g := range language {
+		p.gates[name] = &definition{builtin: g, name: name}
+	}
+
+	if err := p.program(); err != nil {
+		return nil, err
+	}
+
+	return p.c, nil
+}
+
+func (p *parser) program() error {
+	if p.peek("OPENQASM") {
+		p.next()
+		if v := p.next(); !slices.Contains([]string{"2", "2.0", "3", "3.0"}, v.text) {
+			return p.errorf(v, "unsupported version %v", v)
+		}
+
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+	}
+
+	for p.tokens[p.pos].kind != tokEOF {
+		if err := p.statement(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) statement() error {
+	t := p.tokens[p.pos]
+	switch t.text {
+	case "include":
+		return p.include()
+	case "qreg", "creg":
+		return p.register()
+	case "qubit", "bit":
+		return p.variable()
+	case "gate", "opaque":
+		return p.declaration()
+	case "if":
+		return p.conditional()
+	case "barrier":
+		p.next()
+		if _, err := p.arguments(); err != nil {
+			return err
+		}
+
+		return p.expect(";")
+	}
+
+	return p.operation(nil)
+}
+
+func (p *parser) include() error {
+	p.next()
+	t := p.next()
+	if t.kind != tokString {
+		return p.errorf(t, "expected file name, got %v", t)
+	}
+
+	lib, ok := map[string]map[string]builtin{
+		"qelib1.inc":   qelib1,
+		"stdgates.inc": stdgates,
+	}[t.text]
+	if !ok {
+		return p.errorf(t, "unsupported include %v", t)
+	}
+
+	for name, g := range lib {
+		p.gates[name] = &definition{builtin: g, name: name}
+	}
+
+	return p.expect(";")
+}
+
+// register parses qreg q[n]; and creg c[n];.
+func (p *parser) register() error {
+	kind := p.next().text
+	t, err := p.identifier()
+	if err != nil {
+		return err
+	}
+
+	if err := p.expect("["); err != nil {
+		return err
+	}
+
+	n, err := p.integer()
+	if err != nil {
+		return err
+	}
+
+	if err := p.expect("]"); err != nil {
+		return err
+	}
+
+	if err := p.declare(t, kind == "qreg", n); err != nil {
+		return err
+	}
+
+	return p.expect(";")
+}
+
+// variable parses qubit[n] q; and bit[n] c;.
+func (p *parser) variable() error {
+	kind, n := p.next().text, 1
+	if p.peek("[") {
+		p.next()
+
+		var err error
+		if n, err = p.integer(); err != nil {
+			return err
+		}
+
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	}
+
+	t, err := p.identifier()
+	if err != nil {
+		return err
+	}
+
+	if err := p.declare(t, kind == "qubit", n); err != nil {
+		return err
+	}
+
+	return p.expect(";")
+}
+
+// declare allocates n qubits or classical bits for the register.
+func (p *parser) declare(t token, quantum bool, n int) error {
+	if _, ok := p.qreg[t.text]; ok {
+		return p.errorf(t, "register %v already declared", t)
+	}
+
+	if _, ok := p.creg[t.text]; ok {
+		return p.errorf(t, "register %v already declared", t)
+	}
+
+	if quantum {
+		p.qreg[t.text] = p.c.Zeros(n)
+		return nil
+	}
+
+	p.creg[t.text] = p.c.Clbits(n)
+	return nil
+}
+
+func (p *parser) declaration() error {
+	opaque := p.next().text == "opaque"
+	t, err := p.identifier()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.gates[t.text]; ok {
+		return p.errorf(t, "gate %v already defined", t)
+	}
+
+	var params []string
+	if p.peek("(") {
+		p.next()
+		if !p.peek(")") {
+			if params, err = p.identifiers(); err != nil {
+				return err
+			}
+		}
+
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+	}
+
+	args, err := p.identifiers()
+	if err != nil {
+		return err
+	}
+
+	def := &definition{
+		builtin: builtin{params: len(params), qubits: len(args)},
+		name:    t.text,
+		args:    params,
+		opaque:  opaque,
+	}
+
+	if opaque {
+		p.gates[t.text] = def
+		return p.expect(";")
+	}
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	scope := make(map[string]bool)
+	for _, a := range params {
+		scope[a] = true
+	}
+
+	for !p.peek("}") {
+		s, err := p.body(scope, args)
+		if err != nil {
+			return err
+		}
+
+		if s != nil {
+			def.body = append(def.body, *s)
+		}
+	}
+
+	p.next()
+	p.gates[t.text] = def
+	return nil
+}
+
+// body parses a statement in the body of a gate declaration.
+func (p *parser) body(scope map[string]bool, args []string) (*statement, error) {
+	state, err := p.modifiers()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	var params []expr
+	if t.text != "barrier" && p.peek("(") {
+		if params, err = p.expressions(scope); err != nil {
+			return nil, err
+		}
+	}
+
+	var qubits []int
+	for !p.peek(";") {
+		if len(qubits) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		a, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+
+		i := slices.Index(args, a.text)
+		if i < 0 {
+			return nil, p.errorf(a, "undefined qubit argument %v", a)
+		}
+
+		qubits = append(qubits, i)
+	}
+
+	p.next()
+	if t.text == "barrier" {
+		return nil, nil
+	}
+
+	if err := p.check(t, len(params), len(qubits)-len(state)); err != nil {
+		return nil, err
+	}
+
+	return &statement{tok: t, name: t.text, state: state, params: params, qubits: qubits}, nil
+}
+
+// modifiers parses the control modifiers such as ctrl @ and negctrl(2) @,
+// and returns the state of the control qubits.
+func (p *parser) modifiers() (string, error) {
+	var state string
+	for p.peek("ctrl") || p.peek("negctrl") {
+		bit, n := map[string]string{"ctrl": "1", "negctrl": "0"}[p.next().text], 1
+		if p.peek("(") {
+			p.next()
+
+			var err error
+			if n, err = p.integer(); err != nil {
+				return "", err
+			}
+
+			if err := p.expect(")"); err != nil {
+				return "", err
+			}
+		}
+
+		if err := p.expect("@"); err != nil {
+			return "", err
+		}
+
+		state += strings.Repeat(bit, n)
+	}
+
+	return state, nil
+}
+
+// conditional parses if(c==n) followed by an operation or a block of operations.
+// The condition may be a conjunction of the comparisons such as c[0] == 1 && c[1] == 0.
+func (p *parser) conditional() error {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	var bits circuit.Bits
+	var v int
+	for {
+		b, n, err := p.comparison()
+		if err != nil {
+			return err
+		}
+
+		bits, v = append(bits, b...), v<<len(b)|n
+		if !p.peek("&&") {
+			break
+		}
+
+		p.next()
+	}
+
+	if err := p.expect(")"); err != nil {
+		return err
+	}
+
+	cond := bits.Eq(v)
+	if !p.peek("{") {
+		return p.operation(&cond)
+	}
+
+	p.next()
+	for !p.peek("}") {
+		if p.tokens[p.pos].kind == tokEOF {
+			return p.expect("}")
+		}
+
+		if err := p.operation(&cond); err != nil {
+			return err
+		}
+	}
+
+	p.next()
+	return nil
+}
+
+// comparison parses c == n, c[i] == n, c[i] or !c[i].
+// It returns the classical bits in the most significant bit first order and the value.
+func (p *parser) comparison() (circuit.Bits, int, error) {
+	neg := p.peek("!")
+	if neg {
+		p.next()
+	}
+
+	t, err := p.identifier()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reg, ok := p.creg[t.text]
+	if !ok {
+		return nil, 0, p.errorf(t, "undefined classical register %v", t)
+	}
+
+	i, indexed, err := p.index(len(reg))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// c[0] is the least significant bit in OpenQASM.
+	bits := slices.Clone(reg)
+	slices.Reverse(bits)
+	if indexed || len(reg) == 1 {
+		bits, indexed = reg[i:i+1], true
+	}
+
+	if indexed && (neg || !p.peek("==")) {
+		if neg {
+			return bits, 0, nil
+		}
+
+		return bits, 1, nil
+	}
+
+	if err := p.expect("=="); err != nil {
+		return nil, 0, err
+	}
+
+	v, err := p.integer()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return bits, v, nil
+}
+
+// operation parses a gate call, measure or reset.
+func (p *parser) operation(cond *circuit.Cond) error {
+	state, err := p.modifiers()
+	if err != nil {
+		return err
+	}
+
+	t, err := p.identifier()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.creg[t.text]; ok && state == "" {
+		// c[0] = measure q[0];
+		b, err := p.clbitsOf(t)
+		if err != nil {
+			return err
+		}
+
+		if err := p.expect("="); err != nil {
+			return err
+		}
+
+		if err := p.expect("measure"); err != nil {
+			return err
+		}
+
+		return p.measure(t, b, cond)
+	}
+
+	switch {
+	case t.text == "measure" && state == "":
+		qb, err := p.qubits()
+		if err != nil {
+			return err
+		}
+
+		if err := p.expect("->"); err != nil {
+			return err
+		}
+
+		b, err := p.clbits()
+		if err != nil {
+			return err
+		}
+
+		if len(qb) != len(b) {
+			return p.errorf(t, "size mismatch: %d qubits, %d classical bits", len(qb), len(b))
+		}
+
+		p.c.Add(circuit.Op{Name: "Measure", Qubits: qb, Clbits: b, Cond: cond})
+		return p.expect(";")
+	case t.text == "reset" && state == "":
+		qb, err := p.qubits()
+		if err != nil {
+			return err
+		}
+
+		p.c.Add(circuit.Op{Name: "Reset", Qubits: qb, Cond: cond})
+		return p.expect(";")
+	}
+
+	var params []float64
+	if p.peek("(") {
+		exprs, err := p.expressions(nil)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range exprs {
+			params = append(params, e(nil))
+		}
+	}
+
+	var args [][]q.Qubit
+	if !p.peek(";") {
+		if args, err = p.arguments(); err != nil {
+			return err
+		}
+	}
+
+	if len(args) < len(state) {
+		return p.errorf(t, "%d control qubits, got %d arguments", len(state), len(args))
+	}
+
+	if err := p.check(t, len(params), len(args)-len(state)); err != nil {
+		return err
+	}
+
+	// broadcast over the registers
+	size := 1
+	for _, a := range args {
+		if len(a) == 1 {
+			continue
+		}
+
+		if size != 1 && size != len(a) {
+			return p.errorf(t, "register size mismatch: %d, %d", size, len(a))
+		}
+
+		size = len(a)
+	}
+
+	for i := range size {
+		qb := make([]q.Qubit, len(args))
+		for j, a := range args {
+			qb[j] = a[min(i, len(a)-1)]
+		}
+
+		for j := range qb {
+			if slices.Contains(qb[j+1:], qb[j]) {
+				return p.errorf(t, "duplicate qubit argument %v", qb[j])
+			}
+		}
+
+		n := len(state)
+		if err := p.call(t, p.gates[t.text], params, qb[:n], state, qb[n:], cond); err != nil {
+			return err
+		}
+	}
+
+	return p.expect(";")
+}
+
+// measure parses the qubits of c = measure q; and appends the measurement.
+func (p *parser) measure(t token, b circuit.Bits, cond *circuit.Cond) error {
+	qb, err := p.qubits()
+	if err != nil {
+		return err
+	}
+
+	if len(qb) != len(b) {
+		return p.errorf(t, "size mismatch: %d qubits, %d classical bits", len(qb), len(b))
+	}
+
+	p.c.Add(circuit.Op{Name: "Measure", Qubits: qb, Clbits: b, Cond: cond})
+	return p.expect(";")
+}
+
+// call appends the operations of the gate g controlled by the qubits ctrl in the state.
+func (p *parser) call(t token, g *definition, params []float64, ctrl []q.Qubit, state string, qb []q.Qubit, cond *circuit.Cond) error {
+	if g.opaque {
+		return p.errorf(t, "opaque gate %v is not supported", t)
+	}
+
+	if g.name == "gphase" {
+		return p.gphase(t, params[0], ctrl, state, cond)
+	}
+
+	if g.ops != nil {
+		for _, op := range g.ops(params, qb) {
+			if len(ctrl) > 0 {
+				op = control(op, ctrl, state)
+			}
+
+			op.Cond = cond
+			p.c.Add(op)
+		}
+
+		return nil
+	}
+
+	env := make(map[string]float64)
+	for i, a := range g.args {
+		env[a] = params[i]
+	}
+
+	for _, s := range g.body {
+		v := make([]float64, len(s.params))
+		for i, e := range s.params {
+			v[i] = e(env)
+		}
+
+		b := make([]q.Qubit, len(s.qubits))
+		for i, j := range s.qubits {
+			b[i] = qb[j]
+		}
+
+		n := len(s.state)
+		if err := p.call(s.tok, p.gates[s.name], v, append(slices.Clone(ctrl), b[:n]...), state+s.state, b[n:], cond); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gphase appends the global phase gate.
+// The global phase controlled by the qubits is the phase gate on the last control qubit.
+func (p *parser) gphase(t token, gamma float64, ctrl []q.Qubit, state string, cond *circuit.Cond) error {
+	e := cmplx.Exp(complex(0, gamma))
+	if len(ctrl) == 0 {
+		if p.c.NumQubits() == 0 {
+			return p.errorf(t, "gphase requires at least one qubit")
+		}
+
+		p.c.Add(circuit.Op{Name: "Apply", Matrix: gate.I().Mul(e), Qubits: []q.Qubit{0}, Cond: cond})
+		return nil
+	}
+
+	n := len(ctrl) - 1
+	op := circuit.Op{Name: "R", Params: []float64{gamma}, Qubits: ctrl[n:]}
+	if state[n] == '0' {
+		op = circuit.Op{Name: "Apply", Matrix: gate.New([]complex128{e, 0}, []complex128{0, 1}), Qubits: ctrl[n:]}
+	}
+
+	if n > 0 {
+		op = control(op, ctrl[:n], state[:n])
+	}
+
+	op.Cond = cond
+	p.c.Add(op)
+	return nil
+}
+
+// check returns an error if the gate is undefined or the number of arguments does not match.
+func (p *parser) check(t token, params, qubits int) error {
+	g, ok := p.gates[t.text]
+	if !ok {
+		return p.errorf(t, "undefined gate %v", t)
+	}
+
+	if g.params != params {
+		return p.errorf(t, "gate %v takes %d parameters, got %d", t, g.params, params)
+	}
+
+	if g.qubits != qubits {
+		return p.errorf(t, "gate %v takes %d qubits, got %d", t, g.qubits, qubits)
+	}
+
+	return nil
+}
+
+// arguments parses a comma separated list of qubit arguments.
+func (p *parser) arguments() ([][]q.Qubit, error) {
+	var args [][]q.Qubit
+	for {
+		qb, err := p.qubits()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, qb)
+		if !p.peek(",") {
+			return args, nil
+		}
+
+		p.next()
+	}
+}
+
+// qubits parses a qubit argument such as q or q[0].
+func (p *parser) qubits() ([]q.Qubit, error) {
+	t, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	reg, ok := p.qreg[t.text]
+	if !ok {
+		return nil, p.errorf(t, "undefined quantum register %v", t)
+	}
+
+	i, ok, err := p.index(len(reg))
+	if err != nil || !ok {
+		return reg, err
+	}
+
+	return reg[i : i+1], nil
+}
+
+// clbits parses a classical bit argument such as c or c[0].
+func (p *parser) clbits() (circuit.Bits, error) {
+	t, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.clbitsOf(t)
+}
+
+// clbitsOf parses the optional index of the classical register t.
+func (p *parser) clbitsOf(t token) (circuit.Bits, error) {
+	reg, ok := p.creg[t.text]
+	if !ok {
+		return nil, p.errorf(t, "undefined classical register %v", t)
+	}
+
+	i, ok, err := p.index(len(reg))
+	if err != nil || !ok {
+		return reg, err
+	}
+
+	return reg[i : i+1], nil
+}
+
+// index parses an optional index such as [0].
+func (p *parser) index(size int) (int, bool, error) {
+	if !p.peek("[") {
+		return 0, false, nil
+	}
+
+	p.next()
+	t := p.tokens[p.pos]
+	i, err := p.integer()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if i >= size {
+		return 0, false, p.errorf(t, "index %d out of range [0, %d)", i, size)
+	}
+
+	return i, true, p.expect("]")
+}
+
+func (p *parser) expressions(scope map[string]bool) ([]expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var exprs []expr
+	for !p.peek(")") {
+		if len(exprs) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		e, err := p.expr(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		exprs = append(exprs, e)
+	}
+
+	p.next()
+	return exprs, nil
+}
+
+func (p *parser) identifiers() ([]string, error) {
+	var ids []string
+	for {
+		t, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, t.text)
+		if !p.peek(",") {
+			return ids, nil
+		}
+
+		p.next()
+	}
+}
+
+func (p *parser) identifier() (token, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return t, p.errorf(t, "expected identifier, got %v", t)
+	}
+
+	return t, nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.next()
+	if t.kind != tokInt {
+		return 0, p.errorf(t, "expected integer, got %v", t)
+	}
+
+	v, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, p.errorf(t, "invalid integer %v", t)
+	}
+
+	return v, nil
+}
+
+func (p *parser) expect(text string) error {
+	t := p.next()
+	if t.text != text || (t.kind != tokSymbol && t.kind != tokIdent) {
+		return p.errorf(t, "expected %q, got %v", text, t)
+	}
+
+	return nil
+}
+
+func (p *parser) peek(text string) bool {
+	t := p.tokens[p.pos]
+	return t.kind != tokEOF && t.kind != tokString && t.text == text
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) errorf(t token, format string, a ...any) error {
+	return &Error{
+		Line:   t.line,
+		Column: t.column,
+		Msg:    fmt.Sprintf(format, a...),
+	}
+}
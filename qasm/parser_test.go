@@ -0,0 +1,281 @@
+package qasm_test
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/qasm"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+func ExampleParse() {
+	c, err := qasm.Parse(`
+OPENQASM 2.0;
+include "qelib1.inc";
+
+qreg q[2];
+creg c[2];
+
+h q[0];
+cx q[0], q[1];
+measure q -> c;
+`)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, op := range c.Ops {
+		fmt.Println(op)
+	}
+
+	qsim := q.New()
+	qsim.Rand = rand.Const()
+	fmt.Println(c.Run(qsim))
+
+	// Output:
+	// New[(1+0i) (0+0i)] [0]
+	// New[(1+0i) (0+0i)] [1]
+	// H [0]
+	// ControlledNot [0] [1]
+	// Measure [0 1] -> [0 1]
+	// [1 1]
+}
+
+func ExampleParse_gate() {
+	c, err := qasm.Parse(`
+OPENQASM 2.0;
+include "qelib1.inc";
+
+gate bell a, b {
+  h a;
+  cx a, b;
+}
+
+qreg q[2];
+bell q[0], q[1];
+`)
+	if err != nil {
+		panic(err)
+	}
+
+	qsim := q.New()
+	c.Run(qsim)
+
+	for _, s := range qsim.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+}
+
+func ExampleParse_if() {
+	// quantum teleportation
+	c, err := qasm.Parse(`
+OPENQASM 2.0;
+include "qelib1.inc";
+
+qreg q[3];
+creg c0[1];
+creg c1[1];
+
+u3(2.214297435588181, 0, 0) q[0];
+h q[1];
+cx q[1], q[2];
+cx q[0], q[1];
+h q[0];
+measure q[0] -> c0[0];
+measure q[1] -> c1[0];
+if(c1==1) x q[2];
+if(c0==1) z q[2];
+`)
+	if err != nil {
+		panic(err)
+	}
+
+	qsim := q.New()
+	qsim.Rand = rand.Const()
+	c.Run(qsim)
+
+	for _, s := range qsim.State(q.Qubit(2)) {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [0][  0]( 0.4472 0.0000i): 0.2000
+	// [1][  1]( 0.8944 0.0000i): 0.8000
+}
+
+func ExampleError() {
+	_, err := qasm.Parse(`OPENQASM 2.0;
+include "qelib1.inc";
+qreg q[2];
+cx q[0], q[2];
+`)
+
+	fmt.Println(err)
+
+	// Output:
+	// 4:12: index 2 out of range [0, 2)
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		src  string
+		want func(qsim *q.Q, qb []q.Qubit)
+	}{
+		{
+			`x q[0]; y q[1]; z q[2]; h q; s q[0]; t q[1]; id q[2];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.X(qb[0]).Y(qb[1]).Z(qb[2]).H(qb...).S(qb[0]).T(qb[1]).I(qb[2])
+			},
+		},
+		{
+			`h q; sdg q[0]; tdg q[1]; sx q[2]; sxdg q[0];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				sx := gate.New([]complex128{(1 + 1i) / 2, (1 - 1i) / 2}, []complex128{(1 - 1i) / 2, (1 + 1i) / 2})
+				qsim.H(qb...).Apply(gate.S().Dagger(), qb[0]).Apply(gate.T().Dagger(), qb[1]).Apply(sx, qb[2]).Apply(sx.Dagger(), qb[0])
+			},
+		},
+		{
+			`u3(1, 2, 3) q[0]; u2(0.5, -pi/4) q[1]; u1(pi/8) q[2]; U(0.1, 0.2, 0.3) q[1]; p(0.7) q[0]; u(1, 1, 1) q[2];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.U(1, 2, 3, qb[0]).U(math.Pi/2, 0.5, -math.Pi/4, qb[1]).R(math.Pi/8, qb[2])
+				qsim.U(0.1, 0.2, 0.3, qb[1]).R(0.7, qb[0]).U(1, 1, 1, qb[2])
+			},
+		},
+		{
+			`rx(pi/3) q[0]; ry(2^-1) q[1]; rz(-(1+2)*3/4) q[2]; rx(sin(1)+cos(2)-sqrt(3)*exp(0.1)/ln(4)+tan(0.2)) q[0];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.RX(math.Pi/3, qb[0]).RY(0.5, qb[1]).RZ(-(1+2)*3.0/4, qb[2])
+				qsim.RX(math.Sin(1)+math.Cos(2)-math.Sqrt(3)*math.Exp(0.1)/math.Log(4)+math.Tan(0.2), qb[0])
+			},
+		},
+		{
+			`h q; cx q[0], q[1]; CX q[1], q[2]; cz q[2], q[0]; cy q[0], q[2]; ch q[1], q[0]; ccx q[0], q[1], q[2]; swap q[0], q[2]; cswap q[1], q[0], q[2];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).CNOT(qb[0], qb[1]).CNOT(qb[1], qb[2]).CZ(qb[2], qb[0]).C(gate.Y(), qb[0], qb[2]).C(gate.H(), qb[1], qb[0])
+				qsim.CCNOT(qb[0], qb[1], qb[2]).Swap(qb[0], qb[2]).Controlled(gate.Swap(2, 0, 1), []q.Qubit{qb[1]}, qb[0], qb[2])
+			},
+		},
+		{
+			`h q; crx(0.1) q[0], q[1]; cry(0.2) q[1], q[2]; crz(0.3) q[2], q[0]; cu1(0.4) q[0], q[2]; cp(0.5) q[1], q[0]; cu3(1, 2, 3) q[2], q[1];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).C(gate.RX(0.1), qb[0], qb[1]).C(gate.RY(0.2), qb[1], qb[2]).C(gate.RZ(0.3), qb[2], qb[0])
+				qsim.CR(0.4, qb[0], qb[2]).CR(0.5, qb[1], qb[0]).C(gate.U(1, 2, 3), qb[2], qb[1])
+			},
+		},
+		{
+			`h q; rzz(0.3) q[0], q[2]; rxx(0.4) q[1], q[0];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				zz := func(theta float64) *matrix.Matrix {
+					e, f := cmplx.Exp(complex(0, -theta/2)), cmplx.Exp(complex(0, theta/2))
+					return gate.New(
+						[]complex128{e, 0, 0, 0},
+						[]complex128{0, f, 0, 0},
+						[]complex128{0, 0, f, 0},
+						[]complex128{0, 0, 0, e},
+					)
+				}
+
+				// XX = (H⊗H)ZZ(H⊗H)
+				qsim.H(qb...).ApplyOn(zz(0.3), qb[0], qb[2])
+				qsim.H(qb[1], qb[0]).ApplyOn(zz(0.4), qb[1], qb[0]).H(qb[1], qb[0])
+			},
+		},
+		{
+			`gate g(a, b) x, y { rx(a*b) x; barrier x, y; cx x, y; ry(-a) y; } h q; g(0.3, 2) q[1], q[2]; g(pi, 1) q[0], q[1];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb...).RX(0.6, qb[1]).CNOT(qb[1], qb[2]).RY(-0.3, qb[2])
+				qsim.RX(math.Pi, qb[0]).CNOT(qb[0], qb[1]).RY(-math.Pi, qb[1])
+			},
+		},
+		{
+			`gate a x { h x; } gate b x, y { a x; cx x, y; } b q[1], q[2]; barrier q; reset q[1];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.H(qb[1]).CNOT(qb[1], qb[2]).Reset(qb[1])
+			},
+		},
+		{
+			`creg c[2]; x q[0]; measure q[0] -> c[1]; if(c==2) x q[1]; if(c==1) x q[2]; if(c==2) h q;`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.X(qb[0]).Measure(qb[0])
+				qsim.X(qb[1]).H(qb...)
+			},
+		},
+		{
+			`creg c[3]; x q[1]; measure q -> c; if(c==2) cx q[1], q[0];`,
+			func(qsim *q.Q, qb []q.Qubit) {
+				qsim.X(qb[1]).Measure(qb...)
+				qsim.CNOT(qb[1], qb[0])
+			},
+		},
+	}
+
+	for _, c := range cases {
+		src := `OPENQASM 2.0; include "qelib1.inc"; qreg q[3];` + c.src
+		circ, err := qasm.Parse(src)
+		if err != nil {
+			t.Errorf("src=%q, err=%v", c.src, err)
+			continue
+		}
+
+		got := q.New()
+		got.Rand = rand.Const(1)
+		circ.Run(got)
+
+		want := q.New()
+		want.Rand = rand.Const(1)
+		c.want(want, want.Zeros(3))
+
+		for i, a := range got.Amplitude() {
+			if cmplx.Abs(a-want.Amplitude()[i]) > epsilon.E13() {
+				t.Errorf("src=%q, got=%v, want=%v", c.src, got.Amplitude(), want.Amplitude())
+				break
+			}
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"OPENQASM 4.0;", "1:10: unsupported version \"4.0\""},
+		{"OPENQASM 2.0;\ninclude \"qelib2.inc\";", "2:9: unsupported include \"qelib2.inc\""},
+		{"qreg q[2];\nh q[0];", "2:1: undefined gate \"h\""},
+		{"include \"qelib1.inc\";\nqreg q[2];\nh r[0];", "3:3: undefined quantum register \"r\""},
+		{"include \"qelib1.inc\";\nqreg q[2];\ncx q[0];", "3:1: gate \"cx\" takes 2 qubits, got 1"},
+		{"include \"qelib1.inc\";\nqreg q[2];\nrx q[0];", "3:1: gate \"rx\" takes 1 parameters, got 0"},
+		{"include \"qelib1.inc\";\nqreg q[2];\nrx(theta) q[0];", "3:4: undefined parameter \"theta\""},
+		{"include \"qelib1.inc\";\nqreg q[2];\ncx q[1], q[1];", "3:1: duplicate qubit argument 1"},
+		{"include \"qelib1.inc\";\nqreg q[2];\nqreg r[3];\ncx q, r;", "4:1: register size mismatch: 2, 3"},
+		{"include \"qelib1.inc\";\nqreg q[2];\nh q[0]\nh q[1];", "4:1: expected \";\", got \"h\""},
+		{"qreg q[2];\ncreg c[1];\nmeasure q -> c;", "3:1: size mismatch: 2 qubits, 1 classical bits"},
+		{"qreg q[2];\ncreg c[1];\nif(d==1) U(0, 0, 0) q[0];", "3:4: undefined classical register \"d\""},
+		{"qreg q[2];\nqreg q[1];", "2:6: register \"q\" already declared"},
+		{"gate g a { U(0, 0, 0) b; }", "1:23: undefined qubit argument \"b\""},
+		{"gate g a { h a; }", "1:12: undefined gate \"h\""},
+		{"opaque g a;\nqreg q[1];\ng q[0];", "3:1: opaque gate \"g\" is not supported"},
+		{"qreg q[1];\nU(0, 0, 0) q[0]; $", "2:18: unexpected character '$'"},
+		{"qreg q[1];\n/* comment\n", "2:1: unterminated comment \"/*\""},
+		{"include \"qelib1.inc", "1:9: unterminated string"},
+		{"qreg q[1];\nU(0, 0,) q[0];", "2:8: unexpected \")\" in expression"},
+		{"qreg q[1];\nU(0, 0, 0) q[0]", "2:16: expected \";\", got EOF"},
+	}
+
+	for _, c := range cases {
+		_, err := qasm.Parse(c.src)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("src=%q, got=%v, want=%v", c.src, err, c.want)
+		}
+	}
+}
@@ -5,16 +5,26 @@ import (
 	"iter"
 	"math"
 	"math/cmplx"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/itsubaki/q/internal/index"
 	"github.com/itsubaki/q/math/epsilon"
 	"github.com/itsubaki/q/math/matrix"
 	"github.com/itsubaki/q/math/number"
-	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/noise"
 	"github.com/itsubaki/q/quantum/qubit"
 )
 
+var (
+	ErrInvalidDimension = index.ErrInvalidDimension
+	ErrInvalidIndex     = index.ErrInvalidIndex
+	ErrInvalidState     = index.ErrInvalidState
+	ErrNotBijection     = index.ErrNotBijection
+)
+
 // Qubit is a quantum bit.
 type Qubit int
 
@@ -25,7 +35,8 @@ func (q Qubit) Index() int {
 
 // Matrix is a density matrix.
 type Matrix struct {
-	rho *matrix.Matrix
+	rho  *matrix.Matrix
+	Rand func() float64 // Random number generator
 }
 
 // New returns a new density matrix.
@@ -40,7 +51,8 @@ func New(ensemble []State) *Matrix {
 	}
 
 	return &Matrix{
-		rho: rho,
+		rho:  rho,
+		Rand: rand.Float64,
 	}
 }
 
@@ -54,6 +66,15 @@ func NewPureState(qb *qubit.Qubit) *Matrix {
 	})
 }
 
+// FromMatrix returns a density matrix of rho.
+// rho is not copied, and it is not checked to be positive semidefinite with unit trace.
+func FromMatrix(rho *matrix.Matrix) *Matrix {
+	return &Matrix{
+		rho:  rho,
+		Rand: rand.Float64,
+	}
+}
+
 // Qubits returns the qubits of the density matrix.
 func (m *Matrix) Qubits() []Qubit {
 	n := m.NumQubits()
@@ -136,6 +157,266 @@ func (m *Matrix) Apply(u *matrix.Matrix) *Matrix {
 	return m
 }
 
+// Zero returns a density matrix of n qubits in the zero state.
+func Zero(n int) *Matrix {
+	return NewPureState(qubit.Zero(n))
+}
+
+// NewFrom returns a density matrix of the computational basis state such as "0101".
+func NewFrom(binary string) *Matrix {
+	return NewPureState(qubit.NewFrom(binary))
+}
+
+// Clone returns a clone of the density matrix.
+func (m *Matrix) Clone() *Matrix {
+	return &Matrix{
+		rho:  m.rho.Clone(),
+		Rand: m.Rand,
+	}
+}
+
+// ApplyOn applies the (2**k x 2**k) unitary u to the k target qubits.
+// target[0] corresponds to the most significant bit of u.
+func (m *Matrix) ApplyOn(u *matrix.Matrix, target ...Qubit) *Matrix {
+	return m.Controlled(u, nil, target...)
+}
+
+// Controlled applies u to the target qubits if all control qubits are |1>.
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+// It panics if the dimension of u does not match, or the qubits are not distinct.
+func (m *Matrix) Controlled(u *matrix.Matrix, control []Qubit, target ...Qubit) *Matrix {
+	return m.ControlledState(u, control, strings.Repeat("1", len(control)), target...)
+}
+
+// ControlledState applies u to the target qubits if the control qubits are in the given state.
+// state is a binary string such as "10", and state[i] is the state of control[i].
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+func (m *Matrix) ControlledState(u *matrix.Matrix, control []Qubit, state string, target ...Qubit) *Matrix {
+	if err := index.Validate(u, m.NumQubits(), ints(control), ints(target)); err != nil {
+		panic(err)
+	}
+
+	if err := index.State(state, ints(control)); err != nil {
+		panic(err)
+	}
+
+	m.sandwich(m.rho.Data, u, control, state, target)
+	return m
+}
+
+// ApplyKraus applies the channel of the Kraus operators to the target qubits.
+// It maps rho to sum(K * rho * K^dagger), where K is a (2**k x 2**k) matrix on the k target qubits.
+func (m *Matrix) ApplyKraus(kraus []*matrix.Matrix, target ...Qubit) *Matrix {
+	for _, k := range kraus {
+		if err := index.Validate(k, m.NumQubits(), nil, ints(target)); err != nil {
+			panic(err)
+		}
+	}
+
+	data := make([]complex128, len(m.rho.Data))
+	for _, k := range kraus {
+		rho := slices.Clone(m.rho.Data)
+		m.sandwich(rho, k, nil, "", target)
+
+		for i := range data {
+			data[i] += rho[i]
+		}
+	}
+
+	m.rho = &matrix.Matrix{Rows: m.rho.Rows, Cols: m.rho.Cols, Data: data}
+	return m
+}
+
+// sandwich updates data of rho to u * rho * u^dagger, where u is applied if the control qubits are in the state.
+func (m *Matrix) sandwich(data []complex128, u *matrix.Matrix, control []Qubit, state string, target []Qubit) {
+	// data is the vector of 2n qubits |i>|j> for rho[i][j].
+	// u * rho * u^dagger applies u to the row qubits, and the conjugate of u to the column qubits.
+	n := m.NumQubits()
+	for _, c := range []struct {
+		u     *matrix.Matrix
+		shift int
+	}{
+		{u, n},
+		{u.Conjugate(), 0},
+	} {
+		var cmask, cval int
+		for i, q := range control {
+			b := 1 << (n - 1 - q.Index() + c.shift)
+			cmask |= b
+
+			if state[i] == '1' {
+				cval |= b
+			}
+		}
+
+		k := len(target)
+		offset := make([]int, 1<<k)
+		for r := range offset {
+			for j, q := range target {
+				if r&(1<<(k-1-j)) != 0 {
+					offset[r] |= 1 << (n - 1 - q.Index() + c.shift)
+				}
+			}
+		}
+
+		apply(data, c.u, cmask, cval, offset)
+	}
+}
+
+// Swap swaps the states of the i-th and j-th qubits.
+func (m *Matrix) Swap(i, j Qubit) *Matrix {
+	n := m.NumQubits()
+	bi, bj := 1<<(n-1-i.Index()), 1<<(n-1-j.Index())
+
+	return m.permute(func(k int) int {
+		if (k&bi == 0) == (k&bj == 0) {
+			return k
+		}
+
+		return k ^ bi ^ bj
+	})
+}
+
+// Permute maps the basis state |k> of the target qubits to |f(k)>
+// if all control qubits are |1>.
+// f must be a bijection on [0, 2**len(target)), otherwise it panics with ErrNotBijection.
+func (m *Matrix) Permute(f func(k int) int, control []Qubit, target ...Qubit) *Matrix {
+	if err := index.Bijection(f, len(target)); err != nil {
+		panic(err)
+	}
+
+	n, t := m.NumQubits(), ints(target)
+
+	var mask int
+	for _, c := range control {
+		mask |= 1 << (n - 1 - c.Index())
+	}
+
+	return m.permute(func(i int) int {
+		if i&mask != mask {
+			return i
+		}
+
+		return index.Put(n, i, t, f(index.Take(n, i, t)))
+	})
+}
+
+// Measure returns a measured qubit, and collapses the density matrix.
+// The outcome is one if Rand() is greater than the probability of zero.
+func (m *Matrix) Measure(index Qubit) *qubit.Qubit {
+	n, d := m.NumQubits(), m.rho.Rows
+	mask := 1 << (n - 1 - index.Index())
+
+	var zprop float64
+	for i := range d {
+		if i&mask == 0 {
+			zprop += real(m.rho.At(i, i))
+		}
+	}
+
+	if m.Rand() > zprop {
+		m.collapse(mask, mask, 1-zprop)
+		return qubit.One()
+	}
+
+	m.collapse(mask, 0, zprop)
+	return qubit.Zero()
+}
+
+// Reset sets the qubits to the zero state.
+// It traces out the qubits and prepares |0>, so the result does not depend on Rand.
+func (m *Matrix) Reset(index ...Qubit) *Matrix {
+	n, d := m.NumQubits(), m.rho.Rows
+	for _, q := range index {
+		b := 1 << (n - 1 - q.Index())
+
+		rho := matrix.ZeroLike(m.rho)
+		for i := range d {
+			if i&b != 0 {
+				continue
+			}
+
+			for j := range d {
+				if j&b != 0 {
+					continue
+				}
+
+				rho.Set(i, j, m.rho.At(i, j)+m.rho.At(i|b, j|b))
+			}
+		}
+
+		m.rho = rho
+	}
+
+	return m
+}
+
+// Diagonal returns the probabilities of the computational basis states.
+func (m *Matrix) Diagonal() []float64 {
+	d := m.rho.Rows
+
+	p := make([]float64, d)
+	for i := range d {
+		p[i] = real(m.rho.At(i, i))
+	}
+
+	return p
+}
+
+// Marginal returns the marginal probability distribution of the qubits at the index.
+// The unlisted qubits are summed out, and index[0] is the most significant bit.
+// If no index is given, it returns the probability of all qubits.
+func (m *Matrix) Marginal(qb ...Qubit) []float64 {
+	if len(qb) < 1 {
+		return m.Diagonal()
+	}
+
+	n, idx := m.NumQubits(), ints(qb)
+	p := make([]float64, 1<<len(idx))
+	for i, v := range m.Diagonal() {
+		p[index.Take(n, i, idx)] += v
+	}
+
+	return p
+}
+
+// State returns the computational basis states with nonzero probability.
+// The amplitude of each state is the square root of its probability,
+// since a mixed state has no phase.
+// If no index is provided, it returns the states of all qubits.
+func (m *Matrix) State(qb ...[]Qubit) []qubit.State {
+	n := m.NumQubits()
+	if len(qb) < 1 {
+		qb = append(qb, m.Qubits())
+	}
+
+	idx := make([][]int, len(qb))
+	for j := range qb {
+		idx[j] = ints(qb[j])
+	}
+
+	state := make([]qubit.State, 0)
+	for i, p := range m.Diagonal() {
+		if p < epsilon.E13() {
+			continue
+		}
+
+		bin := make([]string, len(qb))
+		for j := range idx {
+			bin[j] = index.Binary(n, i, idx[j])
+		}
+
+		state = append(state, qubit.NewState(complex(math.Sqrt(p), 0), bin...))
+	}
+
+	return state
+}
+
+// String returns the string representation of the density matrix.
+func (m *Matrix) String() string {
+	return fmt.Sprintf("%v", m.rho.Data)
+}
+
 // Probability returns the probability of the qubit in the given state.
 func (m *Matrix) Probability(q *qubit.Qubit) float64 {
 	p := q.OuterProduct(q)
@@ -148,14 +429,16 @@ func (m *Matrix) Project(q *qubit.Qubit, eps ...float64) (float64, *Matrix) {
 	p := m.Probability(q)
 	if math.Abs(p) < epsilon.E13(eps...) {
 		return 0, &Matrix{
-			rho: matrix.ZeroLike(m.rho),
+			rho:  matrix.ZeroLike(m.rho),
+			Rand: m.Rand,
 		}
 	}
 
 	op := q.OuterProduct(q)
 	rho := matrix.MatMul(op, m.rho, op)
 	return p, &Matrix{
-		rho: rho.Mul(1.0 / complex(p, 0)),
+		rho:  rho.Mul(1.0 / complex(p, 0)),
+		Rand: m.Rand,
 	}
 }
 
@@ -174,27 +457,52 @@ func (m *Matrix) Purity() float64 {
 	return real(matrix.MatMul(m.rho, m.rho).Trace())
 }
 
+// Fidelity returns the fidelity of the density matrices m and n.
+// It is (Tr(sqrt(sqrt(m) * n * sqrt(m))))**2, and it is <psi|n|psi> if m is the pure state |psi>.
+func (m *Matrix) Fidelity(n *Matrix) float64 {
+	// the eigenvalues are non-negative, and the rounding errors less than epsilon are zero
+	sqrt := func(z complex128) complex128 {
+		if real(z) < epsilon.E13() {
+			return 0
+		}
+
+		return complex(math.Sqrt(real(z)), 0)
+	}
+
+	sq := m.rho.Func(sqrt)
+	tr := real(matrix.MatMul(sq, n.rho, sq).Func(sqrt).Trace())
+	return tr * tr
+}
+
 // TensorProduct returns the tensor product of two density matrices.
 func (m *Matrix) TensorProduct(n *Matrix) *Matrix {
 	return &Matrix{
-		rho: m.rho.TensorProduct(n.rho),
+		rho:  m.rho.TensorProduct(n.rho),
+		Rand: m.Rand,
 	}
 }
 
 // PartialTrace returns the partial trace of the density matrix.
 // The length of index must be less than or equal to n - 1,
 // where n is the number of qubits in the matrix.
-func (m *Matrix) PartialTrace(index ...Qubit) *Matrix {
+func (m *Matrix) PartialTrace(qb ...Qubit) *Matrix {
 	n := m.NumQubits()
 	p, q := m.Dimension()
 	d := number.Pow(2, n-1)
 
+	idx, remain := ints(qb), make([]int, 0, n)
+	for i := range n {
+		if !slices.Contains(idx, i) {
+			remain = append(remain, i)
+		}
+	}
+
 	rho := matrix.Zero(d, d)
 	for i := range p {
-		k, kr := take(n, i, index)
+		k, kr := index.Binary(n, i, idx), index.Binary(n, i, remain)
 
 		for j := range q {
-			l, lr := take(n, j, index)
+			l, lr := index.Binary(n, j, idx), index.Binary(n, j, remain)
 
 			if k != l {
 				continue
@@ -221,100 +529,184 @@ func (m *Matrix) PartialTrace(index ...Qubit) *Matrix {
 		}
 	}
 
-	return &Matrix{rho: rho}
+	return &Matrix{rho: rho, Rand: m.Rand}
 }
 
-// Depolarizing returns the depolarizing channel.
+// Depolarizing returns the density matrix with the depolarizing channel applied to each qubit independently.
 // It applies the identity with probability (1 - p),
 // and applies each of the Pauli gates X, Y, and Z with probability p/3.
-func (m *Matrix) Depolarizing(p float64) *Matrix {
-	n := m.NumQubits()
+// If no qubit is given, it depolarizes all qubits.
+func (m *Matrix) Depolarizing(p float64, qb ...Qubit) *Matrix {
+	if len(qb) < 1 {
+		qb = m.Qubits()
+	}
 
-	id := m.rho.Mul(complex(1-p, 0))
-	x := matrix.MatMul(gate.X(n), m.rho, gate.X(n)).Mul(complex(p/3, 0))
-	y := matrix.MatMul(gate.Y(n), m.rho, gate.Y(n)).Mul(complex(p/3, 0))
-	z := matrix.MatMul(gate.Z(n), m.rho, gate.Z(n)).Mul(complex(p/3, 0))
+	c := noise.Depolarizing(p)
 
-	return &Matrix{
-		rho: id.Add(x).Add(y).Add(z),
+	out := m.Clone()
+	for _, q := range qb {
+		out.ApplyKraus(c.Kraus, q)
 	}
+
+	return out
 }
 
-// ApplyChannel applies a channel to the density matrix.
-// It applies the identity with probability 1-p, and applies the gate g with probability p.
-func (m *Matrix) ApplyChannel(p float64, g *matrix.Matrix, qb ...Qubit) *Matrix {
-	n, k := m.NumQubits(), len(qb)
-	id := gate.I()
-	e0 := gate.I().Mul(complex(math.Sqrt(1-p), 0))
-	e1 := g.Mul(complex(math.Sqrt(p), 0))
-
-	index := make([]int, k)
-	for i, v := range qb {
-		index[i] = v.Index()
-	}
-
-	// create kraus operators
-	kraus := make([]*matrix.Matrix, 1<<k)
-	for i := range 1 << k {
-		ops := make([]*matrix.Matrix, n)
-		for j := range n {
-			ops[j] = id
-		}
+// TwoQubitDepolarizing returns the density matrix with the two-qubit depolarizing channel applied to q0 and q1.
+// It applies the identity with probability (1 - p),
+// and applies each of the 15 non-identity Pauli pairs with probability p/15.
+func (m *Matrix) TwoQubitDepolarizing(p float64, q0, q1 Qubit) *Matrix {
+	return m.Evolve(noise.TwoQubitDepolarizing(p), q0, q1)
+}
 
-		for j, idx := range index {
-			if (i>>j)&1 == 1 {
-				ops[idx] = e1
-				continue
+// Choi returns the Choi matrix of the channel f on n qubits.
+// It is sum(|i><j| x f(|i><j|)), and f is a linear map such as the channel methods of Matrix.
+// For example, Choi(1, func(m *Matrix) *Matrix { return m.AmplitudeDamping(0.1, 0) }).
+// noise.FromChoi converts it to the Kraus operators.
+func Choi(n int, f func(m *Matrix) *Matrix) *matrix.Matrix {
+	d := 1 << n
+
+	choi := matrix.Zero(d*d, d*d)
+	for i := range d {
+		for j := range d {
+			e := matrix.Zero(d, d)
+			e.Set(i, j, 1)
+
+			out := f(FromMatrix(e))
+			for a := range d {
+				for b := range d {
+					choi.Set(i*d+a, j*d+b, out.At(a, b))
+				}
 			}
-
-			ops[idx] = e0
 		}
-
-		kraus[i] = matrix.TensorProduct(ops...)
 	}
 
-	// E(rho) = sum(E * rho * E^dagger)
-	rho := matrix.ZeroLike(m.rho)
-	for _, e := range kraus {
-		rho = rho.Add(matrix.MatMul(e, m.rho, e.Dagger()))
-	}
+	return choi
+}
 
-	return &Matrix{
-		rho: rho,
+// ApplyChannel applies a channel to the density matrix.
+// It applies the identity with probability 1-p, and applies the gate g with probability p
+// to each qubit independently.
+func (m *Matrix) ApplyChannel(p float64, g *matrix.Matrix, qb ...Qubit) *Matrix {
+	c := noise.Unitary(p, g)
+
+	out := m.Clone()
+	for _, q := range qb {
+		out.ApplyKraus(c.Kraus, q)
 	}
+
+	return out
+}
+
+// Evolve returns the density matrix evolved by the channel on the qubits in the given order.
+// qb[0] corresponds to the most significant bit of the Kraus operators.
+// The Kraus operators act on the qubits only, and are not extended to n qubits.
+func (m *Matrix) Evolve(c *noise.Channel, qb ...Qubit) *Matrix {
+	return m.Clone().ApplyKraus(c.Kraus, qb...)
 }
 
 // BitFlip applies a bit flip channel to the density matrix.
 func (m *Matrix) BitFlip(p float64, qb Qubit) *Matrix {
-	return m.ApplyChannel(p, gate.X(), qb)
+	return m.Evolve(noise.BitFlip(p), qb)
 }
 
 // BitPhaseFlip applies a bit-phase flip channel to the density matrix.
 func (m *Matrix) BitPhaseFlip(p float64, qb Qubit) *Matrix {
-	return m.ApplyChannel(p, gate.Y(), qb)
+	return m.Evolve(noise.BitPhaseFlip(p), qb)
 }
 
 // PhaseFlip applies a phase flip channel to the density matrix.
 func (m *Matrix) PhaseFlip(p float64, qb Qubit) *Matrix {
-	return m.ApplyChannel(p, gate.Z(), qb)
+	return m.Evolve(noise.PhaseFlip(p), qb)
 }
 
-func take(n, i int, index []Qubit) (string, string) {
-	idx := make(map[int]struct{}, len(index))
-	for _, j := range index {
-		idx[j.Index()] = struct{}{}
+// AmplitudeDamping applies an amplitude damping channel to the density matrix.
+// It relaxes |1> to |0> with probability gamma.
+func (m *Matrix) AmplitudeDamping(gamma float64, qb Qubit) *Matrix {
+	return m.Evolve(noise.AmplitudeDamping(gamma), qb)
+}
+
+// PhaseDamping applies a phase damping channel to the density matrix.
+// It multiplies the off-diagonal elements of the qubit by sqrt(1-lambda).
+func (m *Matrix) PhaseDamping(lambda float64, qb Qubit) *Matrix {
+	return m.Evolve(noise.PhaseDamping(lambda), qb)
+}
+
+// GeneralizedAmplitudeDamping applies a generalized amplitude damping channel to the density matrix.
+// It relaxes the qubit with probability gamma towards the state with the population p of |0>.
+func (m *Matrix) GeneralizedAmplitudeDamping(gamma, p float64, qb Qubit) *Matrix {
+	return m.Evolve(noise.GeneralizedAmplitudeDamping(gamma, p), qb)
+}
+
+// ThermalRelaxation applies a thermal relaxation channel for the duration gateTime to the density matrix.
+// It requires 0 < t2 <= 2*t1.
+func (m *Matrix) ThermalRelaxation(t1, t2, gateTime float64, qb Qubit) *Matrix {
+	return m.Evolve(noise.ThermalRelaxation(t1, t2, gateTime), qb)
+}
+
+// ints returns the index of the qubits.
+func ints(qb []Qubit) []int {
+	out := make([]int, len(qb))
+	for i, q := range qb {
+		out[i] = q.Index()
 	}
 
-	var out, remain strings.Builder
-	for bit := range n {
-		b := byte('0' + ((i >> (n - 1 - bit)) & 1))
-		if _, ok := idx[bit]; ok {
-			out.WriteByte(b)
-			continue
+	return out
+}
+
+// permute maps rho[i][j] to rho[f(i)][f(j)].
+func (m *Matrix) permute(f func(i int) int) *Matrix {
+	d := m.rho.Rows
+
+	rho := matrix.ZeroLike(m.rho)
+	for i := range d {
+		fi := f(i)
+		for j := range d {
+			rho.Set(fi, f(j), m.rho.At(i, j))
 		}
+	}
+
+	m.rho = rho
+	return m
+}
 
-		remain.WriteByte(b)
+// collapse keeps rho[i][j] such that i&mask == v and j&mask == v, and divides it by p.
+func (m *Matrix) collapse(mask, v int, p float64) {
+	d := m.rho.Rows
+	for i := range d {
+		for j := range d {
+			if i&mask != v || j&mask != v {
+				m.rho.Set(i, j, 0)
+				continue
+			}
+
+			m.rho.DivAt(i, j, complex(p, 0))
+		}
 	}
+}
 
-	return out.String(), remain.String()
+// apply applies u to the basis states i of data such that i&cmask == cval.
+// offset[r] is the index offset of the basis state |r> of the target qubits.
+func apply(data []complex128, u *matrix.Matrix, cmask, cval int, offset []int) {
+	d := len(offset)
+	tmask := offset[d-1]
+
+	in := make([]complex128, d)
+	for i := range data {
+		if i&tmask != 0 || i&cmask != cval {
+			continue
+		}
+
+		for r := range d {
+			in[r] = data[i|offset[r]]
+		}
+
+		for r := range d {
+			var z complex128
+			for c := range d {
+				z += u.At(r, c) * in[c]
+			}
+
+			data[i|offset[r]] = z
+		}
+	}
 }
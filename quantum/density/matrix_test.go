@@ -1,15 +1,19 @@
 package density_test
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/cmplx"
+	"math/rand/v2"
 	"testing"
 
 	"github.com/itsubaki/q/math/epsilon"
 	"github.com/itsubaki/q/math/matrix"
+	qrand "github.com/itsubaki/q/math/rand"
 	"github.com/itsubaki/q/quantum/density"
 	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
 	"github.com/itsubaki/q/quantum/qubit"
 )
 
@@ -340,6 +344,97 @@ func ExampleMatrix_phaseAndBitPhaseFlip() {
 	// 0.00
 }
 
+func ExampleMatrix_Controlled() {
+	rho := density.Zero(2)
+	rho.ApplyOn(gate.H(), 0)
+	rho.Controlled(gate.X(), []density.Qubit{0}, 1)
+
+	for _, s := range rho.State() {
+		fmt.Println(s)
+	}
+
+	fmt.Printf("purity: %.2v\n", rho.Purity())
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+	// purity: 1
+}
+
+func ExampleMatrix_Measure() {
+	rho := density.Zero(3)
+	rho.Rand = qrand.Const()
+
+	rho.ApplyOn(gate.H(), 0)
+	rho.Controlled(gate.X(), []density.Qubit{0}, 2)
+	rho = rho.BitFlip(0.1, 1)
+
+	m0 := rho.Measure(0)
+	m2 := rho.Measure(2)
+	fmt.Println(m0.IsOne() == m2.IsOne())
+	fmt.Printf("%.4f\n", rho.Marginal(1))
+
+	// Output:
+	// true
+	// [0.9000 0.1000]
+}
+
+func ExampleMatrix_Reset() {
+	rho := density.Zero(2)
+	rho.ApplyOn(gate.H(), 0)
+	rho.Controlled(gate.X(), []density.Qubit{0}, 1)
+	rho.Reset(0)
+
+	for _, s := range rho.State([]density.Qubit{0}, []density.Qubit{1}) {
+		fmt.Println(s)
+	}
+
+	fmt.Printf("purity: %.2v\n", rho.Purity())
+
+	// Output:
+	// [0 0][  0   0]( 0.7071 0.0000i): 0.5000
+	// [0 1][  0   1]( 0.7071 0.0000i): 0.5000
+	// purity: 0.5
+}
+
+func ExampleMatrix_AmplitudeDamping() {
+	rho := density.NewPureState(qubit.One())
+	s0 := rho.AmplitudeDamping(0.3, 0)
+
+	for _, r := range s0.Seq2() {
+		fmt.Printf("%.2f\n", r)
+	}
+
+	// Output:
+	// [(0.30+0.00i) (0.00+0.00i)]
+	// [(0.00+0.00i) (0.70+0.00i)]
+}
+
+func ExampleMatrix_PhaseDamping() {
+	rho := density.NewPureState(qubit.Plus())
+	s0 := rho.PhaseDamping(0.36, 0)
+
+	for _, r := range s0.Seq2() {
+		fmt.Printf("%.2f\n", r)
+	}
+
+	// Output:
+	// [(0.50+0.00i) (0.40+0.00i)]
+	// [(0.40+0.00i) (0.50+0.00i)]
+}
+
+func ExampleMatrix_Evolve() {
+	c := noise.BitFlip(0.1).Compose(noise.BitFlip(0.2))
+
+	rho := density.Zero(2)
+	s0 := rho.Evolve(c, 1)
+
+	fmt.Printf("%.2f\n", s0.Diagonal())
+
+	// Output:
+	// [0.74 0.26 0.00 0.00]
+}
+
 func TestExpectedValue(t *testing.T) {
 	cases := []struct {
 		s        []density.State
@@ -554,3 +649,392 @@ func TestMatrix_IsZero(t *testing.T) {
 		}
 	}
 }
+
+func TestMatrix_Controlled(t *testing.T) {
+	type op struct {
+		name   string
+		u      *matrix.Matrix
+		qubits []int
+	}
+
+	random := func(r *rand.Rand, n, depth int) []op {
+		ops := make([]op, depth)
+		for i := range ops {
+			perm := r.Perm(n)
+			switch r.IntN(6) {
+			case 0:
+				ops[i] = op{"ApplyOn", gate.U(r.Float64()*math.Pi, r.Float64()*math.Pi, r.Float64()*math.Pi), perm[:1]}
+			case 1:
+				ops[i] = op{"ApplyOn", gate.CNOT(2, 1, 0), perm[:2]}
+			case 2:
+				ops[i] = op{"Controlled", gate.RY(r.Float64() * math.Pi), perm[:2]}
+			case 3:
+				ops[i] = op{"ControlledState", gate.H(), perm[:2]}
+			case 4:
+				ops[i] = op{"Swap", nil, perm[:2]}
+			case 5:
+				ops[i] = op{"Permute", nil, perm[:min(3, n)]}
+			}
+		}
+
+		return ops
+	}
+
+	cases := []struct {
+		n, depth int
+		seed     uint64
+	}{
+		{2, 10, 1},
+		{3, 20, 2},
+		{4, 30, 3},
+	}
+
+	for _, c := range cases {
+		rho := density.Zero(c.n)
+		qb := qubit.Zero(c.n)
+		for _, o := range random(rand.New(rand.NewPCG(c.seed, 0)), c.n, c.depth) {
+			idx := make([]density.Qubit, len(o.qubits))
+			for i, v := range o.qubits {
+				idx[i] = density.Qubit(v)
+			}
+
+			k := len(o.qubits) - 1
+			switch o.name {
+			case "ApplyOn":
+				rho.ApplyOn(o.u, idx...)
+				qb.ApplyOn(o.u, o.qubits...)
+			case "Controlled":
+				rho.Controlled(o.u, idx[:k], idx[k])
+				qb.Controlled(o.u, o.qubits[:k], o.qubits[k])
+			case "ControlledState":
+				rho.ControlledState(o.u, idx[:1], "0", idx[1])
+				qb.ControlledState(o.u, o.qubits[:1], "0", o.qubits[1])
+			case "Swap":
+				rho.Swap(idx[0], idx[1])
+				qb.Swap(o.qubits[0], o.qubits[1])
+			case "Permute":
+				f := func(v int) int { return (v + 1) % (1 << k) }
+				rho.Permute(f, idx[:1], idx[1:]...)
+				qb.Permute(f, o.qubits[:1], o.qubits[1:]...)
+			}
+		}
+
+		want := density.NewPureState(qb)
+		if !rho.Underlying().Equals(want.Underlying(), 1e-12) {
+			t.Errorf("n=%v, seed=%v, got=%v, want=%v", c.n, c.seed, rho, want)
+		}
+	}
+}
+
+func TestMatrix_Measure(t *testing.T) {
+	cases := []struct {
+		seed uint64
+	}{
+		{1}, {2}, {3}, {4},
+	}
+
+	for _, c := range cases {
+		rho := density.Zero(3).ApplyOn(gate.H(), 0).Controlled(gate.X(), []density.Qubit{0}, 2)
+		rho = rho.BitFlip(0.3, 1)
+		rho.Rand = qrand.Const(c.seed)
+
+		m0 := rho.Measure(0)
+		m2 := rho.Measure(2)
+		if m0.IsOne() != m2.IsOne() {
+			t.Errorf("seed=%v, got=%v, want=%v", c.seed, m0, m2)
+		}
+
+		if math.Abs(rho.Trace()-1) > epsilon.E13() {
+			t.Errorf("seed=%v, got=%v, want=1", c.seed, rho.Trace())
+		}
+
+		if !rho.IsHermite() {
+			t.Errorf("seed=%v, got=%v", c.seed, rho)
+		}
+	}
+}
+
+func TestMatrix_ControlledPanics(t *testing.T) {
+	cases := []struct {
+		u       *matrix.Matrix
+		control []density.Qubit
+		state   string
+		target  []density.Qubit
+		want    error
+	}{
+		{gate.CNOT(2, 0, 1), nil, "", []density.Qubit{0}, density.ErrInvalidDimension},
+		{gate.X(), []density.Qubit{0}, "1", []density.Qubit{0}, density.ErrInvalidIndex},
+		{gate.X(), nil, "", []density.Qubit{2}, density.ErrInvalidIndex},
+		{gate.X(), []density.Qubit{0}, "2", []density.Qubit{1}, density.ErrInvalidState},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, c.want) {
+					t.Errorf("got=%v, want=%v", err, c.want)
+				}
+			}()
+
+			density.Zero(2).ControlledState(c.u, c.control, c.state, c.target...)
+		}()
+	}
+}
+
+func TestMatrix_ApplyKraus(t *testing.T) {
+	cases := []struct {
+		p      float64
+		g      *matrix.Matrix
+		target density.Qubit
+	}{
+		{0.1, gate.X(), 0},
+		{0.2, gate.Y(), 1},
+		{0.3, gate.Z(), 2},
+	}
+
+	for _, c := range cases {
+		rho := density.Zero(3).ApplyOn(gate.H(), 0).Controlled(gate.X(), []density.Qubit{0}, 1).ApplyOn(gate.RY(0.3), 2)
+		want := rho.ApplyChannel(c.p, c.g, c.target)
+
+		got := rho.Clone().ApplyKraus([]*matrix.Matrix{
+			gate.I().Mul(complex(math.Sqrt(1-c.p), 0)),
+			c.g.Mul(complex(math.Sqrt(c.p), 0)),
+		}, c.target)
+
+		if !got.Underlying().Equals(want.Underlying()) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestMatrix_ThermalRelaxation(t *testing.T) {
+	cases := []struct {
+		t1, t2, gateTime float64
+	}{
+		{50, 70, 10},
+		{50, 100, 10},
+		{80, 20, 5},
+		{80, 20, 0},
+	}
+
+	for _, c := range cases {
+		// |1> on qubit 1 and |+> on qubit 0
+		rho := density.NewPureState(qubit.Plus().TensorProduct(qubit.One()))
+
+		p1 := rho.ThermalRelaxation(c.t1, c.t2, c.gateTime, 1).PartialTrace(0)
+		if got, want := real(p1.At(1, 1)), math.Exp(-c.gateTime/c.t1); math.Abs(got-want) > epsilon.E13() {
+			t.Errorf("population: got=%v, want=%v", got, want)
+		}
+
+		p0 := rho.ThermalRelaxation(c.t1, c.t2, c.gateTime, 0).PartialTrace(1)
+		if got, want := cmplx.Abs(p0.At(0, 1)), 0.5*math.Exp(-c.gateTime/c.t2); math.Abs(got-want) > epsilon.E13() {
+			t.Errorf("coherence: got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestMatrix_GeneralizedAmplitudeDamping(t *testing.T) {
+	cases := []struct {
+		gamma, p float64
+	}{
+		{0.3, 1},
+		{0.3, 0.8},
+		{1, 0.8},
+	}
+
+	for _, c := range cases {
+		rho := density.NewPureState(qubit.One())
+		got := rho.GeneralizedAmplitudeDamping(c.gamma, c.p, 0)
+
+		// the population of |0> relaxes towards p
+		if want := c.gamma * c.p; math.Abs(real(got.At(0, 0))-want) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got.At(0, 0), want)
+		}
+
+		if c.p == 1 && !got.Underlying().Equals(rho.AmplitudeDamping(c.gamma, 0).Underlying()) {
+			t.Errorf("got=%v, want=%v", got, rho.AmplitudeDamping(c.gamma, 0))
+		}
+	}
+}
+
+func TestMatrix_Evolve(t *testing.T) {
+	cases := []struct {
+		c  *noise.Channel
+		qb []density.Qubit
+	}{
+		{noise.AmplitudeDamping(0.3), []density.Qubit{1}},
+		{noise.AmplitudeDamping(0.3).TensorProduct(noise.BitFlip(0.2)), []density.Qubit{2, 0}},
+		{noise.Unitary(0.4, gate.CNOT(2, 0, 1)), []density.Qubit{2, 1}},
+		{noise.Depolarizing(0.1).TensorProduct(noise.PhaseFlip(0.3)).TensorProduct(noise.AmplitudeDamping(0.2)), []density.Qubit{1, 2, 0}},
+	}
+
+	for _, c := range cases {
+		rho := density.Zero(3).ApplyOn(gate.H(), 0).Controlled(gate.X(), []density.Qubit{0}, 1).ApplyOn(gate.RY(0.9), 2)
+		got := rho.Evolve(c.c, c.qb...)
+
+		// the Kraus operators on 3 qubits, which acts on qb in the given order
+		idx := make([]int, len(c.qb))
+		for i, q := range c.qb {
+			idx[i] = q.Index()
+		}
+
+		want := matrix.ZeroLike(rho.Underlying())
+		for _, k := range c.c.Kraus {
+			e := matrix.Zero(8, 8)
+			for j := range 8 {
+				v := make([]complex128, 8)
+				v[j] = 1
+
+				col := qubit.New(v...).ApplyOn(k, idx...).Amplitude()
+				for i := range 8 {
+					e.Set(i, j, col[i])
+				}
+			}
+
+			want = want.Add(matrix.MatMul(e, rho.Underlying(), e.Dagger()))
+		}
+
+		if !got.Underlying().Equals(want) {
+			t.Errorf("qb=%v, got=%v, want=%v", c.qb, got.Underlying(), want)
+		}
+	}
+}
+
+func TestMatrix_Depolarizing(t *testing.T) {
+	cases := []struct {
+		p    float64
+		qb   []density.Qubit
+		want []float64
+	}{
+		{0.3, nil, []float64{0.64, 0.16, 0.16, 0.04}},
+		{0.3, []density.Qubit{0, 1}, []float64{0.64, 0.16, 0.16, 0.04}},
+		{0.3, []density.Qubit{1}, []float64{0.8, 0.2, 0, 0}},
+		{0.75, nil, []float64{0.25, 0.25, 0.25, 0.25}},
+	}
+
+	for _, c := range cases {
+		got := density.Zero(2).Depolarizing(c.p, c.qb...).Diagonal()
+		for i := range c.want {
+			if math.Abs(got[i]-c.want[i]) > epsilon.E13() {
+				t.Errorf("p=%v, qb=%v, got=%v, want=%v", c.p, c.qb, got, c.want)
+				break
+			}
+		}
+	}
+
+	// the maximally mixed state for p = 3/4
+	rho := density.NewPureState(qubit.Plus(2)).Depolarizing(0.75)
+	if !rho.Underlying().Equals(matrix.Identity(4).Mul(0.25)) {
+		t.Errorf("got=%v", rho)
+	}
+}
+
+func TestMatrix_TwoQubitDepolarizing(t *testing.T) {
+	cases := []struct {
+		p      float64
+		q0, q1 density.Qubit
+	}{
+		{0.2, 0, 1},
+		{0.2, 2, 0},
+		{15.0 / 16, 1, 2},
+	}
+
+	for _, c := range cases {
+		// GHZ state has <Z_q0 Z_q1> = 1
+		rho := density.Zero(3).ApplyOn(gate.H(), 0).Controlled(gate.X(), []density.Qubit{0}, 1).Controlled(gate.X(), []density.Qubit{1}, 2)
+		got := rho.TwoQubitDepolarizing(c.p, c.q0, c.q1)
+
+		if math.Abs(got.Trace()-1) > epsilon.E13() || !got.IsHermite() {
+			t.Errorf("got=%v", got)
+		}
+
+		// E(rho) = (1 - 16p/15) rho + (16p/15) I/4 x Tr_{q0,q1}(rho)
+		m := got.Marginal(c.q0, c.q1)
+		if zz, want := m[0]-m[1]-m[2]+m[3], 1-16*c.p/15; math.Abs(zz-want) > epsilon.E13() {
+			t.Errorf("p=%v, got=%v, want=%v", c.p, zz, want)
+		}
+
+		q := density.Qubit(3 - c.q0.Index() - c.q1.Index())
+		if m := got.Marginal(q); math.Abs(m[0]-0.5) > epsilon.E13() {
+			t.Errorf("p=%v, got=%v, want=[0.5 0.5]", c.p, m)
+		}
+	}
+
+	// the maximally mixed state for p = 15/16
+	rho := density.NewPureState(qubit.Plus(2)).TwoQubitDepolarizing(15.0/16, 0, 1)
+	if !rho.Underlying().Equals(matrix.Identity(4).Mul(0.25)) {
+		t.Errorf("got=%v", rho)
+	}
+}
+
+func TestChoi(t *testing.T) {
+	cases := []struct {
+		n    int
+		f    func(m *density.Matrix) *density.Matrix
+		want *noise.Channel
+	}{
+		{
+			1,
+			func(m *density.Matrix) *density.Matrix { return m.AmplitudeDamping(0.3, 0) },
+			noise.AmplitudeDamping(0.3),
+		},
+		{
+			1,
+			func(m *density.Matrix) *density.Matrix { return m.Depolarizing(0.2) },
+			noise.Depolarizing(0.2),
+		},
+		{
+			2,
+			func(m *density.Matrix) *density.Matrix { return m.Apply(gate.CNOT(2, 0, 1)).PhaseDamping(0.4, 1) },
+			noise.NewChannel(gate.CNOT(2, 0, 1)).Compose(noise.NewChannel(gate.I()).TensorProduct(noise.PhaseDamping(0.4))),
+		},
+	}
+
+	for _, c := range cases {
+		got := density.Choi(c.n, c.f)
+		if !got.Equals(c.want.Choi()) {
+			t.Errorf("got=%v, want=%v", got, c.want.Choi())
+		}
+	}
+}
+
+func TestMatrix_Fidelity(t *testing.T) {
+	bell := qubit.New(1, 0, 0, 1)
+	mixed := density.New([]density.State{
+		{Probability: 0.3, Qubit: qubit.Zero(2)},
+		{Probability: 0.7, Qubit: qubit.Plus(2)},
+	})
+
+	cases := []struct {
+		m, n *density.Matrix
+		want float64
+	}{
+		{density.Zero(1), density.NewFrom("1"), 0},
+		{density.Zero(1), density.NewPureState(qubit.Plus()), 0.5},
+		{mixed, mixed, 1},
+		{density.NewPureState(bell), mixed, mixed.Probability(bell)},
+		{
+			density.New([]density.State{
+				{Probability: 0.2, Qubit: qubit.Zero()},
+				{Probability: 0.8, Qubit: qubit.One()},
+			}),
+			density.New([]density.State{
+				{Probability: 0.5, Qubit: qubit.Zero()},
+				{Probability: 0.5, Qubit: qubit.One()},
+			}),
+			math.Pow(math.Sqrt(0.2*0.5)+math.Sqrt(0.8*0.5), 2),
+		},
+		{
+			density.NewPureState(bell).Depolarizing(0.3),
+			density.NewPureState(bell).AmplitudeDamping(0.4, 1),
+			density.NewPureState(bell).AmplitudeDamping(0.4, 1).Fidelity(density.NewPureState(bell).Depolarizing(0.3)),
+		},
+	}
+
+	for _, c := range cases {
+		if got := c.m.Fidelity(c.n); math.Abs(got-c.want) > 1e-10 {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
@@ -3,11 +3,17 @@ package gate
 import (
 	"math"
 	"math/cmplx"
+	"strings"
 
+	"github.com/itsubaki/q/internal/index"
 	"github.com/itsubaki/q/math/matrix"
 	"github.com/itsubaki/q/math/number"
+	"github.com/itsubaki/q/math/rand"
 )
 
+// ErrInvalidState is returned when the control state does not match the control qubits.
+var ErrInvalidState = index.ErrInvalidState
+
 // Theta returns 2 * pi / 2**k
 func Theta(k int) float64 {
 	return 2 * math.Pi / math.Pow(2, float64(k))
@@ -121,33 +127,56 @@ func RZ(theta float64) *matrix.Matrix {
 }
 
 // Controlled returns a controlled-u gate.
-// u is a (2x2) unitary matrix and returns a (2**n x 2**n) matrix.
-func Controlled(u *matrix.Matrix, n int, c []int, t int) *matrix.Matrix {
-	var mask int
-	for _, bit := range c {
+// u is a (2**k x 2**k) unitary matrix acting on the k target qubits t,
+// and returns a (2**n x 2**n) matrix that applies u if all control qubits c are |1>.
+func Controlled(u *matrix.Matrix, n int, c []int, t ...int) *matrix.Matrix {
+	return ControlledState(u, n, c, strings.Repeat("1", len(c)), t...)
+}
+
+// ControlledState returns a controlled-u gate with the control state.
+// state is a binary string such as "10", and state[i] is the state of the control qubit c[i] for which u is applied.
+// u is a (2**k x 2**k) unitary matrix acting on the k target qubits t, and returns a (2**n x 2**n) matrix.
+// It panics with ErrInvalidState if state is not a binary string of the same length as c.
+func ControlledState(u *matrix.Matrix, n int, c []int, state string, t ...int) *matrix.Matrix {
+	if err := index.State(state, c); err != nil {
+		panic(err)
+	}
+
+	var mask, v int
+	for i, bit := range c {
 		mask |= (1 << (n - 1 - bit))
+		if state[i] == '1' {
+			v |= (1 << (n - 1 - bit))
+		}
+	}
+
+	// offset[r] is the index offset of the basis state |r> of the target qubits.
+	k := len(t)
+	offset := make([]int, 1<<k)
+	for r := range 1 << k {
+		for j, bit := range t {
+			if r&(1<<(k-1-j)) != 0 {
+				offset[r] |= (1 << (n - 1 - bit))
+			}
+		}
 	}
 
-	s := (1 << n)
 	g := I(n)
-	for i := range s {
-		if (i & mask) != mask {
+	tmask := offset[len(offset)-1]
+	for i := range 1 << n {
+		if (i & mask) != v {
 			continue
 		}
 
-		for j := range s {
-			if (j & mask) != mask {
-				continue
-			}
-
-			// modify only the target qubit
-			if (i & ^(1 << (n - 1 - t))) != (j & ^(1 << (n - 1 - t))) {
-				continue
-			}
+		// modify only the target qubits
+		var r int
+		for _, bit := range t {
+			r = r<<1 | (i>>(n-1-bit))&1
+		}
 
-			c := (j >> (n - 1 - t)) & 1
-			r := (i >> (n - 1 - t)) & 1
-			g.Set(i, j, u.At(c, r))
+		base := i &^ tmask
+		for c := range offset {
+			g.Set(i, base|offset[c], u.At(r, c))
 		}
 	}
 
@@ -312,6 +341,45 @@ func QFT(n int) *matrix.Matrix {
 	return g
 }
 
+// Haar returns a random unitary gate of n qubits drawn from the Haar measure.
+// It is the QR decomposition of a matrix of the standard complex normal elements,
+// and the phases of the diagonal elements of r are moved to q.
+// If rng is not given, rand.Float64 is used.
+func Haar(n int, rng ...func() float64) *matrix.Matrix {
+	r := rand.Float64
+	if len(rng) > 0 {
+		r = rng[0]
+	}
+
+	// Box-Muller transform
+	normal := func() float64 {
+		return math.Sqrt(-2*math.Log(1-r())) * math.Cos(2*math.Pi*r())
+	}
+
+	d := number.Pow(2, n)
+	z := matrix.Zero(d, d)
+	for i := range d {
+		for j := range d {
+			z.Set(i, j, complex(normal(), normal())/complex(math.Sqrt2, 0))
+		}
+	}
+
+	q, rr := z.QR()
+	for j := range d {
+		v := rr.At(j, j)
+		if cmplx.Abs(v) == 0 {
+			continue
+		}
+
+		phase := v / complex(cmplx.Abs(v), 0)
+		for i := range d {
+			q.MulAt(i, j, phase)
+		}
+	}
+
+	return q
+}
+
 // ControlledModExp2 returns gate of controlled modular exponentiation operation.
 // |j>|k> -> |j>|a**(2**j) * k mod N>.
 // len(t) must be larger than log2(N).
@@ -1,13 +1,16 @@
 package gate_test
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"math/cmplx"
 	"strconv"
 	"testing"
 
 	"github.com/itsubaki/q/math/matrix"
 	"github.com/itsubaki/q/math/number"
+	"github.com/itsubaki/q/math/rand"
 	"github.com/itsubaki/q/quantum/gate"
 )
 
@@ -243,6 +246,12 @@ func TestControlled(t *testing.T) {
 		{gate.Controlled(gate.X(), 3, []int{2, 0}, 1), gate.CCNOT(3, 2, 0, 1)},
 		{gate.Controlled(gate.X(), 3, []int{0, 1}, 2), gate.CCNOT(3, 0, 1, 2)},
 		{gate.Controlled(gate.X(), 3, []int{1, 0}, 2), gate.CCNOT(3, 1, 0, 2)},
+		{gate.Controlled(gate.Y(), 2, []int{0}, 1), gate.New(
+			[]complex128{1, 0, 0, 0},
+			[]complex128{0, 1, 0, 0},
+			[]complex128{0, 0, 0, -1i},
+			[]complex128{0, 0, 1i, 0},
+		)},
 	}
 
 	for _, c := range cases {
@@ -252,6 +261,72 @@ func TestControlled(t *testing.T) {
 	}
 }
 
+func TestControlled_nonSymmetric(t *testing.T) {
+	// u is applied to the column vector of the target qubit, that is, u|0> is the first column of u.
+	p0 := gate.New([]complex128{1, 0}, []complex128{0, 0})
+	p1 := gate.New([]complex128{0, 0}, []complex128{0, 1})
+	u := gate.U(1, 2, 3)
+
+	cases := []struct {
+		in, want *matrix.Matrix
+	}{
+		{gate.Controlled(u, 2, []int{0}, 1), p0.TensorProduct(gate.I()).Add(p1.TensorProduct(u))},
+		{gate.Controlled(u, 2, []int{1}, 0), gate.I().TensorProduct(p0).Add(u.TensorProduct(p1))},
+		{gate.Controlled(gate.RY(1), 2, []int{0}, 1), p0.TensorProduct(gate.I()).Add(p1.TensorProduct(gate.RY(1)))},
+	}
+
+	for _, c := range cases {
+		if !c.in.Equals(c.want) {
+			t.Errorf("got=%v, want=%v", c.in, c.want)
+		}
+	}
+}
+
+func TestControlledState(t *testing.T) {
+	cases := []struct {
+		in, want *matrix.Matrix
+	}{
+		{gate.ControlledState(gate.X(), 2, []int{0}, "1", 1), gate.CNOT(2, 0, 1)},
+		{gate.ControlledState(gate.X(), 2, []int{0}, "0", 1), matrix.MatMul(gate.X().TensorProduct(gate.I()), gate.CNOT(2, 0, 1), gate.X().TensorProduct(gate.I()))},
+		{gate.ControlledState(gate.X(), 3, []int{2, 0}, "01", 1), matrix.MatMul(gate.I(2).TensorProduct(gate.X()), gate.CCNOT(3, 2, 0, 1), gate.I(2).TensorProduct(gate.X()))},
+		{gate.Controlled(gate.Swap(2, 0, 1), 3, []int{0}, 1, 2), gate.Fredkin(3, 0, 1, 2)},
+		{gate.Controlled(gate.Swap(2, 0, 1), 3, []int{2}, 0, 1), gate.Fredkin(3, 2, 0, 1)},
+		{gate.Controlled(gate.CNOT(2, 0, 1), 3, []int{0}, 1, 2), gate.CCNOT(3, 0, 1, 2)},
+		{gate.Controlled(gate.CNOT(2, 1, 0), 3, []int{0}, 1, 2), gate.CCNOT(3, 0, 2, 1)},
+		{gate.Controlled(gate.I(2), 3, []int{1}, 0, 2), gate.I(3)},
+	}
+
+	for _, c := range cases {
+		if !c.in.Equals(c.want) {
+			t.Errorf("got=%v, want=%v", c.in, c.want)
+		}
+	}
+}
+
+func TestControlledStatePanics(t *testing.T) {
+	cases := []struct {
+		c     []int
+		state string
+	}{
+		{[]int{0, 1}, "1"},
+		{[]int{0}, "10"},
+		{[]int{0}, "2"},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, gate.ErrInvalidState) {
+					t.Errorf("got=%v, want=%v", err, gate.ErrInvalidState)
+				}
+			}()
+
+			gate.ControlledState(gate.X(), 3, c.c, c.state, 2)
+		}()
+	}
+}
+
 func TestInverse(t *testing.T) {
 	cases := []struct {
 		in, want *matrix.Matrix
@@ -310,6 +385,8 @@ func TestIsUnitary(t *testing.T) {
 		{gate.CS(2, 0, 1), true},
 		{gate.CR(gate.Theta(4), 2, 0, 1), true},
 		{gate.QFT(2), true},
+		{gate.Haar(1), true},
+		{gate.Haar(3), true},
 		{gate.New(
 			[]complex128{1, 2},
 			[]complex128{3, 4},
@@ -429,3 +506,33 @@ func TestMultiQubitGate(t *testing.T) {
 		}
 	}
 }
+
+func TestHaar(t *testing.T) {
+	cases := []struct {
+		n    int
+		seed uint64
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 3},
+	}
+
+	for _, c := range cases {
+		if !gate.Haar(c.n, rand.Const(c.seed)).Equals(gate.Haar(c.n, rand.Const(c.seed))) {
+			t.Errorf("not deterministic for the seed=%v", c.seed)
+		}
+
+		// E[|Tr(U)|**2] is 1 for the Haar measure
+		r := rand.Const(c.seed)
+		shots := 2000
+
+		var sum float64
+		for range shots {
+			sum += math.Pow(cmplx.Abs(gate.Haar(c.n, r).Trace()), 2)
+		}
+
+		if got := sum / float64(shots); math.Abs(got-1) > 0.1 {
+			t.Errorf("got=%v, want=1", got)
+		}
+	}
+}
@@ -0,0 +1,833 @@
+package mps
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+
+	"github.com/itsubaki/q/internal/index"
+	"github.com/itsubaki/q/internal/trajectory"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/qubit"
+)
+
+var (
+	ErrInvalidDimension = index.ErrInvalidDimension
+	ErrInvalidIndex     = index.ErrInvalidIndex
+	ErrInvalidState     = index.ErrInvalidState
+	ErrNotBijection     = index.ErrNotBijection
+	ErrZeroProbability  = trajectory.ErrZeroProbability
+)
+
+// tensor is a site tensor of shape (l, 2, r).
+// a[p] is the (l x r) row-major matrix for the physical index p.
+type tensor struct {
+	l, r int
+	a    [2][]complex128
+}
+
+// clone returns a clone of the tensor.
+func (t *tensor) clone() *tensor {
+	return &tensor{
+		l: t.l,
+		r: t.r,
+		a: [2][]complex128{
+			append([]complex128{}, t.a[0]...),
+			append([]complex128{}, t.a[1]...),
+		},
+	}
+}
+
+// tall returns the (2l x r) matrix with the row index a*2+p.
+func (t *tensor) tall() []complex128 {
+	out := make([]complex128, 2*t.l*t.r)
+	for a := range t.l {
+		for p := range 2 {
+			copy(out[(a*2+p)*t.r:(a*2+p+1)*t.r], t.a[p][a*t.r:(a+1)*t.r])
+		}
+	}
+
+	return out
+}
+
+// wide returns the (l x 2r) matrix with the column index p*r+b.
+func (t *tensor) wide() []complex128 {
+	out := make([]complex128, 2*t.l*t.r)
+	for a := range t.l {
+		for p := range 2 {
+			copy(out[a*2*t.r+p*t.r:a*2*t.r+(p+1)*t.r], t.a[p][a*t.r:(a+1)*t.r])
+		}
+	}
+
+	return out
+}
+
+// MPS is a matrix product state.
+// The sites are kept in the mixed canonical form around the orthogonality center,
+// and the qubits may be stored in a different order than their index,
+// since non-adjacent gates are routed by swapping the sites.
+type MPS struct {
+	sites   []*tensor
+	qubit   []int // qubit[s] is the index of the qubit at the site s
+	site    []int // site[i] is the site of the i-th qubit
+	center  int
+	trunc   float64
+	MaxBond int            // Maximum bond dimension. Zero means no limit.
+	Rand    func() float64 // Random number generator
+}
+
+// New returns a new matrix product state of the vector z.
+// The length of z is a power of two, and z is normalized.
+// If z is empty, it returns the state of zero qubits.
+func New(z ...complex128) *MPS {
+	m := &MPS{
+		Rand: rand.Float64,
+	}
+
+	if len(z) < 1 {
+		return m
+	}
+
+	n := int(math.Log2(float64(len(z))))
+	if 1<<n != len(z) {
+		panic(fmt.Errorf("%w: len(z)=%d", ErrInvalidDimension, len(z)))
+	}
+
+	var norm float64
+	for _, a := range z {
+		norm += real(a)*real(a) + imag(a)*imag(a)
+	}
+
+	theta := make([]complex128, len(z))
+	for i, a := range z {
+		theta[i] = a / complex(math.Sqrt(norm), 0)
+	}
+
+	m.sites = m.split(theta, 1, n, 1)
+	m.qubit = make([]int, n)
+	m.site = make([]int, n)
+	for i := range n {
+		m.qubit[i], m.site[i] = i, i
+	}
+
+	m.center = n - 1
+	return m
+}
+
+// Zero returns a matrix product state of n qubits in the zero state.
+func Zero(n int) *MPS {
+	m := New()
+	for range n {
+		m.TensorProduct(New(1, 0))
+	}
+
+	return m
+}
+
+// NewFrom returns a matrix product state in the computational basis state of the binary string.
+func NewFrom(binary string) *MPS {
+	m := New()
+	for _, b := range binary {
+		if b == '1' {
+			m.TensorProduct(New(0, 1))
+			continue
+		}
+
+		m.TensorProduct(New(1, 0))
+	}
+
+	return m
+}
+
+// NumQubits returns the number of qubits.
+func (m *MPS) NumQubits() int {
+	return len(m.sites)
+}
+
+// Clone returns a clone of the matrix product state.
+func (m *MPS) Clone() *MPS {
+	sites := make([]*tensor, len(m.sites))
+	for i := range m.sites {
+		sites[i] = m.sites[i].clone()
+	}
+
+	return &MPS{
+		sites:   sites,
+		qubit:   append([]int{}, m.qubit...),
+		site:    append([]int{}, m.site...),
+		center:  m.center,
+		trunc:   m.trunc,
+		MaxBond: m.MaxBond,
+		Rand:    m.Rand,
+	}
+}
+
+// TensorProduct appends the qubits of n after the qubits of m.
+func (m *MPS) TensorProduct(n *MPS) *MPS {
+	if len(n.sites) < 1 {
+		return m
+	}
+
+	if len(m.sites) > 0 {
+		// the last site of m is left-canonical since the bond dimension between m and n is one.
+		m.move(len(m.sites) - 1)
+	}
+
+	n = n.Clone()
+	n.move(0)
+
+	offset := len(m.sites)
+	for s := range n.sites {
+		m.sites = append(m.sites, n.sites[s])
+		m.qubit = append(m.qubit, n.qubit[s]+offset)
+	}
+
+	m.site = append(m.site, make([]int, len(n.sites))...)
+	for s, i := range m.qubit {
+		m.site[i] = s
+	}
+
+	m.center = offset
+	m.trunc += n.trunc
+	return m
+}
+
+// BondDimension returns the bond dimensions between the adjacent sites.
+func (m *MPS) BondDimension() []int {
+	if len(m.sites) < 1 {
+		return nil
+	}
+
+	dim := make([]int, len(m.sites)-1)
+	for s := range dim {
+		dim[s] = m.sites[s].r
+	}
+
+	return dim
+}
+
+// TruncationError returns the sum of the discarded weights of the truncations.
+// The discarded weight is the sum of the squared singular values dropped to keep the bond dimension
+// within MaxBond, relative to the sum of all squared singular values, that is the norm of the state
+// before the truncation. The state is normalized after each truncation.
+func (m *MPS) TruncationError() float64 {
+	return m.trunc
+}
+
+// ApplyOn applies the (2**k x 2**k) unitary u to the k target qubits.
+// target[0] corresponds to the most significant bit of u.
+// The target qubits are moved to adjacent sites by swaps before u is applied.
+func (m *MPS) ApplyOn(u *matrix.Matrix, target ...int) *MPS {
+	if err := index.Validate(u, len(m.sites), nil, target); err != nil {
+		panic(err)
+	}
+
+	first, bit := m.route(target)
+	m.apply(first, len(target), bit, u)
+	return m
+}
+
+// ApplyKraus applies one of the Kraus operators to the target qubits as a quantum trajectory.
+// The operator K is chosen with the probability p = |K|psi>|^2 using Rand, and K/sqrt(p) is applied.
+// The probability is given by the reduced density matrix of the target sites.
+// It panics with ErrZeroProbability if no operator has nonzero probability.
+func (m *MPS) ApplyKraus(kraus []*matrix.Matrix, target ...int) *MPS {
+	for _, k := range kraus {
+		if err := index.Validate(k, len(m.sites), nil, target); err != nil {
+			panic(err)
+		}
+	}
+
+	first, bit := m.route(target)
+	k, p, err := trajectory.Choose(kraus, m.reduced(first, len(target), bit), m.Rand())
+	if err != nil {
+		panic(err)
+	}
+
+	m.apply(first, len(target), bit, kraus[k].Mul(complex(1/math.Sqrt(p), 0)))
+	return m
+}
+
+// Controlled applies u to the target qubits if all control qubits are |1>.
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+func (m *MPS) Controlled(u *matrix.Matrix, control []int, target ...int) *MPS {
+	return m.ControlledState(u, control, strings.Repeat("1", len(control)), target...)
+}
+
+// ControlledState applies u to the target qubits if the control qubits are in the given state.
+// state is a binary string such as "10", and state[i] is the state of control[i].
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+func (m *MPS) ControlledState(u *matrix.Matrix, control []int, state string, target ...int) *MPS {
+	if err := index.Validate(u, len(m.sites), control, target); err != nil {
+		panic(err)
+	}
+
+	if err := index.State(state, control); err != nil {
+		panic(err)
+	}
+
+	c, t := local(len(control), len(target))
+	g := gate.ControlledState(u, len(control)+len(target), c, state, t...)
+	return m.ApplyOn(g, append(append([]int{}, control...), target...)...)
+}
+
+// Swap swaps the states of the i-th and j-th qubits.
+// It exchanges the sites of the qubits and does not change the tensors.
+func (m *MPS) Swap(i, j int) *MPS {
+	if err := index.Validate(nil, len(m.sites), nil, []int{i}); err != nil {
+		panic(err)
+	}
+
+	if err := index.Validate(nil, len(m.sites), nil, []int{j}); err != nil {
+		panic(err)
+	}
+
+	si, sj := m.site[i], m.site[j]
+	m.qubit[si], m.qubit[sj] = j, i
+	m.site[i], m.site[j] = sj, si
+	return m
+}
+
+// Permute maps the basis state |k> of the target qubits to |f(k)>
+// if all control qubits are |1>.
+// f must be a bijection on [0, 2**len(target)), otherwise it panics with ErrNotBijection.
+func (m *MPS) Permute(f func(k int) int, control []int, target ...int) *MPS {
+	if err := index.Bijection(f, len(target)); err != nil {
+		panic(err)
+	}
+
+	nc, nt := len(control), len(target)
+	cmask := (1<<nc - 1) << nt
+
+	d := 1 << (nc + nt)
+	g := matrix.Zero(d, d)
+	for i := range d {
+		if i&cmask != cmask {
+			g.Set(i, i, 1)
+			continue
+		}
+
+		g.Set(i&cmask|f(i&^cmask), i, 1)
+	}
+
+	return m.ApplyOn(g, append(append([]int{}, control...), target...)...)
+}
+
+// Measure returns a measured qubit.
+func (m *MPS) Measure(i int) *qubit.Qubit {
+	if err := index.Validate(nil, len(m.sites), nil, []int{i}); err != nil {
+		panic(err)
+	}
+
+	s := m.site[i]
+	m.move(s)
+
+	// the norm of the state is the norm of the orthogonality center
+	t := m.sites[s]
+	p0, p1 := norm2(t.a[0]), norm2(t.a[1])
+	zprop := p0 / (p0 + p1)
+
+	// One()
+	if m.Rand() > zprop {
+		clear(t.a[0])
+		scale(t.a[1], 1/math.Sqrt(p1))
+		return qubit.One()
+	}
+
+	// Zero()
+	clear(t.a[1])
+	scale(t.a[0], 1/math.Sqrt(p0))
+	return qubit.Zero()
+}
+
+// Amplitude returns the amplitude of the computational basis state of the binary string.
+// binary[i] is the state of the i-th qubit.
+func (m *MPS) Amplitude(binary string) complex128 {
+	if len(binary) != len(m.sites) {
+		panic(fmt.Errorf("%w: binary=%q, qubits=%d", ErrInvalidDimension, binary, len(m.sites)))
+	}
+
+	v := []complex128{1}
+	for s, t := range m.sites {
+		p := 0
+		if binary[m.qubit[s]] == '1' {
+			p = 1
+		}
+
+		v = mul(v, 1, t.l, t.a[p], t.r)
+	}
+
+	return v[0]
+}
+
+// State returns the states of the computational basis states of the binary strings.
+func (m *MPS) State(binary ...string) []qubit.State {
+	state := make([]qubit.State, len(binary))
+	for i, b := range binary {
+		state[i] = qubit.NewState(m.Amplitude(b), b)
+	}
+
+	return state
+}
+
+// Vector returns the amplitudes of all computational basis states.
+// The length is 2**n, and the 0-th qubit is the most significant bit.
+func (m *MPS) Vector() []complex128 {
+	n := len(m.sites)
+	if n < 1 {
+		return nil
+	}
+
+	// rows of prefix contraction in site order
+	v, r := []complex128{1}, 1
+	for _, t := range m.sites {
+		next := make([]complex128, 0, len(v)/r*2*t.r)
+		for i := 0; i < len(v); i += r {
+			for p := range 2 {
+				next = append(next, mul(v[i:i+r], 1, r, t.a[p], t.r)...)
+			}
+		}
+
+		v, r = next, t.r
+	}
+
+	out := make([]complex128, 1<<n)
+	for k, a := range v {
+		var i int
+		for s := range n {
+			i |= (k >> (n - 1 - s) & 1) << (n - 1 - m.qubit[s])
+		}
+
+		out[i] = a
+	}
+
+	return out
+}
+
+// Marginal returns the marginal probability distribution of the qubits at the index.
+// The unlisted qubits are summed out, and index[0] is the most significant bit.
+// If no index is given, it returns the probability of all qubits.
+// The orthogonality center is moved to the first listed site, so the sites outside the listed ones
+// contract to the identity, and the sites in between are contracted once for each partial outcome
+// with nonzero probability.
+func (m *MPS) Marginal(idx ...int) []float64 {
+	if len(idx) < 1 {
+		idx = make([]int, len(m.sites))
+		for i := range idx {
+			idx[i] = i
+		}
+	}
+
+	if err := index.Validate(nil, len(m.sites), nil, idx); err != nil {
+		panic(err)
+	}
+
+	k := len(idx)
+	p := make([]float64, 1<<k)
+	if k < 1 {
+		p[0] = 1
+		return p
+	}
+
+	pos := make(map[int]int)
+	first, last := len(m.sites), 0
+	for j, i := range idx {
+		pos[i] = j
+		first, last = min(first, m.site[i]), max(last, m.site[i])
+	}
+
+	m.move(first)
+
+	// env[b] is the (l x l) environment of <psi|P_b|psi> for the partial outcome b of the listed qubits.
+	l := m.sites[first].l
+	env := map[int][]complex128{0: identity(l)}
+	for s := first; s <= last; s++ {
+		t := m.sites[s]
+		j, listed := pos[m.qubit[s]]
+
+		next := make(map[int][]complex128)
+		for b, e := range env {
+			for v := range 2 {
+				key := b
+				if listed {
+					key |= v << (k - 1 - j)
+				}
+
+				c := mul(dagger(t.a[v], t.l, t.r), t.r, t.l, mul(e, t.l, t.l, t.a[v], t.r), t.r)
+				if n, ok := next[key]; ok {
+					for i := range n {
+						n[i] += c[i]
+					}
+
+					continue
+				}
+
+				next[key] = c
+			}
+		}
+
+		// the sites after s are right-canonical, so the probability of b is the trace of env[b].
+		for b, e := range next {
+			if trace(e, t.r) < epsilon.E13() {
+				delete(next, b)
+			}
+		}
+
+		env = next
+	}
+
+	r := m.sites[last].r
+	for b, e := range env {
+		p[b] = trace(e, r)
+	}
+
+	return p
+}
+
+// String returns the string representation of the amplitudes.
+func (m *MPS) String() string {
+	return fmt.Sprintf("%v", m.Vector())
+}
+
+// swap swaps the qubits at the adjacent sites s and s+1.
+func (m *MPS) swap(s int) {
+	m.apply(s, 2, func(i int) int { return i }, gate.Swap(2, 0, 1))
+
+	i, j := m.qubit[s], m.qubit[s+1]
+	m.qubit[s], m.qubit[s+1] = j, i
+	m.site[i], m.site[j] = s+1, s
+}
+
+// route moves the target qubits to the adjacent sites from the leftmost one by swaps.
+// It returns the first site, and the map from the physical index of the sites to the index of u,
+// where target[0] corresponds to the most significant bit of u.
+func (m *MPS) route(target []int) (int, func(i int) int) {
+	// move the target qubits next to the leftmost one
+	first := m.site[target[0]]
+	for _, t := range target {
+		first = min(first, m.site[t])
+	}
+
+	k := len(target)
+	sorted := make([]int, 0, k)
+	for s := first; len(sorted) < k; s++ {
+		for _, t := range target {
+			if m.site[t] == s {
+				sorted = append(sorted, t)
+			}
+		}
+	}
+
+	for j, t := range sorted {
+		for m.site[t] > first+j {
+			m.swap(m.site[t] - 1)
+		}
+	}
+
+	// the bit of u for the qubit at the site first+j
+	bit := make([]int, k)
+	for j := range k {
+		for i, t := range target {
+			if m.site[t] == first+j {
+				bit[j] = k - 1 - i
+			}
+		}
+	}
+
+	return first, func(i int) int {
+		var v int
+		for j := range k {
+			v |= (i >> (k - 1 - j) & 1) << bit[j]
+		}
+
+		return v
+	}
+}
+
+// apply applies u to the k adjacent sites from the site first.
+// bit maps the physical index of the sites to the index of u.
+func (m *MPS) apply(first, k int, bit func(i int) int, u *matrix.Matrix) {
+	m.move(first)
+	theta, l, r := m.theta(first, k)
+
+	d := 1 << k
+	out := make([]complex128, len(theta))
+	for a := range l {
+		for i := range d {
+			for j := range d {
+				uij := u.At(bit(i), bit(j))
+				if uij == 0 {
+					continue
+				}
+
+				for b := range r {
+					out[(a*d+i)*r+b] += uij * theta[(a*d+j)*r+b]
+				}
+			}
+		}
+	}
+
+	sites := m.split(out, l, k, r)
+	copy(m.sites[first:first+k], sites)
+	m.center = first + k - 1
+}
+
+// reduced returns the reduced density matrix of the k adjacent sites from the site first.
+// bit maps the physical index of the sites to the index of the matrix.
+// The orthogonality center is moved to the site first, so the other sites contract to the identity.
+func (m *MPS) reduced(first, k int, bit func(i int) int) *matrix.Matrix {
+	m.move(first)
+	theta, l, r := m.theta(first, k)
+
+	d := 1 << k
+	rho := matrix.Zero(d, d)
+	for i := range d {
+		for j := range d {
+			var z complex128
+			for a := range l {
+				for b := range r {
+					z += theta[(a*d+i)*r+b] * cmplx.Conj(theta[(a*d+j)*r+b])
+				}
+			}
+
+			rho.Set(bit(i), bit(j), z)
+		}
+	}
+
+	return rho
+}
+
+// theta returns the (l, 2**k, r) tensor of the k adjacent sites from the site first, and l and r.
+func (m *MPS) theta(first, k int) ([]complex128, int, int) {
+	l, r := m.sites[first].l, m.sites[first].r
+	theta := m.sites[first].tall()
+	for s := first + 1; s < first+k; s++ {
+		t := m.sites[s]
+		theta = mul(theta, len(theta)/r, r, t.wide(), 2*t.r)
+		r = t.r
+	}
+
+	return theta, l, r
+}
+
+// split returns the k sites of the (l, 2**k, r) tensor theta.
+// The sites except the last one are left-canonical, and the bond dimension is at most MaxBond.
+func (m *MPS) split(theta []complex128, l, k, r int) []*tensor {
+	sites := make([]*tensor, k)
+	for j := range k - 1 {
+		rows, cols := l*2, len(theta)/(l*2)
+		u, s, vh := svd(theta, rows, cols)
+
+		// the number of singular values to keep
+		var total, kept float64
+		for _, v := range s {
+			total += v * v
+		}
+
+		chi := 0
+		for chi < len(s) && s[chi] > epsilon.E13() && (m.MaxBond < 1 || chi < m.MaxBond) {
+			kept += s[chi] * s[chi]
+			chi++
+		}
+
+		if chi < 1 {
+			chi, kept = 1, s[0]*s[0]
+		}
+
+		if total > 0 {
+			m.trunc += (total - kept) / total
+		}
+
+		// left-canonical site
+		w := len(s)
+		t := &tensor{l: l, r: chi, a: [2][]complex128{make([]complex128, l*chi), make([]complex128, l*chi)}}
+		for a := range l {
+			for p := range 2 {
+				for b := range chi {
+					t.a[p][a*chi+b] = u[(a*2+p)*w+b]
+				}
+			}
+		}
+
+		sites[j] = t
+
+		// the rest is diag(s) * vh normalized to the total weight
+		c := math.Sqrt(total / kept)
+		rest := make([]complex128, chi*cols)
+		for a := range chi {
+			for b := range cols {
+				rest[a*cols+b] = complex(s[a]*c, 0) * vh[a*cols+b]
+			}
+		}
+
+		theta, l = rest, chi
+	}
+
+	// the last site is the orthogonality center
+	t := &tensor{l: l, r: r, a: [2][]complex128{make([]complex128, l*r), make([]complex128, l*r)}}
+	for a := range l {
+		for p := range 2 {
+			copy(t.a[p][a*r:(a+1)*r], theta[(a*2+p)*r:(a*2+p+1)*r])
+		}
+	}
+
+	sites[k-1] = t
+	return sites
+}
+
+// move moves the orthogonality center to the site s.
+func (m *MPS) move(s int) {
+	for m.center < s {
+		// left-canonicalize the center, and absorb the rest into the right site
+		c := m.center
+		t, next := m.sites[c], m.sites[c+1]
+		u, sv, vh := svd(t.tall(), 2*t.l, t.r)
+
+		k := len(sv)
+		left := &tensor{l: t.l, r: k, a: [2][]complex128{make([]complex128, t.l*k), make([]complex128, t.l*k)}}
+		for a := range t.l {
+			for p := range 2 {
+				copy(left.a[p][a*k:(a+1)*k], u[(a*2+p)*k:(a*2+p+1)*k])
+			}
+		}
+
+		for a := range k {
+			for b := range t.r {
+				vh[a*t.r+b] *= complex(sv[a], 0)
+			}
+		}
+
+		right := &tensor{l: k, r: next.r}
+		for p := range 2 {
+			right.a[p] = mul(vh, k, t.r, next.a[p], next.r)
+		}
+
+		m.sites[c], m.sites[c+1] = left, right
+		m.center++
+	}
+
+	for m.center > s {
+		// right-canonicalize the center, and absorb the rest into the left site
+		c := m.center
+		t, prev := m.sites[c], m.sites[c-1]
+
+		u, sv, vh := svd(t.wide(), t.l, 2*t.r)
+
+		k := len(sv)
+		right := &tensor{l: k, r: t.r}
+		for p := range 2 {
+			right.a[p] = make([]complex128, k*t.r)
+			for i := range k {
+				copy(right.a[p][i*t.r:(i+1)*t.r], vh[i*2*t.r+p*t.r:i*2*t.r+(p+1)*t.r])
+			}
+		}
+
+		for i := range t.l {
+			for j := range k {
+				u[i*k+j] *= complex(sv[j], 0)
+			}
+		}
+
+		left := &tensor{l: prev.l, r: k}
+		for p := range 2 {
+			left.a[p] = mul(prev.a[p], prev.l, prev.r, u, k)
+		}
+
+		m.sites[c-1], m.sites[c] = left, right
+		m.center--
+	}
+}
+
+// local returns the local index of the control and target qubits of a controlled gate.
+func local(nc, nt int) ([]int, []int) {
+	c := make([]int, nc)
+	for i := range nc {
+		c[i] = i
+	}
+
+	t := make([]int, nt)
+	for i := range nt {
+		t[i] = nc + i
+	}
+
+	return c, t
+}
+
+// mul returns the product of the (rows x inner) matrix a and the (inner x cols) matrix b.
+func mul(a []complex128, rows, inner int, b []complex128, cols int) []complex128 {
+	out := make([]complex128, rows*cols)
+	for i := range rows {
+		for k := range inner {
+			aik := a[i*inner+k]
+			if aik == 0 {
+				continue
+			}
+
+			for j := range cols {
+				out[i*cols+j] += aik * b[k*cols+j]
+			}
+		}
+	}
+
+	return out
+}
+
+// identity returns the (n x n) identity matrix.
+func identity(n int) []complex128 {
+	out := make([]complex128, n*n)
+	for i := range n {
+		out[i*n+i] = 1
+	}
+
+	return out
+}
+
+// trace returns the real part of the trace of the (n x n) matrix a.
+func trace(a []complex128, n int) float64 {
+	var sum float64
+	for i := range n {
+		sum += real(a[i*n+i])
+	}
+
+	return sum
+}
+
+// norm2 returns the squared norm of a.
+func norm2(a []complex128) float64 {
+	var sum float64
+	for _, v := range a {
+		sum += real(v)*real(v) + imag(v)*imag(v)
+	}
+
+	return sum
+}
+
+// scale multiplies a by c.
+func scale(a []complex128, c float64) {
+	for i := range a {
+		a[i] *= complex(c, 0)
+	}
+}
+
+// svd returns the singular value decomposition a = u * diag(s) * vh of the (rows x cols) row-major matrix a.
+// u is (rows x k), vh is (k x cols), and s is in descending order, where k = min(rows, cols).
+func svd(a []complex128, rows, cols int) (u []complex128, s []float64, vh []complex128) {
+	um, s, vhm := (&matrix.Matrix{Rows: rows, Cols: cols, Data: a}).SVD()
+	return um.Data, s, vhm.Data
+}
+
+// dagger returns the conjugate transpose of the (rows x cols) row-major matrix a.
+func dagger(a []complex128, rows, cols int) []complex128 {
+	out := make([]complex128, rows*cols)
+	for i := range rows {
+		for j := range cols {
+			out[j*rows+i] = cmplx.Conj(a[i*cols+j])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,364 @@
+package mps_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	qrand "github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/mps"
+	"github.com/itsubaki/q/quantum/qubit"
+)
+
+func ExampleMPS() {
+	m := mps.Zero(2)
+	m.ApplyOn(gate.H(), 0)
+	m.Controlled(gate.X(), []int{0}, 1)
+
+	for _, s := range m.State("00", "01", "10", "11") {
+		fmt.Println(s)
+	}
+
+	fmt.Println(m.BondDimension())
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [01][  1]( 0.0000 0.0000i): 0.0000
+	// [10][  2]( 0.0000 0.0000i): 0.0000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+	// [2]
+}
+
+func ExampleMPS_ghz() {
+	n := 100
+	m := mps.Zero(n)
+	m.ApplyOn(gate.H(), 0)
+	for i := 1; i < n; i++ {
+		m.Controlled(gate.X(), []int{i - 1}, i)
+	}
+
+	zeros, ones := strings.Repeat("0", n), strings.Repeat("1", n)
+	fmt.Printf("%.4f\n", m.Amplitude(zeros))
+	fmt.Printf("%.4f\n", m.Amplitude(ones))
+	fmt.Println(slices.Max(m.BondDimension()), m.TruncationError())
+
+	// Output:
+	// (0.7071+0.0000i)
+	// (0.7071+0.0000i)
+	// 2 0
+}
+
+func ExampleMPS_TruncationError() {
+	m := mps.Zero(6)
+	m.MaxBond = 2
+
+	for i := range 6 {
+		m.ApplyOn(gate.RY(float64(i+1)), i)
+	}
+
+	for i := range 6 {
+		for j := i + 1; j < 6; j++ {
+			m.Controlled(gate.R(gate.Theta(j-i+1)), []int{j}, i)
+		}
+	}
+
+	fmt.Println(slices.Max(m.BondDimension()))
+	fmt.Println(m.TruncationError() > 0)
+
+	// Output:
+	// 2
+	// true
+}
+
+func ExampleMPS_Measure() {
+	m := mps.Zero(3)
+	m.Rand = qrand.Const()
+
+	m.ApplyOn(gate.H(), 0)
+	m.Controlled(gate.X(), []int{0}, 2)
+
+	m0 := m.Measure(0)
+	m2 := m.Measure(2)
+	fmt.Println(m0.IsOne() == m2.IsOne())
+
+	// Output:
+	// true
+}
+
+func TestMPS(t *testing.T) {
+	type op struct {
+		name   string
+		u      *matrix.Matrix
+		qubits []int
+	}
+
+	random := func(r *rand.Rand, n, depth int) []op {
+		ops := make([]op, depth)
+		for i := range ops {
+			perm := r.Perm(n)
+			switch r.IntN(6) {
+			case 0:
+				ops[i] = op{"ApplyOn", gate.U(r.Float64()*math.Pi, r.Float64()*math.Pi, r.Float64()*math.Pi), perm[:1]}
+			case 1:
+				ops[i] = op{"ApplyOn", gate.H(), perm[:1]}
+			case 2:
+				ops[i] = op{"Controlled", gate.RY(r.Float64() * math.Pi), perm[:2]}
+			case 3:
+				ops[i] = op{"Controlled", gate.X(), perm[:min(3, n)]}
+			case 4:
+				ops[i] = op{"Swap", nil, perm[:2]}
+			case 5:
+				ops[i] = op{"ApplyOn", gate.CNOT(2, 1, 0), perm[:2]}
+			}
+		}
+
+		return ops
+	}
+
+	cases := []struct {
+		n, depth int
+		seed     uint64
+	}{
+		{2, 10, 1},
+		{3, 20, 2},
+		{4, 30, 3},
+		{5, 40, 4},
+		{6, 60, 5},
+		{7, 60, 6},
+	}
+
+	for _, c := range cases {
+		m := mps.Zero(c.n)
+		qb := qubit.Zero(c.n)
+		for _, o := range random(rand.New(rand.NewPCG(c.seed, 0)), c.n, c.depth) {
+			switch o.name {
+			case "ApplyOn":
+				m.ApplyOn(o.u, o.qubits...)
+				qb.ApplyOn(o.u, o.qubits...)
+			case "Controlled":
+				k := len(o.qubits) - 1
+				m.Controlled(o.u, o.qubits[:k], o.qubits[k])
+				qb.Controlled(o.u, o.qubits[:k], o.qubits[k])
+			case "Swap":
+				m.Swap(o.qubits[0], o.qubits[1])
+				qb.Swap(o.qubits[0], o.qubits[1])
+			}
+		}
+
+		got, want := m.Vector(), qb.Amplitude()
+		for i := range want {
+			if cmplx.Abs(got[i]-want[i]) > 1e-10 {
+				t.Errorf("n=%v, seed=%v, got=%v, want=%v", c.n, c.seed, got, want)
+				break
+			}
+		}
+
+		for i := range want {
+			b := fmt.Sprintf("%0*b", c.n, i)
+			if a := m.Amplitude(b); cmplx.Abs(a-want[i]) > 1e-10 {
+				t.Errorf("n=%v, seed=%v, binary=%v, got=%v, want=%v", c.n, c.seed, b, a, want[i])
+			}
+		}
+
+		for _, idx := range [][]int{nil, {0}, {c.n - 1, 0}, {1, 0}} {
+			got, want := m.Marginal(idx...), qb.Marginal(idx...)
+			for i := range want {
+				if math.Abs(got[i]-want[i]) > 1e-10 {
+					t.Errorf("n=%v, seed=%v, index=%v, got=%v, want=%v", c.n, c.seed, idx, got, want)
+					break
+				}
+			}
+		}
+
+		if m.TruncationError() > epsilon.E13() {
+			t.Errorf("n=%v, seed=%v, truncation=%v", c.n, c.seed, m.TruncationError())
+		}
+	}
+}
+
+func TestPermute(t *testing.T) {
+	f := func(k int) int { return (k + 3) % 8 }
+
+	m := mps.NewFrom("1010")
+	m.ApplyOn(gate.H(), 2)
+	m.Permute(f, []int{0}, 3, 1, 2)
+
+	qb := qubit.NewFrom("1010")
+	qb.ApplyOn(gate.H(), 2)
+	qb.Permute(f, []int{0}, 3, 1, 2)
+
+	got, want := m.Vector(), qb.Amplitude()
+	for i := range want {
+		if cmplx.Abs(got[i]-want[i]) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got, want)
+			break
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	cases := []struct {
+		seed uint64
+	}{
+		{1}, {2}, {3}, {4},
+	}
+
+	for _, c := range cases {
+		m := mps.Zero(4)
+		m.Rand = qrand.Const(c.seed)
+
+		m.ApplyOn(gate.H(), 0).Controlled(gate.X(), []int{0}, 3).ApplyOn(gate.H(), 1)
+		m3 := m.Measure(3)
+		m0 := m.Measure(0)
+		if m0.IsOne() != m3.IsOne() {
+			t.Errorf("seed=%v, got=%v, want=%v", c.seed, m0, m3)
+		}
+
+		var sum float64
+		for _, p := range m.Marginal() {
+			sum += p
+		}
+
+		if math.Abs(sum-1) > epsilon.E13() {
+			t.Errorf("seed=%v, got=%v, want=1", c.seed, sum)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		z []complex128
+	}{
+		{[]complex128{1, 2}},
+		{[]complex128{1, 2, 3, 4}},
+		{[]complex128{1, 0, 0, 1, 0, 1i, 1, 0}},
+	}
+
+	for _, c := range cases {
+		got, want := mps.New(c.z...).Vector(), qubit.New(c.z...).Amplitude()
+		for i := range want {
+			if cmplx.Abs(got[i]-want[i]) > epsilon.E13() {
+				t.Errorf("got=%v, want=%v", got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestTruncationError(t *testing.T) {
+	cases := []struct {
+		theta []float64
+	}{
+		{[]float64{0.3}},
+		{[]float64{0.3, 1.2}},
+		{[]float64{1.0, 0.5, 0.1}},
+	}
+
+	for _, c := range cases {
+		m := mps.Zero(2)
+		m.MaxBond = 1
+
+		var want float64
+		for _, theta := range c.theta {
+			// cos(theta/2)|00> + sin(theta/2)|11> is truncated to |00>.
+			m.ApplyOn(gate.RY(theta), 0)
+			m.Controlled(gate.X(), []int{0}, 1)
+			want += math.Pow(math.Sin(theta/2), 2)
+		}
+
+		if got := m.TruncationError(); math.Abs(got-want) > 1e-10 {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+
+		if got := m.Marginal(); math.Abs(got[0]-1) > 1e-10 {
+			t.Errorf("got=%v, want=%v", got[0], 1)
+		}
+	}
+}
+
+func TestMarginal(t *testing.T) {
+	n, k := 100, 20
+
+	m := mps.Zero(n)
+	m.ApplyOn(gate.H(), 0)
+	for i := range n - 1 {
+		m.Controlled(gate.X(), []int{i}, i+1)
+	}
+
+	index := make([]int, k)
+	for i := range index {
+		index[i] = n - 1 - 4*i
+	}
+
+	p := m.Marginal(index...)
+	for b, got := range p {
+		var want float64
+		if b == 0 || b == len(p)-1 {
+			want = 0.5
+		}
+
+		if math.Abs(got-want) > 1e-10 {
+			t.Errorf("b=%d, got=%v, want=%v", b, got, want)
+		}
+	}
+}
+
+func TestApplyKraus(t *testing.T) {
+	gamma := 0.4
+	damping := []*matrix.Matrix{
+		gate.New([]complex128{1, 0}, []complex128{0, complex(math.Sqrt(1-gamma), 0)}),
+		gate.New([]complex128{0, complex(math.Sqrt(gamma), 0)}, []complex128{0, 0}),
+	}
+
+	projector := make([]*matrix.Matrix, 4)
+	for i := range projector {
+		projector[i] = matrix.Zero(4, 4)
+		projector[i].Set(i, i, 1)
+	}
+
+	cases := []struct {
+		kraus  []*matrix.Matrix
+		target []int
+	}{
+		{damping, []int{0}},
+		{damping, []int{3}},
+		{projector, []int{3, 0}},
+		{projector, []int{1, 2}},
+	}
+
+	for _, c := range cases {
+		for seed := range uint64(20) {
+			m := mps.Zero(4).ApplyOn(gate.RY(1.0), 0).ApplyOn(gate.H(), 1).Controlled(gate.RY(2.0), []int{1}, 3).Controlled(gate.X(), []int{0}, 2)
+			qb := qubit.Zero(4).ApplyOn(gate.RY(1.0), 0).ApplyOn(gate.H(), 1).Controlled(gate.RY(2.0), []int{1}, 3).Controlled(gate.X(), []int{0}, 2)
+			m.Rand, qb.Rand = qrand.Const(seed), qrand.Const(seed)
+
+			got, want := m.ApplyKraus(c.kraus, c.target...).Vector(), qb.ApplyKraus(c.kraus, c.target...).Amplitude()
+			for i := range want {
+				if cmplx.Abs(got[i]-want[i]) > 1e-10 {
+					t.Errorf("target=%v, seed=%v, got=%v, want=%v", c.target, seed, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestApplyKraus_zeroProbability(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, mps.ErrZeroProbability) {
+			t.Errorf("got=%v, want=%v", err, mps.ErrZeroProbability)
+		}
+	}()
+
+	// |1><1| on |0>
+	mps.Zero(1).ApplyKraus([]*matrix.Matrix{gate.New([]complex128{0, 0}, []complex128{0, 1})}, 0)
+}
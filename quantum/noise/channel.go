@@ -0,0 +1,220 @@
+package noise
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/number"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+var (
+	ErrInvalidDimension = errors.New("invalid dimension")
+	ErrInvalidParameter = errors.New("invalid parameter")
+)
+
+// Channel is a quantum channel on k qubits given by the Kraus operators.
+// It maps rho to sum(K * rho * K^dagger).
+type Channel struct {
+	Kraus []*matrix.Matrix
+}
+
+// NewChannel returns a new channel of the Kraus operators.
+// It panics if the operators are not (2**k x 2**k) matrices of the same dimension.
+func NewChannel(kraus ...*matrix.Matrix) *Channel {
+	if len(kraus) < 1 {
+		panic(fmt.Errorf("%w: no Kraus operators", ErrInvalidDimension))
+	}
+
+	rows, cols := kraus[0].Dimension()
+	for _, k := range kraus {
+		r, c := k.Dimension()
+		if r != rows || c != cols || r != c || r < 2 || r&(r-1) != 0 {
+			panic(fmt.Errorf("%w: %dx%d Kraus operator", ErrInvalidDimension, r, c))
+		}
+	}
+
+	return &Channel{
+		Kraus: kraus,
+	}
+}
+
+// NumQubits returns the number of qubits the channel acts on.
+func (c *Channel) NumQubits() int {
+	rows, _ := c.Kraus[0].Dimension()
+	return number.Log2(rows)
+}
+
+// IsCPTP returns true if the channel is completely positive and trace preserving.
+// The channel of the Kraus operators is completely positive,
+// and it is trace preserving if sum(K^dagger * K) is the identity.
+func (c *Channel) IsCPTP(eps ...float64) bool {
+	rows, _ := c.Kraus[0].Dimension()
+
+	sum := matrix.Zero(rows, rows)
+	for _, k := range c.Kraus {
+		sum = sum.Add(k.Dagger().MatMul(k))
+	}
+
+	return sum.Equals(matrix.Identity(rows), eps...)
+}
+
+// Compose returns the channel that applies c and then d.
+// The Kraus operators are D * C for each C of c and D of d.
+func (c *Channel) Compose(d *Channel) *Channel {
+	if c.NumQubits() != d.NumQubits() {
+		panic(fmt.Errorf("%w: compose %d and %d qubits", ErrInvalidDimension, c.NumQubits(), d.NumQubits()))
+	}
+
+	kraus := make([]*matrix.Matrix, 0, len(c.Kraus)*len(d.Kraus))
+	for _, kc := range c.Kraus {
+		for _, kd := range d.Kraus {
+			kraus = append(kraus, kd.MatMul(kc))
+		}
+	}
+
+	return NewChannel(kraus...)
+}
+
+// TensorProduct returns the channel that applies c and d to the qubits side by side.
+// The qubits of c are the most significant bits.
+func (c *Channel) TensorProduct(d *Channel) *Channel {
+	kraus := make([]*matrix.Matrix, 0, len(c.Kraus)*len(d.Kraus))
+	for _, kc := range c.Kraus {
+		for _, kd := range d.Kraus {
+			kraus = append(kraus, kc.TensorProduct(kd))
+		}
+	}
+
+	return NewChannel(kraus...)
+}
+
+// Unitary returns the channel that applies the identity with probability 1-p,
+// and applies the unitary u with probability p.
+func Unitary(p float64, u *matrix.Matrix) *Channel {
+	rows, _ := u.Dimension()
+	return NewChannel(
+		matrix.Identity(rows).Mul(complex(math.Sqrt(1-p), 0)),
+		u.Mul(complex(math.Sqrt(p), 0)),
+	)
+}
+
+// BitFlip returns the bit flip channel.
+func BitFlip(p float64) *Channel {
+	return Unitary(p, gate.X())
+}
+
+// BitPhaseFlip returns the bit-phase flip channel.
+func BitPhaseFlip(p float64) *Channel {
+	return Unitary(p, gate.Y())
+}
+
+// PhaseFlip returns the phase flip channel.
+func PhaseFlip(p float64) *Channel {
+	return Unitary(p, gate.Z())
+}
+
+// Depolarizing returns the depolarizing channel on one qubit.
+// It applies the identity with probability (1 - p),
+// and applies each of the Pauli gates X, Y, and Z with probability p/3.
+func Depolarizing(p float64) *Channel {
+	e := complex(math.Sqrt(p/3), 0)
+	return NewChannel(
+		gate.I().Mul(complex(math.Sqrt(1-p), 0)),
+		gate.X().Mul(e),
+		gate.Y().Mul(e),
+		gate.Z().Mul(e),
+	)
+}
+
+// TwoQubitDepolarizing returns the depolarizing channel on two qubits.
+// It applies the identity with probability (1 - p),
+// and applies each of the 15 non-identity Pauli pairs such as IX and ZY with probability p/15.
+func TwoQubitDepolarizing(p float64) *Channel {
+	pauli := []*matrix.Matrix{gate.I(), gate.X(), gate.Y(), gate.Z()}
+	e := complex(math.Sqrt(p/15), 0)
+
+	kraus := []*matrix.Matrix{gate.I(2).Mul(complex(math.Sqrt(1-p), 0))}
+	for i, a := range pauli {
+		for j, b := range pauli {
+			if i == 0 && j == 0 {
+				continue
+			}
+
+			kraus = append(kraus, a.TensorProduct(b).Mul(e))
+		}
+	}
+
+	return NewChannel(kraus...)
+}
+
+// AmplitudeDamping returns the amplitude damping channel.
+// It relaxes |1> to |0> with probability gamma.
+func AmplitudeDamping(gamma float64) *Channel {
+	return NewChannel(
+		gate.New(
+			[]complex128{1, 0},
+			[]complex128{0, complex(math.Sqrt(1-gamma), 0)},
+		),
+		gate.New(
+			[]complex128{0, complex(math.Sqrt(gamma), 0)},
+			[]complex128{0, 0},
+		),
+	)
+}
+
+// PhaseDamping returns the phase damping channel.
+// It multiplies the off-diagonal elements by sqrt(1-lambda), and does not change the populations.
+func PhaseDamping(lambda float64) *Channel {
+	return NewChannel(
+		gate.New(
+			[]complex128{1, 0},
+			[]complex128{0, complex(math.Sqrt(1-lambda), 0)},
+		),
+		gate.New(
+			[]complex128{0, 0},
+			[]complex128{0, complex(math.Sqrt(lambda), 0)},
+		),
+	)
+}
+
+// GeneralizedAmplitudeDamping returns the generalized amplitude damping channel.
+// It relaxes the qubit with probability gamma towards the state with the population p of |0>.
+// If p is 1, it is the amplitude damping channel.
+func GeneralizedAmplitudeDamping(gamma, p float64) *Channel {
+	a, b := complex(math.Sqrt(p), 0), complex(math.Sqrt(1-p), 0)
+	g, h := complex(math.Sqrt(gamma), 0), complex(math.Sqrt(1-gamma), 0)
+	return NewChannel(
+		gate.New([]complex128{a, 0}, []complex128{0, a * h}),
+		gate.New([]complex128{0, a * g}, []complex128{0, 0}),
+		gate.New([]complex128{b * h, 0}, []complex128{0, b}),
+		gate.New([]complex128{0, 0}, []complex128{b * g, 0}),
+	)
+}
+
+// ThermalRelaxation returns the thermal relaxation channel for the duration gateTime.
+// The population of |1> decays by exp(-gateTime/t1), and the coherence decays by exp(-gateTime/t2).
+// It is the amplitude damping followed by the phase damping, and requires 0 < t2 <= 2*t1.
+func ThermalRelaxation(t1, t2, gateTime float64) *Channel {
+	if t1 <= 0 || t2 <= 0 || t2 > 2*t1 || gateTime < 0 {
+		panic(fmt.Errorf("%w: t1=%v, t2=%v, gateTime=%v", ErrInvalidParameter, t1, t2, gateTime))
+	}
+
+	// the amplitude damping decays the coherence by exp(-gateTime/(2*t1)),
+	// and the phase damping decays the rest.
+	gamma := 1 - math.Exp(-gateTime/t1)
+	lambda := 1 - math.Exp(gateTime/t1-2*gateTime/t2)
+
+	kraus := make([]*matrix.Matrix, 0)
+	for _, k := range AmplitudeDamping(gamma).Compose(PhaseDamping(lambda)).Kraus {
+		if k.Equals(matrix.ZeroLike(k)) {
+			continue
+		}
+
+		kraus = append(kraus, k)
+	}
+
+	return NewChannel(kraus...)
+}
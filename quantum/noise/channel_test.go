@@ -0,0 +1,168 @@
+package noise_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
+)
+
+func ExampleBitFlip() {
+	c := noise.BitFlip(0.1)
+	fmt.Println(c.NumQubits(), len(c.Kraus))
+
+	for _, k := range c.Kraus {
+		fmt.Printf("%.4f\n", k.Data)
+	}
+
+	// Output:
+	// 1 2
+	// [(0.9487+0.0000i) (0.0000+0.0000i) (0.0000+0.0000i) (0.9487+0.0000i)]
+	// [(0.0000+0.0000i) (0.3162+0.0000i) (0.3162+0.0000i) (0.0000+0.0000i)]
+}
+
+func ExampleUnitary() {
+	c := noise.Unitary(0.2, gate.CNOT(2, 0, 1))
+	fmt.Println(c.NumQubits(), len(c.Kraus))
+
+	// Output:
+	// 2 2
+}
+
+func TestChannel(t *testing.T) {
+	cases := []struct {
+		c *noise.Channel
+	}{
+		{noise.BitFlip(0.3)},
+		{noise.BitPhaseFlip(0.3)},
+		{noise.PhaseFlip(0.3)},
+		{noise.Depolarizing(0.3)},
+		{noise.Unitary(0.3, gate.Swap(2, 0, 1))},
+		{noise.TwoQubitDepolarizing(0.3)},
+		{noise.AmplitudeDamping(0.3)},
+		{noise.PhaseDamping(0.3)},
+		{noise.GeneralizedAmplitudeDamping(0.3, 0.8)},
+		{noise.ThermalRelaxation(50, 70, 10)},
+		{noise.ThermalRelaxation(50, 100, 10)},
+	}
+
+	for _, c := range cases {
+		if !c.c.IsCPTP() {
+			t.Errorf("got=%v", c.c.Kraus)
+		}
+	}
+}
+
+func TestChannel_IsCPTP(t *testing.T) {
+	cases := []struct {
+		c    *noise.Channel
+		want bool
+	}{
+		{noise.NewChannel(gate.H()), true},
+		{noise.NewChannel(gate.H(), gate.X()), false},
+		{noise.NewChannel(gate.I().Mul(0.5)), false},
+		{noise.BitFlip(0.2).TensorProduct(noise.AmplitudeDamping(0.4)), true},
+		{noise.Depolarizing(0.2).Compose(noise.PhaseDamping(0.4)), true},
+	}
+
+	for _, c := range cases {
+		if got := c.c.IsCPTP(); got != c.want {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
+
+func TestNewChannel(t *testing.T) {
+	cases := []struct {
+		kraus []*matrix.Matrix
+	}{
+		{nil},
+		{[]*matrix.Matrix{gate.X(), gate.CNOT(2, 0, 1)}},
+		{[]*matrix.Matrix{matrix.Zero(3, 3)}},
+		{[]*matrix.Matrix{matrix.Zero(2, 4)}},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, noise.ErrInvalidDimension) {
+					t.Errorf("got=%v, want=%v", err, noise.ErrInvalidDimension)
+				}
+			}()
+
+			noise.NewChannel(c.kraus...)
+		}()
+	}
+}
+
+func ExampleThermalRelaxation() {
+	c := noise.ThermalRelaxation(50, 70, 10)
+	fmt.Println(c.NumQubits(), len(c.Kraus))
+
+	// Output:
+	// 1 3
+}
+
+func TestThermalRelaxation(t *testing.T) {
+	cases := []struct {
+		t1, t2, gateTime float64
+	}{
+		{0, 70, 10},
+		{50, 0, 10},
+		{50, 101, 10},
+		{50, 70, -1},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, noise.ErrInvalidParameter) {
+					t.Errorf("got=%v, want=%v", err, noise.ErrInvalidParameter)
+				}
+			}()
+
+			noise.ThermalRelaxation(c.t1, c.t2, c.gateTime)
+		}()
+	}
+}
+
+func ExampleChannel_Compose() {
+	c := noise.BitFlip(0.1).Compose(noise.BitFlip(0.2))
+	fmt.Println(c.NumQubits(), len(c.Kraus), c.IsCPTP())
+
+	// Output:
+	// 1 4 true
+}
+
+func ExampleChannel_TensorProduct() {
+	c := noise.BitFlip(0.1).TensorProduct(noise.PhaseDamping(0.2))
+	fmt.Println(c.NumQubits(), len(c.Kraus), c.IsCPTP())
+
+	// Output:
+	// 2 4 true
+}
+
+func TestChannel_Compose(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, noise.ErrInvalidDimension) {
+			t.Errorf("got=%v, want=%v", err, noise.ErrInvalidDimension)
+		}
+	}()
+
+	noise.BitFlip(0.1).Compose(noise.Unitary(0.1, gate.CNOT(2, 0, 1)))
+	t.Fail()
+}
+
+func ExampleTwoQubitDepolarizing() {
+	c := noise.TwoQubitDepolarizing(0.1)
+	fmt.Println(c.NumQubits(), len(c.Kraus))
+
+	// Output:
+	// 2 16
+}
@@ -0,0 +1,197 @@
+package noise
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Measure is the gate name of the rules applied before measurement.
+const Measure = "Measure"
+
+// ErrUnknownGate is returned when the gate name of a rule is not a gate of q.Q.
+var ErrUnknownGate = errors.New("unknown gate")
+
+// gates maps the gate names of q.Q to the names the noise is dispatched with.
+// The shorthands such as "CNOT" are applied as the gate of the general form such as "ControlledNot",
+// so "CNOT" also matches CCNOT unless the rule sets Arity.
+// The composite gates such as "QFT" are not in the map, since the noise follows each of their gates.
+var gates = map[string]string{
+	"U":                 "U",
+	"I":                 "I",
+	"X":                 "X",
+	"Y":                 "Y",
+	"Z":                 "Z",
+	"H":                 "H",
+	"S":                 "S",
+	"T":                 "T",
+	"R":                 "R",
+	"RX":                "RX",
+	"RY":                "RY",
+	"RZ":                "RZ",
+	"Apply":             "Apply",
+	"ApplyOn":           "ApplyOn",
+	"Controlled":        "Controlled",
+	"C":                 "Controlled",
+	"ControlledState":   "ControlledState",
+	"ControlledNot":     "ControlledNot",
+	"CNOT":              "ControlledNot",
+	"CCNOT":             "ControlledNot",
+	"CCCNOT":            "ControlledNot",
+	"Toffoli":           "ControlledNot",
+	"ControlledZ":       "ControlledZ",
+	"CZ":                "ControlledZ",
+	"CCZ":               "ControlledZ",
+	"ControlledR":       "ControlledR",
+	"CR":                "ControlledR",
+	"ControlledModExp2": "ControlledModExp2",
+	"CModExp2":          "ControlledModExp2",
+	"Swap":              "Swap",
+	Measure:             Measure,
+}
+
+// Rule is a rule of the noise model.
+// The channel is applied after the gates that match the rule.
+//
+// A channel on one qubit is applied to each qubit of the gate, including the control qubits.
+// A channel on k qubits is applied to the qubits of the gate in the order of control and target,
+// if the gate acts on k qubits.
+type Rule struct {
+	Channel *Channel
+	Gate    []string // Names of the methods of q.Q such as "H", "CNOT" and "ControlledNot". If empty, it matches all gates except Measure.
+	Qubit   []int    // Qubits of the gate. If empty, it matches all qubits.
+	Arity   int      // Number of qubits of the gate. If zero, it matches gates of any size.
+}
+
+// Op is a channel applied to the qubits.
+type Op struct {
+	Channel *Channel
+	Qubit   []int
+}
+
+// Model is a noise model that maps gates and measurements to channels and readout errors.
+type Model struct {
+	rules   []Rule
+	readout map[int][2]float64
+}
+
+// NewModel returns a new noise model without noise.
+func NewModel() *Model {
+	return &Model{
+		rules:   make([]Rule, 0),
+		readout: make(map[int][2]float64),
+	}
+}
+
+// Add adds the rules to the noise model.
+// The gate names are mapped to the names the noise is dispatched with, such as "CNOT" to "ControlledNot".
+// It panics with ErrUnknownGate if a gate name is not a gate of q.Q.
+func (m *Model) Add(rule ...Rule) *Model {
+	for _, r := range rule {
+		name := make([]string, len(r.Gate))
+		for i, g := range r.Gate {
+			v, ok := gates[g]
+			if !ok {
+				panic(fmt.Errorf("%w: %q", ErrUnknownGate, g))
+			}
+
+			name[i] = v
+		}
+
+		r.Gate = name
+		m.rules = append(m.rules, r)
+	}
+
+	return m
+}
+
+// AddReadout adds the readout error to the qubits.
+// p0 is the probability of reading 1 for |0>, and p1 is the probability of reading 0 for |1>.
+// If no qubit is given, it is the default for all qubits.
+func (m *Model) AddReadout(p0, p1 float64, qubit ...int) *Model {
+	if len(qubit) < 1 {
+		m.readout[-1] = [2]float64{p0, p1}
+		return m
+	}
+
+	for _, q := range qubit {
+		m.readout[q] = [2]float64{p0, p1}
+	}
+
+	return m
+}
+
+// Gate returns the channels applied after the gate on the qubits.
+// Use Measure as the name for the channels applied before measurement.
+func (m *Model) Gate(name string, qubit ...int) []Op {
+	if v, ok := gates[name]; ok {
+		name = v
+	}
+
+	ops := make([]Op, 0)
+	for _, r := range m.rules {
+		if !r.match(name, len(qubit)) {
+			continue
+		}
+
+		k := r.Channel.NumQubits()
+		if k == 1 {
+			for _, q := range qubit {
+				if len(r.Qubit) > 0 && !slices.Contains(r.Qubit, q) {
+					continue
+				}
+
+				ops = append(ops, Op{Channel: r.Channel, Qubit: []int{q}})
+			}
+
+			continue
+		}
+
+		if k != len(qubit) || !r.contains(qubit) {
+			continue
+		}
+
+		ops = append(ops, Op{Channel: r.Channel, Qubit: slices.Clone(qubit)})
+	}
+
+	return ops
+}
+
+// Readout returns the readout error of the qubit.
+// p0 is the probability of reading 1 for |0>, and p1 is the probability of reading 0 for |1>.
+func (m *Model) Readout(qubit int) (p0, p1 float64) {
+	if p, ok := m.readout[qubit]; ok {
+		return p[0], p[1]
+	}
+
+	p := m.readout[-1]
+	return p[0], p[1]
+}
+
+// match returns true if the rule matches the gate of the name on n qubits.
+func (r Rule) match(name string, n int) bool {
+	if r.Arity > 0 && r.Arity != n {
+		return false
+	}
+
+	if len(r.Gate) < 1 {
+		return name != Measure
+	}
+
+	return slices.Contains(r.Gate, name)
+}
+
+// contains returns true if the rule matches all the qubits.
+func (r Rule) contains(qubit []int) bool {
+	if len(r.Qubit) < 1 {
+		return true
+	}
+
+	for _, q := range qubit {
+		if !slices.Contains(r.Qubit, q) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,146 @@
+package noise_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
+)
+
+func ExampleModel() {
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.Depolarizing(0.01), Arity: 1},
+		noise.Rule{Channel: noise.BitFlip(0.05), Gate: []string{"ControlledNot"}, Qubit: []int{1}},
+	).AddReadout(0.02, 0.03)
+
+	for _, op := range m.Gate("H", 0) {
+		fmt.Println(len(op.Channel.Kraus), op.Qubit)
+	}
+
+	for _, op := range m.Gate("ControlledNot", 0, 1) {
+		fmt.Println(len(op.Channel.Kraus), op.Qubit)
+	}
+
+	fmt.Println(m.Readout(3))
+
+	// Output:
+	// 4 [0]
+	// 2 [1]
+	// 0.02 0.03
+}
+
+func TestModel_Gate(t *testing.T) {
+	cx := noise.Unitary(0.1, gate.CNOT(2, 0, 1))
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.BitFlip(0.1)},
+		noise.Rule{Channel: noise.PhaseFlip(0.1), Gate: []string{"H", "X"}, Qubit: []int{0, 2}},
+		noise.Rule{Channel: cx, Arity: 2, Qubit: []int{0, 1}},
+		noise.Rule{Channel: noise.BitFlip(0.2), Gate: []string{noise.Measure}},
+	)
+
+	cases := []struct {
+		name  string
+		qubit []int
+		want  [][]int
+	}{
+		{"H", []int{0}, [][]int{{0}, {0}}},
+		{"H", []int{1}, [][]int{{1}}},
+		{"Y", []int{2}, [][]int{{2}}},
+		{"ControlledNot", []int{0, 1}, [][]int{{0}, {1}, {0, 1}}},
+		{"ControlledNot", []int{1, 2}, [][]int{{1}, {2}}},
+		{"Controlled", []int{0, 1, 2}, [][]int{{0}, {1}, {2}}},
+		{noise.Measure, []int{1}, [][]int{{1}}},
+	}
+
+	for _, c := range cases {
+		got := m.Gate(c.name, c.qubit...)
+		if len(got) != len(c.want) {
+			t.Errorf("name=%v, qubit=%v, got=%v, want=%v", c.name, c.qubit, got, c.want)
+			continue
+		}
+
+		for i := range got {
+			if fmt.Sprint(got[i].Qubit) != fmt.Sprint(c.want[i]) {
+				t.Errorf("name=%v, qubit=%v, got=%v, want=%v", c.name, c.qubit, got[i].Qubit, c.want[i])
+			}
+		}
+	}
+}
+
+func TestModel_alias(t *testing.T) {
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.BitFlip(0.1), Gate: []string{"CNOT"}, Arity: 2},
+		noise.Rule{Channel: noise.BitFlip(0.1), Gate: []string{"Toffoli"}, Arity: 3},
+		noise.Rule{Channel: noise.PhaseFlip(0.1), Gate: []string{"CZ", "CR"}},
+	)
+
+	cases := []struct {
+		name  string
+		qubit []int
+		want  int
+	}{
+		{"ControlledNot", []int{0, 1}, 2},
+		{"CNOT", []int{0, 1}, 2},
+		{"ControlledNot", []int{0, 1, 2}, 3},
+		{"ControlledZ", []int{0, 1}, 2},
+		{"ControlledR", []int{1, 2}, 2},
+		{"Controlled", []int{0, 1}, 0},
+		{"H", []int{0}, 0},
+	}
+
+	for _, c := range cases {
+		if got := m.Gate(c.name, c.qubit...); len(got) != c.want {
+			t.Errorf("name=%v, got=%v, want=%v", c.name, len(got), c.want)
+		}
+	}
+}
+
+func TestModel_AddPanics(t *testing.T) {
+	cases := []struct {
+		gate []string
+	}{
+		{[]string{"QFT"}},
+		{[]string{"H", "cnot"}},
+		{[]string{"InvQFT"}},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, noise.ErrUnknownGate) {
+					t.Errorf("gate=%v, got=%v, want=%v", c.gate, err, noise.ErrUnknownGate)
+				}
+			}()
+
+			noise.NewModel().Add(noise.Rule{Channel: noise.BitFlip(0.1), Gate: c.gate})
+		}()
+	}
+}
+
+func TestModel_Readout(t *testing.T) {
+	m := noise.NewModel().AddReadout(0.1, 0.2).AddReadout(0.3, 0.4, 1, 2)
+
+	cases := []struct {
+		qubit  int
+		p0, p1 float64
+	}{
+		{0, 0.1, 0.2},
+		{1, 0.3, 0.4},
+		{2, 0.3, 0.4},
+		{3, 0.1, 0.2},
+	}
+
+	for _, c := range cases {
+		p0, p1 := m.Readout(c.qubit)
+		if p0 != c.p0 || p1 != c.p1 {
+			t.Errorf("qubit=%v, got=(%v, %v), want=(%v, %v)", c.qubit, p0, p1, c.p0, c.p1)
+		}
+	}
+
+	if p0, p1 := noise.NewModel().Readout(0); p0 != 0 || p1 != 0 {
+		t.Errorf("got=(%v, %v), want=(0, 0)", p0, p1)
+	}
+}
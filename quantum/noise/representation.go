@@ -0,0 +1,158 @@
+package noise
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/number"
+	"github.com/itsubaki/q/quantum/gate"
+)
+
+// Choi returns the Choi matrix of the channel.
+// It is sum(|i><j| x E(|i><j|)) of dimension (d**2 x d**2), and its trace is d.
+func (c *Channel) Choi() *matrix.Matrix {
+	d := 1 << c.NumQubits()
+
+	choi := matrix.Zero(d*d, d*d)
+	for i := range d {
+		for j := range d {
+			e := matrix.Zero(d, d)
+			e.Set(i, j, 1)
+
+			out := c.apply(e)
+			for a := range d {
+				for b := range d {
+					choi.Set(i*d+a, j*d+b, out.At(a, b))
+				}
+			}
+		}
+	}
+
+	return choi
+}
+
+// Superoperator returns the superoperator (Liouville) matrix of the channel.
+// It is sum(K x conj(K)), and maps the row-major vectorization of rho to that of E(rho).
+func (c *Channel) Superoperator() *matrix.Matrix {
+	var s *matrix.Matrix
+	for _, k := range c.Kraus {
+		t := k.TensorProduct(k.Conjugate())
+		if s == nil {
+			s = t
+			continue
+		}
+
+		s = s.Add(t)
+	}
+
+	return s
+}
+
+// PTM returns the Pauli transfer matrix of the channel.
+// R[i][j] is Tr(P_i * E(P_j)) / d, where P_i is the i-th Pauli string in the order of I, X, Y and Z,
+// and the 0-th qubit is the most significant digit. The elements are real.
+func (c *Channel) PTM() *matrix.Matrix {
+	d := 1 << c.NumQubits()
+	pauli := paulis(c.NumQubits())
+
+	ptm := matrix.Zero(d*d, d*d)
+	for j, pj := range pauli {
+		out := c.apply(pj)
+		for i, pi := range pauli {
+			ptm.Set(i, j, complex(real(pi.MatMul(out).Trace())/float64(d), 0))
+		}
+	}
+
+	return ptm
+}
+
+// FromChoi returns the channel of the Choi matrix.
+// The Kraus operators are the eigenvectors of the Choi matrix scaled by the square root of the eigenvalues,
+// and the eigenvalues less than eps are dropped.
+// It panics if the Choi matrix is not (d**2 x d**2) for a power of two d.
+func FromChoi(choi *matrix.Matrix, eps ...float64) *Channel {
+	rows, cols := choi.Dimension()
+	d := int(math.Sqrt(float64(rows)))
+	if rows != cols || d*d != rows || d < 2 || d&(d-1) != 0 {
+		panic(fmt.Errorf("%w: %dx%d Choi matrix", ErrInvalidDimension, rows, cols))
+	}
+
+	// the Hermitian part cancels the rounding errors
+	h := choi.Add(choi.Dagger()).Mul(0.5)
+	w, v := h.Eigen()
+
+	kraus := make([]*matrix.Matrix, 0)
+	for k := range w {
+		if w[k] < epsilon.E13(eps...) {
+			continue
+		}
+
+		// v[i*d+a][k] is sqrt(w[k]) * K[a][i]
+		s := complex(math.Sqrt(w[k]), 0)
+		op := matrix.Zero(d, d)
+		for i := range d {
+			for a := range d {
+				op.Set(a, i, s*v.At(i*d+a, k))
+			}
+		}
+
+		kraus = append(kraus, op)
+	}
+
+	return NewChannel(kraus...)
+}
+
+// ProcessFidelity returns the process fidelity between the channel and the unitary u.
+// It is sum(|Tr(u^dagger * K)|**2) / d**2, and it is 1 if the channel is u.
+func (c *Channel) ProcessFidelity(u *matrix.Matrix) float64 {
+	rows, _ := u.Dimension()
+	if rows != 1<<c.NumQubits() {
+		panic(fmt.Errorf("%w: %dx%d unitary for %d qubits", ErrInvalidDimension, rows, rows, c.NumQubits()))
+	}
+
+	ud := u.Dagger()
+
+	var sum float64
+	for _, k := range c.Kraus {
+		sum += math.Pow(cmplx.Abs(ud.MatMul(k).Trace()), 2)
+	}
+
+	return sum / float64(rows*rows)
+}
+
+// AverageGateFidelity returns the average gate fidelity between the channel and the unitary u.
+// It is (d * ProcessFidelity + 1) / (d + 1) averaged over the pure input states.
+func (c *Channel) AverageGateFidelity(u *matrix.Matrix) float64 {
+	d := float64(number.Pow(2, c.NumQubits()))
+	return (d*c.ProcessFidelity(u) + 1) / (d + 1)
+}
+
+// apply returns sum(K * rho * K^dagger).
+func (c *Channel) apply(rho *matrix.Matrix) *matrix.Matrix {
+	out := matrix.ZeroLike(rho)
+	for _, k := range c.Kraus {
+		out = out.Add(matrix.MatMul(k, rho, k.Dagger()))
+	}
+
+	return out
+}
+
+// paulis returns the Pauli strings on n qubits in the order of I, X, Y and Z.
+func paulis(n int) []*matrix.Matrix {
+	out := []*matrix.Matrix{matrix.Identity(1)}
+	for range n {
+		next := make([]*matrix.Matrix, 0, len(out)*4)
+		for _, p := range out {
+			for _, q := range []*matrix.Matrix{gate.I(), gate.X(), gate.Y(), gate.Z()} {
+				next = append(next, p.TensorProduct(q))
+			}
+		}
+
+		out = next
+	}
+
+	return out
+}
@@ -0,0 +1,176 @@
+package noise_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/quantum/density"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
+	"github.com/itsubaki/q/quantum/qubit"
+)
+
+func ExampleChannel_PTM() {
+	ptm := noise.Depolarizing(0.3).PTM()
+	for _, r := range ptm.Real() {
+		fmt.Printf("%.2f\n", r)
+	}
+
+	// Output:
+	// [1.00 0.00 0.00 0.00]
+	// [0.00 0.60 0.00 0.00]
+	// [0.00 0.00 0.60 0.00]
+	// [0.00 0.00 0.00 0.60]
+}
+
+func ExampleChannel_Choi() {
+	choi := noise.AmplitudeDamping(0.3).Choi()
+	for _, r := range choi.Real() {
+		fmt.Printf("%.2f\n", r)
+	}
+
+	// Output:
+	// [1.00 0.00 0.00 0.84]
+	// [0.00 0.00 0.00 0.00]
+	// [0.00 0.00 0.30 0.00]
+	// [0.84 0.00 0.00 0.70]
+}
+
+func ExampleChannel_ProcessFidelity() {
+	c := noise.Depolarizing(0.3)
+	fmt.Printf("%.4f\n", c.ProcessFidelity(gate.I()))
+	fmt.Printf("%.4f\n", c.AverageGateFidelity(gate.I()))
+
+	// Output:
+	// 0.7000
+	// 0.8000
+}
+
+func ExampleFromChoi() {
+	choi := density.Choi(1, func(m *density.Matrix) *density.Matrix {
+		return m.AmplitudeDamping(0.3, 0)
+	})
+
+	c := noise.FromChoi(choi)
+	fmt.Println(len(c.Kraus), c.IsCPTP())
+	fmt.Println(c.Choi().Equals(noise.AmplitudeDamping(0.3).Choi()))
+
+	// Output:
+	// 2 true
+	// true
+}
+
+func TestFromChoi(t *testing.T) {
+	cases := []struct {
+		c    *noise.Channel
+		want int
+	}{
+		{noise.NewChannel(gate.H()), 1},
+		{noise.BitFlip(0.2), 2},
+		{noise.Depolarizing(0.3), 4},
+		{noise.PhaseDamping(0.3), 2},
+		{noise.GeneralizedAmplitudeDamping(0.3, 0.7), 4},
+		{noise.ThermalRelaxation(50, 30, 10), 3},
+		{noise.TwoQubitDepolarizing(0.2), 16},
+		{noise.AmplitudeDamping(0.2).TensorProduct(noise.NewChannel(gate.U(0.1, 0.2, 0.3))).Compose(noise.Unitary(0.3, gate.CNOT(2, 0, 1))), 3},
+	}
+
+	for _, c := range cases {
+		got := noise.FromChoi(c.c.Choi())
+		if len(got.Kraus) != c.want {
+			t.Errorf("got=%v, want=%v", len(got.Kraus), c.want)
+		}
+
+		if !got.IsCPTP() {
+			t.Errorf("got=%v", got.Kraus)
+		}
+
+		if !got.Choi().Equals(c.c.Choi()) {
+			t.Errorf("got=%v, want=%v", got.Choi(), c.c.Choi())
+		}
+	}
+}
+
+func TestChannel_Superoperator(t *testing.T) {
+	cases := []struct {
+		c  *noise.Channel
+		qb []density.Qubit
+	}{
+		{noise.AmplitudeDamping(0.3), []density.Qubit{0}},
+		{noise.Depolarizing(0.3).TensorProduct(noise.PhaseDamping(0.2)), []density.Qubit{0, 1}},
+		{noise.TwoQubitDepolarizing(0.3), []density.Qubit{0, 1}},
+	}
+
+	for _, c := range cases {
+		n := c.c.NumQubits()
+		rho := density.NewPureState(qubit.Zero(n).Apply(gate.H(n)).ApplyOn(gate.U(0.3, 0.4, 0.5), 0))
+
+		want := rho.Evolve(c.c, c.qb...).Underlying().Data
+		got := matrix.New(rho.Underlying().Data).MatMul(c.c.Superoperator().Transpose()).Data
+		for i := range want {
+			if math.Abs(real(got[i]-want[i])) > epsilon.E13() || math.Abs(imag(got[i]-want[i])) > epsilon.E13() {
+				t.Errorf("got=%v, want=%v", got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestChannel_PTM(t *testing.T) {
+	cases := []struct {
+		c *noise.Channel
+	}{
+		{noise.NewChannel(gate.H())},
+		{noise.AmplitudeDamping(0.3)},
+		{noise.TwoQubitDepolarizing(0.2)},
+		{noise.NewChannel(gate.CNOT(2, 0, 1))},
+	}
+
+	for _, c := range cases {
+		ptm := c.c.PTM()
+
+		// trace preserving maps the identity to the identity
+		rows, _ := ptm.Dimension()
+		if math.Abs(real(ptm.At(0, 0))-1) > epsilon.E13() {
+			t.Errorf("got=%v", ptm.At(0, 0))
+		}
+
+		for j := 1; j < rows; j++ {
+			if math.Abs(real(ptm.At(0, j))) > epsilon.E13() {
+				t.Errorf("got=%v", ptm.At(0, j))
+			}
+		}
+
+		// imaginary parts are zero
+		for _, r := range ptm.Imag() {
+			for _, v := range r {
+				if math.Abs(v) > epsilon.E13() {
+					t.Errorf("got=%v", ptm.Imag())
+				}
+			}
+		}
+	}
+}
+
+func TestChannel_AverageGateFidelity(t *testing.T) {
+	cases := []struct {
+		c    *noise.Channel
+		u    *matrix.Matrix
+		want float64
+	}{
+		{noise.NewChannel(gate.H()), gate.H(), 1},
+		{noise.NewChannel(gate.X()), gate.Z(), 1.0 / 3},
+		{noise.NewChannel(gate.CNOT(2, 0, 1)), gate.CNOT(2, 0, 1), 1},
+		{noise.Depolarizing(0.3), gate.I(), 0.8},
+		{noise.TwoQubitDepolarizing(0.3), gate.I(2), (4*0.7 + 1) / 5},
+	}
+
+	for _, c := range cases {
+		if got := c.c.AverageGateFidelity(c.u); math.Abs(got-c.want) > epsilon.E13() {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
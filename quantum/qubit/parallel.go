@@ -0,0 +1,30 @@
+package qubit
+
+import "sync"
+
+// MinShard is the minimum number of amplitudes handled by a worker.
+// Smaller state vectors are processed on the calling goroutine.
+var MinShard = 1 << 12
+
+// parallel calls f on contiguous shards of [0, n), one shard per worker.
+// Each index is visited exactly once, so the result does not depend on the number of workers.
+func (q *Qubit) parallel(n int, f func(lo, hi int)) {
+	w := min(q.Workers, n/MinShard)
+	if w < 2 {
+		f(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for k := range w {
+		lo, hi := n*k/w, n*(k+1)/w
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(lo, hi)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -6,17 +6,27 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/internal/index"
+	"github.com/itsubaki/q/internal/trajectory"
 	"github.com/itsubaki/q/math/matrix"
 	"github.com/itsubaki/q/math/number"
 	"github.com/itsubaki/q/math/rand"
 	"github.com/itsubaki/q/math/vector"
 )
 
+var (
+	ErrInvalidDimension = index.ErrInvalidDimension
+	ErrInvalidIndex     = index.ErrInvalidIndex
+	ErrInvalidState     = index.ErrInvalidState
+	ErrNotBijection     = index.ErrNotBijection
+	ErrZeroProbability  = trajectory.ErrZeroProbability
+)
+
 // Qubit is a qubit.
 type Qubit struct {
-	vec  *vector.Vector
-	Rand func() float64 // Random number generator
+	vec     *vector.Vector
+	Rand    func() float64 // Random number generator
+	Workers int            // Number of goroutines used to update the amplitudes
 }
 
 // New returns a new qubit.
@@ -125,8 +135,9 @@ func (q *Qubit) Dimension() int {
 // Clone returns a clone of q.
 func (q *Qubit) Clone() *Qubit {
 	return &Qubit{
-		vec:  q.vec.Clone(),
-		Rand: q.Rand,
+		vec:     q.vec.Clone(),
+		Rand:    q.Rand,
+		Workers: q.Workers,
 	}
 }
 
@@ -176,11 +187,207 @@ func (q *Qubit) Apply(m ...*matrix.Matrix) *Qubit {
 	return q
 }
 
+// ApplyOn applies the (2**k x 2**k) unitary u to the k target qubits.
+// target[0] corresponds to the most significant bit of u.
+func (q *Qubit) ApplyOn(u *matrix.Matrix, target ...int) *Qubit {
+	return q.Controlled(u, nil, target...)
+}
+
+// Controlled applies u to the target qubits if all control qubits are |1>.
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+// It panics if the dimension of u does not match, or the qubits are not distinct.
+func (q *Qubit) Controlled(u *matrix.Matrix, control []int, target ...int) *Qubit {
+	if err := index.Validate(u, q.NumQubits(), control, target); err != nil {
+		panic(err)
+	}
+
+	mask := q.mask(control)
+	q.apply(u, mask, mask, target)
+	return q
+}
+
+// ControlledState applies u to the target qubits if the control qubits are in the given state.
+// state is a binary string such as "10", and state[i] is the state of control[i].
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+func (q *Qubit) ControlledState(u *matrix.Matrix, control []int, state string, target ...int) *Qubit {
+	if err := index.Validate(u, q.NumQubits(), control, target); err != nil {
+		panic(err)
+	}
+
+	if err := index.State(state, control); err != nil {
+		panic(err)
+	}
+
+	n := q.NumQubits()
+
+	var v int
+	for i, c := range control {
+		if state[i] == '1' {
+			v |= 1 << (n - 1 - c)
+		}
+	}
+
+	q.apply(u, q.mask(control), v, target)
+	return q
+}
+
+// ApplyKraus applies one of the Kraus operators to the target qubits as a quantum trajectory.
+// The operator K is chosen with the probability p = |K|psi>|^2 using Rand, and K/sqrt(p) is applied.
+// Averaging over the trajectories reproduces the channel sum(K * rho * K^dagger).
+// It panics with ErrZeroProbability if no operator has nonzero probability.
+func (q *Qubit) ApplyKraus(kraus []*matrix.Matrix, target ...int) *Qubit {
+	for _, k := range kraus {
+		if err := index.Validate(k, q.NumQubits(), nil, target); err != nil {
+			panic(err)
+		}
+	}
+
+	k, p, err := trajectory.Choose(kraus, q.reduced(target), q.Rand())
+	if err != nil {
+		panic(err)
+	}
+
+	q.apply(kraus[k].Mul(complex(1/math.Sqrt(p), 0)), 0, 0, target)
+	return q
+}
+
+// Swap swaps the states of the i-th and j-th qubits.
+func (q *Qubit) Swap(i, j int) *Qubit {
+	if i == j {
+		return q
+	}
+
+	n := q.NumQubits()
+	bi, bj := 1<<(n-1-i), 1<<(n-1-j)
+	q.parallel(len(q.vec.Data), func(lo, hi int) {
+		for k := lo; k < hi; k++ {
+			// visit each pair once, at the index where bit i is 1 and bit j is 0.
+			if k&bi == 0 || k&bj != 0 {
+				continue
+			}
+
+			l := k ^ bi ^ bj
+			q.vec.Data[k], q.vec.Data[l] = q.vec.Data[l], q.vec.Data[k]
+		}
+	})
+
+	return q
+}
+
+// Permute maps the basis state |k> of the target qubits to |f(k)>
+// if all control qubits are |1>.
+// f must be a bijection on [0, 2**len(target)), otherwise it panics with ErrNotBijection.
+func (q *Qubit) Permute(f func(k int) int, control []int, target ...int) *Qubit {
+	if err := index.Bijection(f, len(target)); err != nil {
+		panic(err)
+	}
+
+	n, mask := q.NumQubits(), q.mask(control)
+
+	data := make([]complex128, len(q.vec.Data))
+	q.parallel(len(data), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			a := q.vec.Data[i]
+			if i&mask != mask {
+				data[i] += a
+				continue
+			}
+
+			k := index.Take(n, i, target)
+			data[index.Put(n, i, target, f(k))] += a
+		}
+	})
+
+	q.vec.Data = data
+	return q
+}
+
+// mask returns the bit mask of the given qubits.
+func (q *Qubit) mask(index []int) int {
+	n := q.NumQubits()
+
+	var mask int
+	for _, i := range index {
+		mask |= 1 << (n - 1 - i)
+	}
+
+	return mask
+}
+
+// apply applies u to the target qubits on the basis states i such that i&cmask == cval.
+// It updates the amplitudes in place, 2**k at a time, without building a 2**n x 2**n matrix.
+func (q *Qubit) apply(u *matrix.Matrix, cmask, cval int, target []int) {
+	d := 1 << len(target)
+
+	// offset[r] is the index offset of the basis state |r> of the target qubits.
+	offset := index.Offset(q.NumQubits(), target)
+
+	tmask, amp := offset[d-1], q.vec.Data
+	q.parallel(len(amp), func(lo, hi int) {
+		in := make([]complex128, d)
+		for i := lo; i < hi; i++ {
+			if i&tmask != 0 || i&cmask != cval {
+				continue
+			}
+
+			for r := range d {
+				in[r] = amp[i|offset[r]]
+			}
+
+			for r := range d {
+				var z complex128
+				for c := range d {
+					z += u.At(r, c) * in[c]
+				}
+
+				amp[i|offset[r]] = z
+			}
+		}
+	})
+}
+
+// reduced returns the reduced density matrix of the target qubits.
+// The sum is taken serially in the index order, so the result does not depend on Workers.
+func (q *Qubit) reduced(target []int) *matrix.Matrix {
+	d := 1 << len(target)
+
+	// offset[r] is the index offset of the basis state |r> of the target qubits.
+	offset := index.Offset(q.NumQubits(), target)
+
+	tmask, amp, rho := offset[d-1], q.vec.Data, matrix.Zero(d, d)
+	for i := range amp {
+		if i&tmask != 0 {
+			continue
+		}
+
+		for r := range d {
+			a := amp[i|offset[r]]
+			if a == 0 {
+				continue
+			}
+
+			for c := range d {
+				rho.Data[r*d+c] += a * cmplx.Conj(amp[i|offset[c]])
+			}
+		}
+	}
+
+	return rho
+}
+
 // Normalize returns a normalized qubit.
 func (q *Qubit) Normalize() *Qubit {
 	sum := number.Sum(q.Probability())
-	z := 1 / math.Sqrt(sum)
-	q.vec = q.vec.Mul(complex(z, 0))
+	z := complex(1/math.Sqrt(sum), 0)
+
+	data := make([]complex128, len(q.vec.Data))
+	q.parallel(len(data), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			data[i] = z * q.vec.Data[i]
+		}
+	})
+
+	q.vec.Data = data
 	return q
 }
 
@@ -191,9 +398,30 @@ func (q *Qubit) Amplitude() []complex128 {
 
 // Probability returns the probability of q.
 func (q *Qubit) Probability() []float64 {
-	p := make([]float64, len(q.Amplitude()))
-	for i, a := range q.Amplitude() {
-		p[i] = math.Pow(cmplx.Abs(a), 2)
+	amp := q.Amplitude()
+
+	p := make([]float64, len(amp))
+	q.parallel(len(p), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			p[i] = math.Pow(cmplx.Abs(amp[i]), 2)
+		}
+	})
+
+	return p
+}
+
+// Marginal returns the marginal probability distribution of the qubits at the index.
+// The unlisted qubits are summed out, and index[0] is the most significant bit.
+// If no index is given, it returns the probability of all qubits.
+func (q *Qubit) Marginal(idx ...int) []float64 {
+	if len(idx) < 1 {
+		return q.Probability()
+	}
+
+	n := q.NumQubits()
+	p := make([]float64, 1<<len(idx))
+	for i, v := range q.Probability() {
+		p[index.Take(n, i, idx)] += v
 	}
 
 	return p
@@ -204,34 +432,38 @@ func (q *Qubit) Measure(index int) *Qubit {
 	n := q.NumQubits()
 	mask := 1 << (n - 1 - index)
 
-	zidx, zprop := make([]int, 0), make([]float64, 0)
-	oidx := make([]int, 0)
+	// the probability of zero is summed in index order,
+	// so that it does not depend on the number of workers.
+	var zprop float64
 	for i, p := range q.Probability() {
 		if i&mask == 0 {
-			zidx, zprop = append(zidx, i), append(zprop, p)
-			continue
+			zprop = zprop + p
 		}
-
-		oidx = append(oidx, i)
 	}
 
 	// One()
-	if q.Rand() > number.Sum(zprop) {
-		for _, i := range zidx {
-			q.vec.Data[i] = complex(0, 0)
-		}
-
-		q.Normalize()
+	if q.Rand() > zprop {
+		q.collapse(mask, 0)
 		return One()
 	}
 
 	// Zero()
-	for _, i := range oidx {
-		q.vec.Data[i] = complex(0, 0)
-	}
+	q.collapse(mask, mask)
+	return Zero()
+}
+
+// collapse sets the amplitudes of the basis states i such that i&mask == v to zero,
+// and normalizes the qubit.
+func (q *Qubit) collapse(mask, v int) {
+	q.parallel(len(q.vec.Data), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			if i&mask == v {
+				q.vec.Data[i] = complex(0, 0)
+			}
+		}
+	})
 
 	q.Normalize()
-	return Zero()
 }
 
 // Int measures the quantum state and returns its int representation.
@@ -263,28 +495,28 @@ func (q *Qubit) String() string {
 
 // State returns the state of the qubit at the given index.
 // If no index is provided, it returns the state vector of all qubits.
-func (q *Qubit) State(index ...[]int) []State {
-	if len(index) < 1 {
+func (q *Qubit) State(idx ...[]int) []State {
+	if len(idx) < 1 {
 		n := q.NumQubits()
-		idx := make([]int, n)
+		all := make([]int, n)
 		for i := range n {
-			idx[i] = i
+			all[i] = i
 		}
 
-		index = append(index, idx)
+		idx = append(idx, all)
 	}
 
 	n := q.NumQubits()
 	state := make([]State, 0)
 	for i, a := range q.Amplitude() {
-		amp := round(a)
+		amp := index.Round(a)
 		if amp == 0 {
 			continue
 		}
 
 		var bin []string
-		for _, idx := range index {
-			bin = append(bin, take(n, i, idx))
+		for _, b := range idx {
+			bin = append(bin, index.Binary(n, i, b))
 		}
 
 		state = append(state, NewState(amp, bin...))
@@ -293,34 +525,6 @@ func (q *Qubit) State(index ...[]int) []State {
 	return state
 }
 
-func round(a complex128, eps ...float64) complex128 {
-	e := epsilon.E13(eps...)
-
-	if math.Abs(real(a)) < e {
-		a = complex(0, imag(a))
-	}
-
-	if math.Abs(imag(a)) < e {
-		a = complex(real(a), 0)
-	}
-
-	return a
-}
-
-func take(n, i int, index []int) string {
-	var sb strings.Builder
-	for _, bit := range index {
-		if (i & (1 << (n - 1 - bit))) == 0 {
-			sb.WriteByte('0')
-			continue
-		}
-
-		sb.WriteByte('1')
-	}
-
-	return sb.String()
-}
-
 func TensorProduct(qb ...*Qubit) *Qubit {
 	q := qb[0]
 	for i := 1; i < len(qb); i++ {
@@ -1,6 +1,7 @@
 package qubit_test
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"testing"
@@ -677,3 +678,443 @@ func TestString(t *testing.T) {
 		}
 	}
 }
+
+func ExampleQubit_ApplyOn() {
+	q := qubit.Zero(3)
+	q.ApplyOn(gate.H(), 0)
+	q.ApplyOn(gate.CNOT(2, 0, 1), 0, 2)
+
+	for _, s := range q.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [000][  0]( 0.7071 0.0000i): 0.5000
+	// [101][  5]( 0.7071 0.0000i): 0.5000
+}
+
+func ExampleQubit_Controlled() {
+	q := qubit.Zero(3)
+	q.ApplyOn(gate.H(), 0)
+	q.ApplyOn(gate.H(), 1)
+	q.Controlled(gate.X(), []int{0, 1}, 2)
+
+	for _, s := range q.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [000][  0]( 0.5000 0.0000i): 0.2500
+	// [010][  2]( 0.5000 0.0000i): 0.2500
+	// [100][  4]( 0.5000 0.0000i): 0.2500
+	// [111][  7]( 0.5000 0.0000i): 0.2500
+}
+
+func ExampleQubit_ControlledState() {
+	q := qubit.Zero(3)
+	q.ApplyOn(gate.H(), 0)
+	q.ControlledState(gate.Swap(2, 0, 1), []int{0}, "0", 1, 2)
+	q.ControlledState(gate.X(), []int{0}, "0", 2)
+
+	for _, s := range q.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [001][  1]( 0.7071 0.0000i): 0.5000
+	// [100][  4]( 0.7071 0.0000i): 0.5000
+}
+
+func ExampleQubit_Swap() {
+	q := qubit.NewFrom("100")
+	q.Swap(0, 2)
+
+	for _, s := range q.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [001][  1]( 1.0000 0.0000i): 1.0000
+}
+
+func ExampleQubit_Permute() {
+	// |c>|k> -> |c>|k+1 mod 4>
+	q := qubit.NewFrom("110")
+	q.Permute(func(k int) int { return (k + 1) % 4 }, []int{0}, 1, 2)
+
+	for _, s := range q.State() {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// [111][  7]( 1.0000 0.0000i): 1.0000
+}
+
+func ExampleQubit_Marginal() {
+	q := qubit.Zero(3)
+	q.ApplyOn(gate.H(), 0)
+	q.Controlled(gate.X(), []int{0}, 2)
+
+	fmt.Printf("%.4f\n", q.Marginal(2, 1))
+	fmt.Printf("%.4f\n", q.Marginal(0, 2))
+
+	// Output:
+	// [0.5000 0.0000 0.5000 0.0000]
+	// [0.5000 0.0000 0.0000 0.5000]
+}
+
+func TestApplyOn(t *testing.T) {
+	cases := []struct {
+		u      *matrix.Matrix
+		n      int
+		target []int
+		want   *matrix.Matrix
+	}{
+		{gate.H(), 3, []int{0}, matrix.TensorProduct(gate.H(), gate.I(2))},
+		{gate.Y(), 3, []int{1}, matrix.TensorProduct(gate.I(), gate.Y(), gate.I())},
+		{gate.U(1, 2, 3), 3, []int{2}, matrix.TensorProduct(gate.I(2), gate.U(1, 2, 3))},
+		{gate.CNOT(2, 0, 1), 3, []int{0, 2}, gate.CNOT(3, 0, 2)},
+		{gate.CNOT(2, 0, 1), 3, []int{2, 0}, gate.CNOT(3, 2, 0)},
+		{gate.Swap(2, 0, 1), 4, []int{3, 1}, gate.Swap(4, 1, 3)},
+		{gate.QFT(3), 3, []int{0, 1, 2}, gate.QFT(3)},
+	}
+
+	for _, c := range cases {
+		in := qubit.Zero(c.n).Apply(gate.H(c.n)).Apply(gate.T(c.n))
+		want := in.Clone().Apply(c.want)
+
+		got := in.ApplyOn(c.u, c.target...)
+		if !got.Equals(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestControlled(t *testing.T) {
+	cases := []struct {
+		u       *matrix.Matrix
+		n       int
+		control []int
+		target  int
+		want    *matrix.Matrix
+	}{
+		{gate.X(), 3, []int{0}, 2, gate.CNOT(3, 0, 2)},
+		{gate.X(), 3, []int{2, 0}, 1, gate.CCNOT(3, 2, 0, 1)},
+		{gate.Z(), 3, []int{1}, 0, gate.CZ(3, 1, 0)},
+		{gate.R(0.3), 3, []int{0, 1}, 2, gate.ControlledR(0.3, 3, []int{0, 1}, 2)},
+		{gate.Y(), 2, []int{0}, 1, gate.Controlled(gate.Y(), 2, []int{0}, 1)},
+		{gate.RY(0.3), 3, []int{2}, 0, gate.Controlled(gate.RY(0.3), 3, []int{2}, 0)},
+	}
+
+	for _, c := range cases {
+		in := qubit.Zero(c.n).Apply(gate.H(c.n)).Apply(gate.T(c.n))
+		want := in.Clone().Apply(c.want)
+
+		got := in.Controlled(c.u, c.control, c.target)
+		if !got.Equals(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestControlledState(t *testing.T) {
+	cases := []struct {
+		u       *matrix.Matrix
+		n       int
+		control []int
+		state   string
+		target  []int
+	}{
+		{gate.X(), 3, []int{0}, "0", []int{2}},
+		{gate.X(), 3, []int{2, 0}, "10", []int{1}},
+		{gate.H(), 4, []int{3, 1, 0}, "010", []int{2}},
+		{gate.Swap(2, 0, 1), 4, []int{1, 2}, "01", []int{3, 0}},
+		{gate.QFT(2), 4, []int{0}, "1", []int{1, 3}},
+		{gate.QFT(3), 5, []int{4, 0}, "00", []int{3, 1, 2}},
+	}
+
+	for _, c := range cases {
+		in := qubit.Zero(c.n).Apply(gate.H(c.n)).Apply(gate.T(c.n))
+		in.ApplyOn(gate.RY(0.3), c.target[0])
+		want := in.Clone().Apply(gate.ControlledState(c.u, c.n, c.control, c.state, c.target...))
+
+		got := in.ControlledState(c.u, c.control, c.state, c.target...)
+		if !got.Equals(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestControlledStatePanic(t *testing.T) {
+	cases := []struct {
+		control []int
+		state   string
+	}{
+		{[]int{0}, "01"},
+		{[]int{0, 1}, "1"},
+		{[]int{0}, "2"},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if rec := recover(); rec == nil {
+					t.Errorf("no panic: control=%v, state=%v", c.control, c.state)
+				}
+			}()
+
+			qubit.Zero(3).ControlledState(gate.X(), c.control, c.state, 2)
+		}()
+	}
+}
+
+func TestSwap(t *testing.T) {
+	cases := []struct {
+		n, i, j int
+	}{
+		{2, 0, 1},
+		{3, 0, 2},
+		{3, 2, 0},
+		{4, 1, 3},
+	}
+
+	for _, c := range cases {
+		in := qubit.Zero(c.n).Apply(gate.H(c.n)).Apply(gate.T(c.n))
+		in.ApplyOn(gate.RX(0.7), c.i)
+		want := in.Clone().Apply(gate.Swap(c.n, c.i, c.j))
+
+		got := in.Swap(c.i, c.j)
+		if !got.Equals(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestPermute(t *testing.T) {
+	cases := []struct {
+		a, j, N, c int
+		target     []int
+	}{
+		{7, 0, 15, 0, []int{1, 2, 3, 4}},
+		{7, 1, 15, 0, []int{1, 2, 3, 4}},
+		{2, 2, 21, 1, []int{2, 3, 4, 5, 6}},
+	}
+
+	for _, c := range cases {
+		n := c.target[len(c.target)-1] + 1
+		in := qubit.Zero(n).Apply(gate.H(n))
+		want := in.Clone().Apply(gate.ControlledModExp2(n, c.a, c.j, c.N, c.c, c.target))
+
+		a2jmodN := number.ModExp2(c.a, c.j, c.N)
+		got := in.Permute(func(k int) int {
+			if k > c.N-1 {
+				return k
+			}
+
+			return a2jmodN * k % c.N
+		}, []int{c.c}, c.target...)
+
+		if !got.Equals(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestPermutePanics(t *testing.T) {
+	cases := []struct {
+		f func(k int) int
+	}{
+		{func(k int) int { return 0 }},
+		{func(k int) int { return k + 1 }},
+		{func(k int) int { return -k }},
+		{func(k int) int {
+			// a=6 and N=15 are not coprime
+			if k > 14 {
+				return k
+			}
+
+			return 6 * k % 15
+		}},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, qubit.ErrNotBijection) {
+					t.Errorf("got=%v, want=%v", err, qubit.ErrNotBijection)
+				}
+			}()
+
+			qubit.Zero(5).Permute(c.f, []int{0}, 1, 2, 3, 4)
+		}()
+	}
+}
+
+func BenchmarkApplyOn(b *testing.B) {
+	q := qubit.Zero(16)
+	for range b.N {
+		q.ApplyOn(gate.H(), 8)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	defer func(v int) { qubit.MinShard = v }(qubit.MinShard)
+	qubit.MinShard = 4
+
+	run := func(workers int) *qubit.Qubit {
+		q := qubit.Zero(6)
+		q.Rand = rand.Const(1)
+		q.Workers = workers
+
+		for i := range 6 {
+			q.ApplyOn(gate.H(), i)
+			q.ApplyOn(gate.RX(0.1*float64(i+1)), i)
+		}
+
+		q.Controlled(gate.X(), []int{0, 1}, 5)
+		q.ApplyOn(gate.QFT(3), 4, 1, 2)
+		q.Swap(0, 3)
+		q.Permute(func(k int) int { return (k + 3) % 8 }, []int{2}, 3, 4, 5)
+		for i := range 6 {
+			q.ApplyKraus([]*matrix.Matrix{
+				gate.New([]complex128{1, 0}, []complex128{0, complex(math.Sqrt(0.7), 0)}),
+				gate.New([]complex128{0, complex(math.Sqrt(0.3), 0)}, []complex128{0, 0}),
+			}, i)
+		}
+
+		q.ApplyKraus([]*matrix.Matrix{
+			gate.CNOT(2, 0, 1).Mul(complex(math.Sqrt(0.5), 0)),
+			gate.I(2).Mul(complex(math.Sqrt(0.5), 0)),
+		}, 5, 2)
+		q.Measure(1)
+		q.Measure(4)
+		return q
+	}
+
+	want := run(1)
+	for _, w := range []int{2, 3, 4, 8} {
+		got := run(w)
+		for i, a := range got.Amplitude() {
+			if a != want.Amplitude()[i] {
+				t.Errorf("workers=%d: got=%v, want=%v", w, a, want.Amplitude()[i])
+			}
+		}
+	}
+}
+
+func ExampleQubit_ApplyKraus() {
+	p := 0.1
+	kraus := []*matrix.Matrix{
+		gate.I().Mul(complex(math.Sqrt(1-p), 0)),
+		gate.X().Mul(complex(math.Sqrt(p), 0)),
+	}
+
+	var ones int
+	for i := range 1000 {
+		qb := qubit.Zero()
+		qb.Rand = rand.Const(uint64(i))
+
+		if qb.ApplyKraus(kraus, 0).Probability()[1] > 0.5 {
+			ones++
+		}
+	}
+
+	fmt.Println(ones)
+
+	// Output:
+	// 120
+}
+
+func TestApplyKraus(t *testing.T) {
+	gamma := 0.4
+	kraus := []*matrix.Matrix{
+		gate.New([]complex128{1, 0}, []complex128{0, complex(math.Sqrt(1-gamma), 0)}),
+		gate.New([]complex128{0, complex(math.Sqrt(gamma), 0)}, []complex128{0, 0}),
+	}
+
+	cases := []struct {
+		target int
+	}{
+		{0}, {1}, {2},
+	}
+
+	for _, c := range cases {
+		init := qubit.Zero(3).ApplyOn(gate.H(), 0).Controlled(gate.X(), []int{0}, 1).ApplyOn(gate.RY(2.0), 2)
+		want := init.Marginal(c.target)
+		want[1], want[0] = want[1]*(1-gamma), want[0]+want[1]*gamma
+
+		n := 4000
+		got := make([]float64, 2)
+		for i := range n {
+			qb := init.Clone()
+			qb.Rand = rand.Const(uint64(i))
+
+			p := qb.ApplyKraus(kraus, c.target).Marginal(c.target)
+			if math.Abs(p[0]+p[1]-1) > epsilon.E13() {
+				t.Errorf("target=%v, got=%v", c.target, p)
+			}
+
+			got[0] += p[0] / float64(n)
+			got[1] += p[1] / float64(n)
+		}
+
+		if math.Abs(got[0]-want[0]) > 0.02 {
+			t.Errorf("target=%v, got=%v, want=%v", c.target, got, want)
+		}
+	}
+}
+
+func TestApplyKraus_projector(t *testing.T) {
+	// the projectors onto the basis states of the target qubits
+	kraus := make([]*matrix.Matrix, 4)
+	for i := range kraus {
+		kraus[i] = matrix.Zero(4, 4)
+		kraus[i].Set(i, i, 1)
+	}
+
+	cases := []struct {
+		target []int
+	}{
+		{[]int{0, 1}},
+		{[]int{2, 0}},
+		{[]int{1, 2}},
+	}
+
+	for _, c := range cases {
+		init := qubit.Zero(3).ApplyOn(gate.RY(1.0), 0).ApplyOn(gate.H(), 1).Controlled(gate.RY(2.0), []int{1}, 2)
+		want := init.Marginal(c.target...)
+
+		n := 4000
+		got := make([]float64, 4)
+		for i := range n {
+			qb := init.Clone()
+			qb.Rand = rand.Const(uint64(i))
+
+			p := qb.ApplyKraus(kraus, c.target...).Marginal(c.target...)
+			for j := range p {
+				if math.Abs(p[j]-1) < epsilon.E13() {
+					got[j] += 1 / float64(n)
+				}
+			}
+		}
+
+		for j := range got {
+			if math.Abs(got[j]-want[j]) > 0.03 {
+				t.Errorf("target=%v, got=%v, want=%v", c.target, got, want)
+			}
+		}
+	}
+}
+
+func TestApplyKraus_zeroProbability(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, qubit.ErrZeroProbability) {
+			t.Errorf("got=%v, want=%v", err, qubit.ErrZeroProbability)
+		}
+	}()
+
+	// |1><1| on |0>
+	qubit.Zero().ApplyKraus([]*matrix.Matrix{gate.New([]complex128{0, 0}, []complex128{0, 1})}, 0)
+}
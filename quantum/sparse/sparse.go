@@ -0,0 +1,452 @@
+package sparse
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strings"
+
+	"github.com/itsubaki/q/internal/index"
+	"github.com/itsubaki/q/internal/trajectory"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/qubit"
+)
+
+var (
+	ErrInvalidDimension = index.ErrInvalidDimension
+	ErrInvalidIndex     = index.ErrInvalidIndex
+	ErrInvalidState     = index.ErrInvalidState
+	ErrNotBijection     = index.ErrNotBijection
+	ErrZeroProbability  = trajectory.ErrZeroProbability
+)
+
+// Qubit is a state vector that stores only the nonzero amplitudes keyed by the basis index.
+// The amplitudes whose absolute value is less than Eps are pruned after each gate.
+type Qubit struct {
+	n    int
+	amp  map[int]complex128
+	Rand func() float64 // Random number generator
+	Eps  float64        // Threshold to prune the amplitudes. If zero, epsilon.E13 is used.
+}
+
+// New returns a new qubit.
+// The length of z is a power of two, and z is normalized.
+// If z is empty, it returns the state of zero qubits.
+func New(z ...complex128) *Qubit {
+	q := &Qubit{
+		amp:  make(map[int]complex128),
+		Rand: rand.Float64,
+	}
+
+	if len(z) < 1 {
+		q.amp[0] = 1
+		return q
+	}
+
+	n := int(math.Log2(float64(len(z))))
+	if 1<<n != len(z) {
+		panic(fmt.Errorf("%w: len(z)=%d", ErrInvalidDimension, len(z)))
+	}
+
+	q.n = n
+	for i, a := range z {
+		if a != 0 {
+			q.amp[i] = a
+		}
+	}
+
+	q.Normalize()
+	return q
+}
+
+// Zero returns a qubit in the zero state.
+// n is the number of qubits.
+func Zero(n int) *Qubit {
+	return NewFrom(strings.Repeat("0", n))
+}
+
+// NewFrom returns a qubit in the computational basis state of the binary string.
+func NewFrom(binary string) *Qubit {
+	var i int
+	for _, b := range binary {
+		i <<= 1
+		if b == '1' {
+			i |= 1
+		}
+	}
+
+	return &Qubit{
+		n:    len(binary),
+		amp:  map[int]complex128{i: 1},
+		Rand: rand.Float64,
+	}
+}
+
+// NumQubits returns the number of qubits.
+func (q *Qubit) NumQubits() int {
+	return q.n
+}
+
+// Len returns the number of nonzero amplitudes.
+func (q *Qubit) Len() int {
+	return len(q.amp)
+}
+
+// Clone returns a clone of the qubit.
+func (q *Qubit) Clone() *Qubit {
+	amp := make(map[int]complex128, len(q.amp))
+	for i, a := range q.amp {
+		amp[i] = a
+	}
+
+	return &Qubit{
+		n:    q.n,
+		amp:  amp,
+		Rand: q.Rand,
+		Eps:  q.Eps,
+	}
+}
+
+// TensorProduct returns the tensor product of q and qb.
+func (q *Qubit) TensorProduct(qb *Qubit) *Qubit {
+	amp := make(map[int]complex128, len(q.amp)*len(qb.amp))
+	for i, a := range q.amp {
+		for j, b := range qb.amp {
+			amp[i<<qb.n|j] = a * b
+		}
+	}
+
+	q.n, q.amp = q.n+qb.n, amp
+	return q
+}
+
+// Apply applies the (2**n x 2**n) matrix u to all qubits.
+func (q *Qubit) Apply(u *matrix.Matrix) *Qubit {
+	target := make([]int, q.n)
+	for i := range target {
+		target[i] = i
+	}
+
+	return q.ApplyOn(u, target...)
+}
+
+// ApplyOn applies the (2**k x 2**k) unitary u to the k target qubits.
+// target[0] corresponds to the most significant bit of u.
+func (q *Qubit) ApplyOn(u *matrix.Matrix, target ...int) *Qubit {
+	return q.Controlled(u, nil, target...)
+}
+
+// Controlled applies u to the target qubits if all control qubits are |1>.
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+func (q *Qubit) Controlled(u *matrix.Matrix, control []int, target ...int) *Qubit {
+	if err := index.Validate(u, q.n, control, target); err != nil {
+		panic(err)
+	}
+
+	mask := q.mask(control)
+	q.apply(u, mask, mask, target)
+	return q
+}
+
+// ControlledState applies u to the target qubits if the control qubits are in the given state.
+// state is a binary string such as "10", and state[i] is the state of control[i].
+// u is a (2**k x 2**k) matrix where k is the number of target qubits.
+func (q *Qubit) ControlledState(u *matrix.Matrix, control []int, state string, target ...int) *Qubit {
+	if err := index.Validate(u, q.n, control, target); err != nil {
+		panic(err)
+	}
+
+	if err := index.State(state, control); err != nil {
+		panic(err)
+	}
+
+	var v int
+	for i, c := range control {
+		if state[i] == '1' {
+			v |= 1 << (q.n - 1 - c)
+		}
+	}
+
+	q.apply(u, q.mask(control), v, target)
+	return q
+}
+
+// Swap swaps the states of the i-th and j-th qubits.
+func (q *Qubit) Swap(i, j int) *Qubit {
+	if i == j {
+		return q
+	}
+
+	bi, bj := 1<<(q.n-1-i), 1<<(q.n-1-j)
+	amp := make(map[int]complex128, len(q.amp))
+	for k, a := range q.amp {
+		if (k&bi == 0) != (k&bj == 0) {
+			k ^= bi | bj
+		}
+
+		amp[k] = a
+	}
+
+	q.amp = amp
+	return q
+}
+
+// ApplyKraus applies one of the Kraus operators to the target qubits as a quantum trajectory.
+// The operator K is chosen with the probability p = |K|psi>|^2 using Rand, and K/sqrt(p) is applied.
+// It panics with ErrZeroProbability if no operator has nonzero probability.
+func (q *Qubit) ApplyKraus(kraus []*matrix.Matrix, target ...int) *Qubit {
+	for _, k := range kraus {
+		if err := index.Validate(k, q.n, nil, target); err != nil {
+			panic(err)
+		}
+	}
+
+	k, p, err := trajectory.Choose(kraus, q.reduced(target), q.Rand())
+	if err != nil {
+		panic(err)
+	}
+
+	q.apply(kraus[k].Mul(complex(1/math.Sqrt(p), 0)), 0, 0, target)
+	return q
+}
+
+// Permute maps the basis state |k> of the target qubits to |f(k)>
+// if all control qubits are |1>.
+// f must be a bijection on [0, 2**len(target)), otherwise it panics with ErrNotBijection.
+func (q *Qubit) Permute(f func(k int) int, control []int, target ...int) *Qubit {
+	if err := index.Bijection(f, len(target)); err != nil {
+		panic(err)
+	}
+
+	mask := q.mask(control)
+
+	amp := make(map[int]complex128, len(q.amp))
+	for i, a := range q.amp {
+		if i&mask != mask {
+			amp[i] += a
+			continue
+		}
+
+		amp[index.Put(q.n, i, target, f(index.Take(q.n, i, target)))] += a
+	}
+
+	q.amp = amp
+	return q
+}
+
+// Normalize returns a normalized qubit.
+func (q *Qubit) Normalize() *Qubit {
+	var sum float64
+	for _, i := range q.keys() {
+		sum += math.Pow(cmplx.Abs(q.amp[i]), 2)
+	}
+
+	z := complex(1/math.Sqrt(sum), 0)
+	for i := range q.amp {
+		q.amp[i] *= z
+	}
+
+	return q
+}
+
+// Amplitude returns the amplitudes of all basis states.
+func (q *Qubit) Amplitude() []complex128 {
+	amp := make([]complex128, 1<<q.n)
+	for i, a := range q.amp {
+		amp[i] = a
+	}
+
+	return amp
+}
+
+// Probability returns the probabilities of all basis states.
+func (q *Qubit) Probability() []float64 {
+	p := make([]float64, 1<<q.n)
+	for i, a := range q.amp {
+		p[i] = math.Pow(cmplx.Abs(a), 2)
+	}
+
+	return p
+}
+
+// Marginal returns the marginal probability distribution of the qubits at the index.
+// The unlisted qubits are summed out, and index[0] is the most significant bit.
+// If no index is given, it returns the probability of all qubits.
+func (q *Qubit) Marginal(idx ...int) []float64 {
+	if len(idx) < 1 {
+		return q.Probability()
+	}
+
+	p := make([]float64, 1<<len(idx))
+	for _, i := range q.keys() {
+		p[index.Take(q.n, i, idx)] += math.Pow(cmplx.Abs(q.amp[i]), 2)
+	}
+
+	return p
+}
+
+// Measure returns a measured qubit.
+func (q *Qubit) Measure(index int) *qubit.Qubit {
+	mask := 1 << (q.n - 1 - index)
+
+	// the probability of zero is summed in index order,
+	// so that it does not depend on the iteration order of the map.
+	var zprop float64
+	for _, i := range q.keys() {
+		if i&mask == 0 {
+			zprop += math.Pow(cmplx.Abs(q.amp[i]), 2)
+		}
+	}
+
+	// One()
+	if q.Rand() > zprop {
+		q.collapse(mask, 0)
+		return qubit.One()
+	}
+
+	// Zero()
+	q.collapse(mask, mask)
+	return qubit.Zero()
+}
+
+// State returns the state of the qubit at the given index.
+// If no index is provided, it returns the state of all qubits.
+func (q *Qubit) State(idx ...[]int) []qubit.State {
+	if len(idx) < 1 {
+		all := make([]int, q.n)
+		for i := range q.n {
+			all[i] = i
+		}
+
+		idx = append(idx, all)
+	}
+
+	state := make([]qubit.State, 0, len(q.amp))
+	for _, i := range q.keys() {
+		amp := index.Round(q.amp[i])
+		if amp == 0 {
+			continue
+		}
+
+		var bin []string
+		for _, b := range idx {
+			bin = append(bin, index.Binary(q.n, i, b))
+		}
+
+		state = append(state, qubit.NewState(amp, bin...))
+	}
+
+	return state
+}
+
+// String returns the string representation of q.
+func (q *Qubit) String() string {
+	return fmt.Sprintf("%v", q.Amplitude())
+}
+
+// collapse removes the basis states i such that i&mask == v, and normalizes the qubit.
+func (q *Qubit) collapse(mask, v int) {
+	for i := range q.amp {
+		if i&mask == v {
+			delete(q.amp, i)
+		}
+	}
+
+	q.Normalize()
+}
+
+// apply applies u to the target qubits on the basis states i such that i&cmask == cval.
+// Only the nonzero amplitudes are visited, and the results below Eps are pruned.
+func (q *Qubit) apply(u *matrix.Matrix, cmask, cval int, target []int) {
+	d := 1 << len(target)
+
+	// offset[r] is the index offset of the basis state |r> of the target qubits.
+	offset := index.Offset(q.n, target)
+
+	// group the nonzero amplitudes by the basis state with the target bits cleared.
+	// The groups are independent, so the map is ranged directly without sorting the keys.
+	tmask := offset[d-1]
+	group := make(map[int][]int)
+	for i := range q.amp {
+		if i&cmask != cval {
+			continue
+		}
+
+		base := i &^ tmask
+		group[base] = append(group[base], index.Take(q.n, i, target))
+	}
+
+	eps := epsilon.E13()
+	if q.Eps > 0 {
+		eps = q.Eps
+	}
+
+	for base, cols := range group {
+		// at most 2**k columns are sorted, so that the sum does not depend on the map order.
+		slices.Sort(cols)
+
+		in := make([]complex128, len(cols))
+		for j, c := range cols {
+			in[j] = q.amp[base|offset[c]]
+			delete(q.amp, base|offset[c])
+		}
+
+		for r := range d {
+			var z complex128
+			for j, c := range cols {
+				z += u.At(r, c) * in[j]
+			}
+
+			if cmplx.Abs(z) < eps {
+				continue
+			}
+
+			q.amp[base|offset[r]] = z
+		}
+	}
+}
+
+// reduced returns the reduced density matrix of the target qubits.
+// Only the nonzero amplitudes are visited.
+func (q *Qubit) reduced(target []int) *matrix.Matrix {
+	d := 1 << len(target)
+
+	// offset[r] is the index offset of the basis state |r> of the target qubits.
+	offset := index.Offset(q.n, target)
+
+	tmask, rho := offset[d-1], matrix.Zero(d, d)
+	for _, i := range q.keys() {
+		base, r := i&^tmask, index.Take(q.n, i, target)
+		for c := range d {
+			if a, ok := q.amp[base|offset[c]]; ok {
+				rho.AddAt(r, c, q.amp[i]*cmplx.Conj(a))
+			}
+		}
+	}
+
+	return rho
+}
+
+// keys returns the basis indices of the nonzero amplitudes in ascending order.
+func (q *Qubit) keys() []int {
+	keys := make([]int, 0, len(q.amp))
+	for i := range q.amp {
+		keys = append(keys, i)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
+
+// mask returns the bit mask of the given qubits.
+func (q *Qubit) mask(index []int) int {
+	var mask int
+	for _, i := range index {
+		mask |= 1 << (q.n - 1 - i)
+	}
+
+	return mask
+}
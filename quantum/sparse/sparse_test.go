@@ -0,0 +1,283 @@
+package sparse_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	qrand "github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/qubit"
+	"github.com/itsubaki/q/quantum/sparse"
+)
+
+func ExampleQubit() {
+	q := sparse.Zero(2)
+	q.ApplyOn(gate.H(), 0)
+	q.Controlled(gate.X(), []int{0}, 1)
+
+	for _, s := range q.State() {
+		fmt.Println(s)
+	}
+
+	fmt.Println(q.Len())
+
+	// Output:
+	// [00][  0]( 0.7071 0.0000i): 0.5000
+	// [11][  3]( 0.7071 0.0000i): 0.5000
+	// 2
+}
+
+func ExampleQubit_Len() {
+	// 50 qubits, and 7^x mod 15 keeps only a few nonzero amplitudes
+	q := sparse.Zero(50)
+	for i := range 3 {
+		q.ApplyOn(gate.H(), i)
+	}
+
+	target := []int{46, 47, 48, 49}
+	q.ApplyOn(gate.X(), 49)
+	for i := range 3 {
+		a := []int{7, 4, 1}[i]
+		q.Permute(func(k int) int {
+			if k > 14 {
+				return k
+			}
+
+			return a * k % 15
+		}, []int{2 - i}, target...)
+	}
+
+	fmt.Println(q.Len())
+	p := q.Marginal(target...)
+	fmt.Printf("%.4f %.4f %.4f %.4f\n", p[1], p[4], p[7], p[13])
+
+	// Output:
+	// 8
+	// 0.2500 0.2500 0.2500 0.2500
+}
+
+func ExampleQubit_Measure() {
+	q := sparse.Zero(3)
+	q.Rand = qrand.Const()
+
+	q.ApplyOn(gate.H(), 0)
+	q.Controlled(gate.X(), []int{0}, 2)
+
+	m0 := q.Measure(0)
+	m2 := q.Measure(2)
+	fmt.Println(m0.IsOne() == m2.IsOne(), q.Len())
+
+	// Output:
+	// true 1
+}
+
+func TestQubit(t *testing.T) {
+	type op struct {
+		name   string
+		u      *matrix.Matrix
+		qubits []int
+	}
+
+	random := func(r *rand.Rand, n, depth int) []op {
+		ops := make([]op, depth)
+		for i := range ops {
+			perm := r.Perm(n)
+			switch r.IntN(7) {
+			case 0:
+				ops[i] = op{"ApplyOn", gate.U(r.Float64()*math.Pi, r.Float64()*math.Pi, r.Float64()*math.Pi), perm[:1]}
+			case 1:
+				ops[i] = op{"ApplyOn", gate.H(), perm[:1]}
+			case 2:
+				ops[i] = op{"Controlled", gate.RY(r.Float64() * math.Pi), perm[:2]}
+			case 3:
+				ops[i] = op{"Controlled", gate.X(), perm[:min(3, n)]}
+			case 4:
+				ops[i] = op{"Swap", nil, perm[:2]}
+			case 5:
+				ops[i] = op{"ControlledState", gate.H(), perm[:2]}
+			case 6:
+				ops[i] = op{"Permute", nil, perm[:min(3, n)]}
+			}
+		}
+
+		return ops
+	}
+
+	cases := []struct {
+		n, depth int
+		seed     uint64
+	}{
+		{2, 10, 1},
+		{3, 20, 2},
+		{4, 30, 3},
+		{5, 40, 4},
+		{6, 60, 5},
+	}
+
+	for _, c := range cases {
+		s := sparse.Zero(c.n)
+		qb := qubit.Zero(c.n)
+		for _, o := range random(rand.New(rand.NewPCG(c.seed, 0)), c.n, c.depth) {
+			k := len(o.qubits) - 1
+			switch o.name {
+			case "ApplyOn":
+				s.ApplyOn(o.u, o.qubits...)
+				qb.ApplyOn(o.u, o.qubits...)
+			case "Controlled":
+				s.Controlled(o.u, o.qubits[:k], o.qubits[k])
+				qb.Controlled(o.u, o.qubits[:k], o.qubits[k])
+			case "ControlledState":
+				s.ControlledState(o.u, o.qubits[:1], "0", o.qubits[1])
+				qb.ControlledState(o.u, o.qubits[:1], "0", o.qubits[1])
+			case "Swap":
+				s.Swap(o.qubits[0], o.qubits[1])
+				qb.Swap(o.qubits[0], o.qubits[1])
+			case "Permute":
+				f := func(v int) int { return (v + 1) % (1 << k) }
+				s.Permute(f, o.qubits[:1], o.qubits[1:]...)
+				qb.Permute(f, []int{o.qubits[0]}, o.qubits[1:]...)
+			}
+		}
+
+		got, want := s.Amplitude(), qb.Amplitude()
+		for i := range want {
+			if cmplx.Abs(got[i]-want[i]) > 1e-12 {
+				t.Errorf("n=%v, seed=%v, got=%v, want=%v", c.n, c.seed, got, want)
+				break
+			}
+		}
+
+		mg, mw := s.Marginal(c.n-1, 0), qb.Marginal(c.n-1, 0)
+		for i := range mw {
+			if math.Abs(mg[i]-mw[i]) > epsilon.E13() {
+				t.Errorf("n=%v, seed=%v, got=%v, want=%v", c.n, c.seed, mg, mw)
+				break
+			}
+		}
+	}
+}
+
+func TestApplyKraus(t *testing.T) {
+	gamma := 0.4
+	damping := []*matrix.Matrix{
+		gate.New([]complex128{1, 0}, []complex128{0, complex(math.Sqrt(1-gamma), 0)}),
+		gate.New([]complex128{0, complex(math.Sqrt(gamma), 0)}, []complex128{0, 0}),
+	}
+
+	projector := make([]*matrix.Matrix, 4)
+	for i := range projector {
+		projector[i] = matrix.Zero(4, 4)
+		projector[i].Set(i, i, 1)
+	}
+
+	cases := []struct {
+		kraus  []*matrix.Matrix
+		target []int
+	}{
+		{damping, []int{0}},
+		{damping, []int{2}},
+		{projector, []int{2, 0}},
+		{projector, []int{1, 2}},
+	}
+
+	for _, c := range cases {
+		for seed := range uint64(20) {
+			s := sparse.Zero(3).ApplyOn(gate.RY(1.0), 0).ApplyOn(gate.H(), 1).Controlled(gate.RY(2.0), []int{1}, 2)
+			qb := qubit.Zero(3).ApplyOn(gate.RY(1.0), 0).ApplyOn(gate.H(), 1).Controlled(gate.RY(2.0), []int{1}, 2)
+			s.Rand, qb.Rand = qrand.Const(seed), qrand.Const(seed)
+
+			got, want := s.ApplyKraus(c.kraus, c.target...).Amplitude(), qb.ApplyKraus(c.kraus, c.target...).Amplitude()
+			for i := range want {
+				if cmplx.Abs(got[i]-want[i]) > 1e-12 {
+					t.Errorf("target=%v, seed=%v, got=%v, want=%v", c.target, seed, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestApplyKraus_zeroProbability(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, sparse.ErrZeroProbability) {
+			t.Errorf("got=%v, want=%v", err, sparse.ErrZeroProbability)
+		}
+	}()
+
+	// |1><1| on |0>
+	sparse.Zero(1).ApplyKraus([]*matrix.Matrix{gate.New([]complex128{0, 0}, []complex128{0, 1})}, 0)
+}
+
+func TestQubit_deterministic(t *testing.T) {
+	run := func() []complex128 {
+		q := sparse.Zero(6)
+		for i := range 6 {
+			q.ApplyOn(gate.U(0.1*float64(i+1), 0.2, 0.3), i)
+		}
+
+		for range 5 {
+			q.ApplyOn(gate.QFT(3), 4, 1, 2)
+			q.ApplyOn(gate.QFT(3), 0, 3, 5)
+		}
+
+		return q.Amplitude()
+	}
+
+	want := run()
+	for range 10 {
+		got := run()
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got=%v, want=%v", got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	q := sparse.Zero(1)
+	q.ApplyOn(gate.H(), 0).ApplyOn(gate.H(), 0)
+
+	if q.Len() != 1 {
+		t.Errorf("got=%v, want=1", q.Len())
+	}
+
+	q.Eps = 0.8
+	q.ApplyOn(gate.RY(0.1), 0)
+	if q.Len() != 1 {
+		t.Errorf("got=%v, want=1", q.Len())
+	}
+}
+
+func TestTensorProduct(t *testing.T) {
+	cases := []struct {
+		z0, z1 []complex128
+	}{
+		{nil, []complex128{1, 2}},
+		{[]complex128{1, 2}, []complex128{3, 4}},
+		{[]complex128{1, 0, 0, 1}, []complex128{0, 1i}},
+	}
+
+	for _, c := range cases {
+		got := sparse.New(c.z0...).TensorProduct(sparse.New(c.z1...)).Amplitude()
+
+		want := qubit.New(c.z1...)
+		if len(c.z0) > 0 {
+			want = qubit.New(c.z0...).TensorProduct(want)
+		}
+
+		for i, a := range want.Amplitude() {
+			if cmplx.Abs(got[i]-a) > epsilon.E13() {
+				t.Errorf("got=%v, want=%v", got, want.Amplitude())
+				break
+			}
+		}
+	}
+}
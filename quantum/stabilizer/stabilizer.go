@@ -0,0 +1,644 @@
+package stabilizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"strings"
+
+	"github.com/itsubaki/q/internal/index"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/number"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/qubit"
+)
+
+var (
+	ErrInvalidDimension = index.ErrInvalidDimension
+	ErrInvalidIndex     = index.ErrInvalidIndex
+	ErrNotClifford      = errors.New("not a clifford gate")
+	ErrNotStabilizer    = errors.New("not a stabilizer state")
+)
+
+// Tableau is a stabilizer state represented by the Aaronson-Gottesman tableau.
+// The rows [0, n) are the destabilizers, the rows [n, 2n) are the stabilizers,
+// and the row 2n is a scratch space used by the deterministic measurement.
+// Each row is a Pauli operator (-1)^r X^x Z^z, where x=z=1 denotes Y.
+type Tableau struct {
+	n    int
+	x, z [][]uint64
+	r    []uint8
+	Rand func() float64 // Random number generator
+}
+
+// Zero returns a tableau of n qubits in the zero state.
+func Zero(n int) *Tableau {
+	w := (n + 63) / 64
+	t := &Tableau{
+		n:    n,
+		x:    make([][]uint64, 2*n+1),
+		z:    make([][]uint64, 2*n+1),
+		r:    make([]uint8, 2*n+1),
+		Rand: rand.Float64,
+	}
+
+	for i := range 2*n + 1 {
+		t.x[i] = make([]uint64, w)
+		t.z[i] = make([]uint64, w)
+	}
+
+	for i := range n {
+		t.x[i][i/64] |= 1 << (i % 64)   // destabilizer X_i
+		t.z[i+n][i/64] |= 1 << (i % 64) // stabilizer Z_i
+	}
+
+	return t
+}
+
+// New returns a tableau in the state z.
+// z is a computational basis state of any number of qubits,
+// or one of the single-qubit states |+>, |->, |+i> and |-i> up to the global phase.
+// It panics with ErrNotStabilizer for the other states.
+func New(z ...complex128) *Tableau {
+	n := number.Log2(len(z))
+	if len(z) != 1<<n {
+		panic(fmt.Errorf("%w: %v", ErrInvalidDimension, z))
+	}
+
+	var norm float64
+	for _, a := range z {
+		norm += math.Pow(cmplx.Abs(a), 2)
+	}
+
+	// computational basis state
+	for i, a := range z {
+		if math.Abs(math.Pow(cmplx.Abs(a), 2)/norm-1) < epsilon.E13() {
+			return NewFrom(fmt.Sprintf("%0*b", n, i))
+		}
+	}
+
+	if n == 1 && math.Abs(cmplx.Abs(z[0])-cmplx.Abs(z[1])) < epsilon.E13() {
+		// z[1]/z[0] is 1, -1, i or -i
+		switch r := z[1] / z[0]; {
+		case cmplx.Abs(r-1) < epsilon.E13():
+			return Zero(1).H(0)
+		case cmplx.Abs(r+1) < epsilon.E13():
+			return Zero(1).X(0).H(0)
+		case cmplx.Abs(r-1i) < epsilon.E13():
+			return Zero(1).H(0).S(0)
+		case cmplx.Abs(r+1i) < epsilon.E13():
+			return Zero(1).X(0).H(0).S(0)
+		}
+	}
+
+	panic(fmt.Errorf("%w: %v", ErrNotStabilizer, z))
+}
+
+// NewFrom returns a tableau in the computational basis state of the binary string.
+func NewFrom(binary string) *Tableau {
+	t := Zero(len(binary))
+	for i, b := range binary {
+		if b == '1' {
+			t.X(i)
+		}
+	}
+
+	return t
+}
+
+// NumQubits returns the number of qubits.
+func (t *Tableau) NumQubits() int {
+	return t.n
+}
+
+// Clone returns a clone of the tableau.
+func (t *Tableau) Clone() *Tableau {
+	c := &Tableau{
+		n:    t.n,
+		x:    make([][]uint64, len(t.x)),
+		z:    make([][]uint64, len(t.z)),
+		r:    append([]uint8{}, t.r...),
+		Rand: t.Rand,
+	}
+
+	for i := range t.x {
+		c.x[i] = append([]uint64{}, t.x[i]...)
+		c.z[i] = append([]uint64{}, t.z[i]...)
+	}
+
+	return c
+}
+
+// TensorProduct appends the qubits of u to t.
+// The qubits of u are indexed after the qubits of t.
+func (t *Tableau) TensorProduct(u *Tableau) *Tableau {
+	n := t.n + u.n
+	out := Zero(n)
+	out.Rand = t.Rand
+
+	// the qubits of t are in the same words
+	for i := range t.n {
+		copy(out.x[i], t.x[i])
+		copy(out.z[i], t.z[i])
+		copy(out.x[i+n], t.x[i+t.n])
+		copy(out.z[i+n], t.z[i+t.n])
+		out.r[i], out.r[i+n] = t.r[i], t.r[i+t.n]
+	}
+
+	for i := range u.n {
+		for _, row := range [][2]int{{i, t.n + i}, {i + u.n, n + t.n + i}} {
+			src, dst := row[0], row[1]
+			clear(out.x[dst])
+			clear(out.z[dst])
+			for a := range u.n {
+				w, b := u.bit(a)
+				ow, ob := out.bit(t.n + a)
+				out.x[dst][ow] |= (u.x[src][w] >> b & 1) << ob
+				out.z[dst][ow] |= (u.z[src][w] >> b & 1) << ob
+			}
+
+			out.r[dst] = u.r[src]
+		}
+	}
+
+	*t = *out
+	return t
+}
+
+// X applies the Pauli-X gate to the qubits.
+func (t *Tableau) X(index ...int) *Tableau {
+	for _, a := range index {
+		w, b := t.bit(a)
+		for i := range 2 * t.n {
+			t.r[i] ^= uint8(t.z[i][w] >> b & 1)
+		}
+	}
+
+	return t
+}
+
+// Y applies the Pauli-Y gate to the qubits.
+func (t *Tableau) Y(index ...int) *Tableau {
+	for _, a := range index {
+		w, b := t.bit(a)
+		for i := range 2 * t.n {
+			t.r[i] ^= uint8((t.x[i][w] ^ t.z[i][w]) >> b & 1)
+		}
+	}
+
+	return t
+}
+
+// Z applies the Pauli-Z gate to the qubits.
+func (t *Tableau) Z(index ...int) *Tableau {
+	for _, a := range index {
+		w, b := t.bit(a)
+		for i := range 2 * t.n {
+			t.r[i] ^= uint8(t.x[i][w] >> b & 1)
+		}
+	}
+
+	return t
+}
+
+// H applies the Hadamard gate to the qubits.
+func (t *Tableau) H(index ...int) *Tableau {
+	for _, a := range index {
+		w, b := t.bit(a)
+		for i := range 2 * t.n {
+			x, z := t.x[i][w]>>b&1, t.z[i][w]>>b&1
+			t.r[i] ^= uint8(x & z)
+
+			// swap x and z
+			d := (x ^ z) << b
+			t.x[i][w] ^= d
+			t.z[i][w] ^= d
+		}
+	}
+
+	return t
+}
+
+// S applies the phase gate to the qubits.
+func (t *Tableau) S(index ...int) *Tableau {
+	for _, a := range index {
+		w, b := t.bit(a)
+		for i := range 2 * t.n {
+			x, z := t.x[i][w]>>b&1, t.z[i][w]>>b&1
+			t.r[i] ^= uint8(x & z)
+			t.z[i][w] ^= x << b
+		}
+	}
+
+	return t
+}
+
+// CNOT applies the controlled-not gate.
+func (t *Tableau) CNOT(control, target int) *Tableau {
+	if control == target {
+		panic(fmt.Errorf("%w: control=%d, target=%d", ErrInvalidIndex, control, target))
+	}
+
+	cw, cb := t.bit(control)
+	tw, tb := t.bit(target)
+	for i := range 2 * t.n {
+		xc, zc := t.x[i][cw]>>cb&1, t.z[i][cw]>>cb&1
+		xt, zt := t.x[i][tw]>>tb&1, t.z[i][tw]>>tb&1
+		t.r[i] ^= uint8(xc & zt & (xt ^ zc ^ 1))
+		t.x[i][tw] ^= xc << tb
+		t.z[i][cw] ^= zt << cb
+	}
+
+	return t
+}
+
+// CZ applies the controlled-z gate.
+func (t *Tableau) CZ(control, target int) *Tableau {
+	return t.H(target).CNOT(control, target).H(target)
+}
+
+// Swap swaps the states of the i-th and j-th qubits.
+func (t *Tableau) Swap(i, j int) *Tableau {
+	if i == j {
+		return t
+	}
+
+	iw, ib := t.bit(i)
+	jw, jb := t.bit(j)
+	for k := range 2 * t.n {
+		for _, p := range [][]uint64{t.x[k], t.z[k]} {
+			d := (p[iw]>>ib ^ p[jw]>>jb) & 1
+			p[iw] ^= d << ib
+			p[jw] ^= d << jb
+		}
+	}
+
+	return t
+}
+
+// ApplyOn applies the (2**k x 2**k) unitary u to the k target qubits.
+// target[0] corresponds to the most significant bit of u.
+// It returns ErrNotClifford if u does not map Pauli operators to Pauli operators.
+func (t *Tableau) ApplyOn(u *matrix.Matrix, target ...int) error {
+	if err := index.Validate(u, t.n, nil, target); err != nil {
+		return err
+	}
+
+	table, err := conjugate(u, len(target))
+	if err != nil {
+		return err
+	}
+
+	k := len(target)
+	for i := range 2 * t.n {
+		// the pauli operator of the row restricted to the target qubits
+		var key int
+		for _, a := range target {
+			w, b := t.bit(a)
+			key = key<<2 | int(t.x[i][w]>>b&1)<<1 | int(t.z[i][w]>>b&1)
+		}
+
+		img := table[key]
+		for j, a := range target {
+			w, b := t.bit(a)
+			v := img.pauli >> (2 * (k - 1 - j))
+			t.x[i][w] = t.x[i][w]&^(1<<b) | uint64(v>>1&1)<<b
+			t.z[i][w] = t.z[i][w]&^(1<<b) | uint64(v&1)<<b
+		}
+
+		t.r[i] ^= img.sign
+	}
+
+	return nil
+}
+
+// Measure returns a measured qubit.
+// The outcome is random if a stabilizer anticommutes with Z on the qubit,
+// otherwise it is determined by the stabilizers.
+func (t *Tableau) Measure(index int) *qubit.Qubit {
+	if t.measure(index, func() uint8 {
+		if t.Rand() > 0.5 {
+			return 1
+		}
+
+		return 0
+	}) == 1 {
+		return qubit.One()
+	}
+
+	return qubit.Zero()
+}
+
+// Marginal returns the marginal probability distribution of the qubits at the index.
+// The unlisted qubits are summed out, and index[0] is the most significant bit.
+// If no index is given, it returns the probability of all qubits.
+// Each outcome has the probability 2**(-r) or zero, where r is the number of random measurements,
+// and it branches only on the random measurements of the listed qubits.
+func (t *Tableau) Marginal(index ...int) []float64 {
+	if len(index) < 1 {
+		index = make([]int, t.n)
+		for i := range t.n {
+			index[i] = i
+		}
+	}
+
+	prob := make([]float64, 1<<len(index))
+
+	var walk func(c *Tableau, j, k int, p float64)
+	walk = func(c *Tableau, j, k int, p float64) {
+		if j == len(index) {
+			prob[k] = p
+			return
+		}
+
+		if !c.random(index[j]) {
+			b := c.measure(index[j], nil)
+			walk(c, j+1, k<<1|int(b), p)
+			return
+		}
+
+		for _, b := range []uint8{0, 1} {
+			walk(c.Clone().project(index[j], b), j+1, k<<1|int(b), p/2)
+		}
+	}
+
+	walk(t.Clone(), 0, 0, 1)
+	return prob
+}
+
+// Vector returns the amplitudes of the state.
+// The global phase is not kept by the tableau, and the first nonzero amplitude is a positive real number.
+// It sums S|x> over the 2**n elements S of the stabilizer group for a basis state |x> in the support.
+func (t *Tableau) Vector() []complex128 {
+	n := t.n
+
+	// a basis state in the support
+	var x int
+	m := t.Clone()
+	for i := range n {
+		x = x<<1 | int(m.measure(i, func() uint8 { return 0 }))
+	}
+
+	// the scratch row is the product of the stabilizers in the gray code order
+	c, s := t.Clone(), 2*n
+	clear(c.x[s])
+	clear(c.z[s])
+	c.r[s] = 0
+
+	v := make([]complex128, 1<<n)
+	for g := range 1 << n {
+		if g > 0 {
+			// the bit that changes in the gray code
+			c.rowsum(s, n+bits.TrailingZeros(uint(g)))
+		}
+
+		y, phase := c.act(s, x)
+		v[y] += phase
+	}
+
+	var norm float64
+	var first complex128
+	for _, a := range v {
+		norm += math.Pow(cmplx.Abs(a), 2)
+		if first == 0 && cmplx.Abs(a) > epsilon.E13() {
+			first = a / complex(cmplx.Abs(a), 0)
+		}
+	}
+
+	z := complex(1/math.Sqrt(norm), 0) / first
+	for i := range v {
+		v[i] *= z
+	}
+
+	return v
+}
+
+// act returns |y> and the phase such that the pauli operator of the i-th row maps |x> to phase * |y>.
+// X|b> = |b^1>, Z|b> = (-1)^b |b> and Y|b> = i(-1)^b |b^1>.
+func (t *Tableau) act(i, x int) (int, complex128) {
+	phase := complex(1, 0)
+	if t.r[i] == 1 {
+		phase = -1
+	}
+
+	y := x
+	for a := range t.n {
+		w, b := t.bit(a)
+		xa, za := t.x[i][w]>>b&1, t.z[i][w]>>b&1
+		mask := 1 << (t.n - 1 - a)
+
+		if za == 1 && x&mask != 0 {
+			phase = -phase
+		}
+
+		if xa == 1 {
+			y ^= mask
+		}
+
+		if xa == 1 && za == 1 {
+			phase *= 1i
+		}
+	}
+
+	return y, phase
+}
+
+// random returns true if the outcome of measuring the qubit is random.
+func (t *Tableau) random(index int) bool {
+	w, b := t.bit(index)
+	for i := t.n; i < 2*t.n; i++ {
+		if t.x[i][w]>>b&1 == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// project collapses the qubit to the outcome b.
+// The outcome must be random.
+func (t *Tableau) project(index int, b uint8) *Tableau {
+	t.measure(index, func() uint8 { return b })
+	return t
+}
+
+// measure measures the qubit, and returns the outcome.
+// outcome returns the outcome if it is random, and it is not called if the outcome is determined.
+func (t *Tableau) measure(index int, outcome func() uint8) uint8 {
+	w, b := t.bit(index)
+	n := t.n
+
+	p := -1
+	for i := n; i < 2*n; i++ {
+		if t.x[i][w]>>b&1 == 1 {
+			p = i
+			break
+		}
+	}
+
+	if p < 0 {
+		// deterministic
+		s := 2 * n
+		clear(t.x[s])
+		clear(t.z[s])
+		t.r[s] = 0
+
+		for i := range n {
+			if t.x[i][w]>>b&1 == 1 {
+				t.rowsum(s, i+n)
+			}
+		}
+
+		return t.r[s]
+	}
+
+	// random
+	for i := range 2 * n {
+		if i != p && t.x[i][w]>>b&1 == 1 {
+			t.rowsum(i, p)
+		}
+	}
+
+	copy(t.x[p-n], t.x[p])
+	copy(t.z[p-n], t.z[p])
+	t.r[p-n] = t.r[p]
+
+	clear(t.x[p])
+	clear(t.z[p])
+	t.z[p][w] |= 1 << b
+
+	t.r[p] = outcome()
+	return t.r[p]
+}
+
+// Reset sets the qubits to the zero state.
+func (t *Tableau) Reset(index ...int) *Tableau {
+	for _, a := range index {
+		if t.Measure(a).IsOne() {
+			t.X(a)
+		}
+	}
+
+	return t
+}
+
+// Stabilizers returns the stabilizer generators such as "+XX" and "-ZZ".
+// The i-th character after the sign is the pauli operator on the i-th qubit.
+func (t *Tableau) Stabilizers() []string {
+	out := make([]string, t.n)
+	for i := range t.n {
+		out[i] = t.pauli(i + t.n)
+	}
+
+	return out
+}
+
+// String returns the string representation of the stabilizer generators.
+func (t *Tableau) String() string {
+	return fmt.Sprintf("%v", t.Stabilizers())
+}
+
+// pauli returns the string representation of the i-th row.
+func (t *Tableau) pauli(i int) string {
+	var sb strings.Builder
+	sb.WriteByte("+-"[t.r[i]])
+	for a := range t.n {
+		w, b := t.bit(a)
+		sb.WriteByte("IZXY"[t.x[i][w]>>b&1<<1|t.z[i][w]>>b&1])
+	}
+
+	return sb.String()
+}
+
+// rowsum sets the h-th row to the product of the i-th and h-th rows.
+func (t *Tableau) rowsum(h, i int) {
+	// the exponent of i in the product, see g in Aaronson and Gottesman.
+	var plus, minus int
+	for w := range t.x[h] {
+		x1, z1, x2, z2 := t.x[i][w], t.z[i][w], t.x[h][w], t.z[h][w]
+		plus += bits.OnesCount64(x1&z1&^x2&z2 | x1&^z1&x2&z2 | ^x1&z1&x2&^z2)
+		minus += bits.OnesCount64(x1&z1&x2&^z2 | x1&^z1&^x2&z2 | ^x1&z1&x2&z2)
+	}
+
+	e := 2*int(t.r[h]) + 2*int(t.r[i]) + plus - minus
+	t.r[h] = uint8((e%4 + 4) % 4 / 2)
+
+	for w := range t.x[h] {
+		t.x[h][w] ^= t.x[i][w]
+		t.z[h][w] ^= t.z[i][w]
+	}
+}
+
+// bit returns the word and the bit position of the qubit.
+func (t *Tableau) bit(index int) (int, int) {
+	if index < 0 || index > t.n-1 {
+		panic(fmt.Errorf("%w: %d", ErrInvalidIndex, index))
+	}
+
+	return index / 64, index % 64
+}
+
+// image is the image of a pauli operator under the conjugation by a clifford gate.
+// pauli holds the (x, z) bits of each qubit, the first qubit in the most significant bits.
+type image struct {
+	pauli int
+	sign  uint8
+}
+
+// conjugate returns the image of u P u^dagger for every k-qubit pauli operator P.
+// It returns ErrNotClifford if an image is not a pauli operator up to the sign.
+func conjugate(u *matrix.Matrix, k int) ([]image, error) {
+	table := make([]image, 1<<(2*k))
+	for p := range table {
+		m := u.MatMul(paulis(p, k)).MatMul(u.Dagger())
+
+		found := false
+		for q := range table {
+			// the coefficient of q in m
+			c := m.MatMul(paulis(q, k)).Trace() / complex(float64(int(1)<<k), 0)
+			if math.Abs(real(c))+math.Abs(imag(c)) < epsilon.E13() {
+				continue
+			}
+
+			if math.Abs(imag(c)) > epsilon.E13() || math.Abs(math.Abs(real(c))-1) > epsilon.E13() {
+				return nil, fmt.Errorf("%w: %v", ErrNotClifford, u)
+			}
+
+			table[p], found = image{pauli: q}, true
+			if real(c) < 0 {
+				table[p].sign = 1
+			}
+
+			break
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: %v", ErrNotClifford, u)
+		}
+	}
+
+	return table, nil
+}
+
+// paulis returns the tensor product of the k pauli operators encoded in p.
+func paulis(p, k int) *matrix.Matrix {
+	list := make([]*matrix.Matrix, k)
+	for i := range k {
+		switch p >> (2 * (k - 1 - i)) & 3 {
+		case 0:
+			list[i] = gate.I()
+		case 1:
+			list[i] = gate.Z()
+		case 2:
+			list[i] = gate.X()
+		case 3:
+			list[i] = gate.Y()
+		}
+	}
+
+	return matrix.TensorProduct(list...)
+}
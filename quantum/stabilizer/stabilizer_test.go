@@ -0,0 +1,428 @@
+package stabilizer_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	qrand "github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/qubit"
+	"github.com/itsubaki/q/quantum/stabilizer"
+)
+
+func ExampleTableau() {
+	t := stabilizer.Zero(2)
+	t.H(0).CNOT(0, 1)
+
+	fmt.Println(t)
+
+	// Output:
+	// [+XX +ZZ]
+}
+
+func ExampleTableau_ghz() {
+	n := 1000
+	t := stabilizer.Zero(n)
+	t.Rand = qrand.Const()
+
+	t.H(0)
+	for i := 1; i < n; i++ {
+		t.CNOT(i-1, i)
+	}
+
+	var sb strings.Builder
+	for i := range n {
+		sb.WriteString(t.Measure(i).BinaryString())
+	}
+
+	m := sb.String()
+	fmt.Println(len(m), strings.Count(m, m[:1]))
+
+	// Output:
+	// 1000 1000
+}
+
+func ExampleTableau_teleportation() {
+	t := stabilizer.Zero(3)
+	t.Rand = qrand.Const()
+
+	// |-> on qubit 0
+	t.X(0).H(0)
+
+	t.H(1).CNOT(1, 2)
+	t.CNOT(0, 1).H(0)
+
+	mz := t.Measure(0)
+	mx := t.Measure(1)
+
+	if mx.IsOne() {
+		t.X(2)
+	}
+
+	if mz.IsOne() {
+		t.Z(2)
+	}
+
+	t.H(2)
+	fmt.Println(t.Measure(2).IsOne())
+
+	// Output:
+	// true
+}
+
+func ExampleTableau_ApplyOn() {
+	t := stabilizer.Zero(2)
+
+	if err := t.ApplyOn(gate.H(), 0); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := t.ApplyOn(gate.CNOT(2, 0, 1), 0, 1); err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Println(t)
+
+	if err := t.ApplyOn(gate.T(), 0); errors.Is(err, stabilizer.ErrNotClifford) {
+		fmt.Println(stabilizer.ErrNotClifford)
+	}
+
+	// Output:
+	// [+XX +ZZ]
+	// not a clifford gate
+}
+
+func ExampleTableau_Reset() {
+	t := stabilizer.NewFrom("11")
+	t.H(0).Reset(0, 1)
+
+	fmt.Println(t)
+
+	// Output:
+	// [+ZI +IZ]
+}
+
+func TestTableau(t *testing.T) {
+	type op struct {
+		name   string
+		qubits []int
+	}
+
+	random := func(r *rand.Rand, n, depth int) []op {
+		names := []string{"H", "S", "X", "Y", "Z", "CNOT", "CZ", "Swap"}
+		if n < 2 {
+			names = names[:5]
+		}
+
+		ops := make([]op, depth)
+		for i := range ops {
+			a, b := r.IntN(n), r.IntN(max(n-1, 1))
+			if b >= a {
+				b++
+			}
+
+			ops[i] = op{names[r.IntN(len(names))], []int{a, b}}
+		}
+
+		return ops
+	}
+
+	cases := []struct {
+		n, depth int
+		seed     uint64
+	}{
+		{1, 10, 1},
+		{2, 20, 2},
+		{3, 30, 3},
+		{4, 40, 4},
+		{5, 60, 5},
+		{5, 60, 6},
+	}
+
+	for _, c := range cases {
+		ops := random(rand.New(rand.NewPCG(c.seed, 0)), c.n, c.depth)
+		tab := stabilizer.Zero(c.n)
+		qb := qubit.Zero(c.n)
+		for _, o := range ops {
+			a := o.qubits[0]
+			switch o.name {
+			case "H":
+				tab.H(a)
+				qb.ApplyOn(gate.H(), a)
+			case "S":
+				tab.S(a)
+				qb.ApplyOn(gate.S(), a)
+			case "X":
+				tab.X(a)
+				qb.ApplyOn(gate.X(), a)
+			case "Y":
+				tab.Y(a)
+				qb.ApplyOn(gate.Y(), a)
+			case "Z":
+				tab.Z(a)
+				qb.ApplyOn(gate.Z(), a)
+			case "CNOT":
+				tab.CNOT(a, o.qubits[1])
+				qb.Controlled(gate.X(), []int{a}, o.qubits[1])
+			case "CZ":
+				tab.CZ(a, o.qubits[1])
+				qb.Controlled(gate.Z(), []int{a}, o.qubits[1])
+			case "Swap":
+				tab.Swap(a, o.qubits[1])
+				qb.Swap(a, o.qubits[1])
+			}
+		}
+
+		// every stabilizer generator stabilizes the state
+		for _, s := range tab.Stabilizers() {
+			if got := expectation(qb, s); cmplx.Abs(got-1) > epsilon.E13() {
+				t.Errorf("n=%v, seed=%v, stabilizer=%v, got=%v, want=1", c.n, c.seed, s, got)
+			}
+		}
+
+		// the amplitudes are equal up to the global phase
+		if got := overlap(tab.Vector(), qb.Amplitude()); math.Abs(got-1) > epsilon.E13() {
+			t.Errorf("n=%v, seed=%v, overlap=%v", c.n, c.seed, got)
+		}
+
+		for _, idx := range [][]int{nil, {0}, {c.n - 1, 0}} {
+			got, want := tab.Marginal(idx...), qb.Marginal(idx...)
+			for i := range want {
+				if math.Abs(got[i]-want[i]) > epsilon.E13() {
+					t.Errorf("n=%v, seed=%v, index=%v, got=%v, want=%v", c.n, c.seed, idx, got, want)
+					break
+				}
+			}
+		}
+
+		// the measurement outcome is possible in the state vector
+		for range 10 {
+			m := tab.Clone()
+			m.Rand = qrand.Const(c.seed)
+
+			var k int
+			for i := range c.n {
+				k = k<<1 | int(m.Measure(i).Int())
+			}
+
+			if p := qb.Probability()[k]; p < epsilon.E13() {
+				t.Errorf("n=%v, seed=%v, outcome=%0*b, probability=%v", c.n, c.seed, c.n, k, p)
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		in  []complex128
+		err error
+	}{
+		{[]complex128{1, 0}, nil},
+		{[]complex128{0, -1i}, nil},
+		{[]complex128{0, 0, 0, 0, 0, 2, 0, 0}, nil},
+		{[]complex128{1, 1}, nil},
+		{[]complex128{1i, -1i}, nil},
+		{[]complex128{1, 1i}, nil},
+		{[]complex128{-1, 1i}, nil},
+		{[]complex128{1, 2}, stabilizer.ErrNotStabilizer},
+		{[]complex128{1, 1, 1, 0}, stabilizer.ErrNotStabilizer},
+		{[]complex128{1, 0, 0}, stabilizer.ErrInvalidDimension},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, c.err) {
+					t.Errorf("in=%v, got=%v, want=%v", c.in, err, c.err)
+				}
+			}()
+
+			got := stabilizer.New(c.in...).Vector()
+			if o := overlap(got, qubit.New(c.in...).Amplitude()); math.Abs(o-1) > epsilon.E13() {
+				t.Errorf("in=%v, got=%v", c.in, got)
+			}
+		}()
+	}
+}
+
+func TestTableau_TensorProduct(t *testing.T) {
+	cases := []struct {
+		n, m int
+	}{
+		{0, 2}, {2, 1}, {3, 3}, {63, 2}, {64, 65},
+	}
+
+	for _, c := range cases {
+		// GHZ states on the qubits of each tableau
+		ghz := func(n int) *stabilizer.Tableau {
+			t := stabilizer.Zero(n)
+			if n > 0 {
+				t.H(0).S(0)
+			}
+
+			for i := 1; i < n; i++ {
+				t.CNOT(i-1, i)
+			}
+
+			return t
+		}
+
+		got := ghz(c.n).TensorProduct(ghz(c.m).X(0))
+		want := stabilizer.Zero(c.n + c.m)
+		if c.n > 0 {
+			want.H(0).S(0)
+		}
+
+		for i := 1; i < c.n; i++ {
+			want.CNOT(i-1, i)
+		}
+
+		want.H(c.n).S(c.n)
+		for i := c.n + 1; i < c.n+c.m; i++ {
+			want.CNOT(i-1, i)
+		}
+
+		want.X(c.n)
+
+		if got.NumQubits() != c.n+c.m || got.String() != want.String() {
+			t.Errorf("n=%v, m=%v, got=%v, want=%v", c.n, c.m, got, want)
+		}
+	}
+}
+
+func ExampleTableau_Marginal() {
+	t := stabilizer.Zero(3)
+	t.H(0).CNOT(0, 1).X(2)
+
+	fmt.Println(t.Marginal(0, 1))
+	fmt.Println(t.Marginal(2))
+
+	// Output:
+	// [0.5 0 0 0.5]
+	// [0 1]
+}
+
+func TestApplyOn(t *testing.T) {
+	cases := []struct {
+		u      *matrix.Matrix
+		target []int
+		want   []string
+		err    error
+	}{
+		{gate.H(), []int{0}, []string{"+XI", "+IZ"}, nil},
+		{gate.S().MatMul(gate.H()), []int{1}, []string{"+ZI", "+IY"}, nil},
+		{gate.H().Mul(cmplx.Exp(complex(0, math.Pi/3))), []int{1}, []string{"+ZI", "+IX"}, nil},
+		{gate.CZ(2, 0, 1), []int{0, 1}, []string{"+ZI", "+IZ"}, nil},
+		{gate.Swap(2, 0, 1), []int{1, 0}, []string{"+IZ", "+ZI"}, nil},
+		{gate.X().TensorProduct(gate.H()), []int{0, 1}, []string{"-ZI", "+IX"}, nil},
+		{gate.T(), []int{0}, nil, stabilizer.ErrNotClifford},
+		{gate.RX(0.1), []int{1}, nil, stabilizer.ErrNotClifford},
+		{gate.CS(2, 0, 1), []int{0, 1}, nil, stabilizer.ErrNotClifford},
+		{gate.H(), []int{0, 1}, nil, stabilizer.ErrInvalidDimension},
+		{gate.H(), []int{2}, nil, stabilizer.ErrInvalidIndex},
+		{gate.CZ(2, 0, 1), []int{1, 1}, nil, stabilizer.ErrInvalidIndex},
+	}
+
+	for _, c := range cases {
+		tab := stabilizer.Zero(2)
+		err := tab.ApplyOn(c.u, c.target...)
+		if !errors.Is(err, c.err) {
+			t.Errorf("got=%v, want=%v", err, c.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if got := tab.Stabilizers(); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("got=%v, want=%v", got, c.want)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	cases := []struct {
+		seed uint64
+	}{
+		{1}, {2}, {3}, {4},
+	}
+
+	for _, c := range cases {
+		tab := stabilizer.Zero(3)
+		tab.Rand = qrand.Const(c.seed)
+		tab.H(0).CNOT(0, 1).CNOT(1, 2)
+
+		m0 := tab.Measure(0)
+		for i := 1; i < 3; i++ {
+			if got := tab.Measure(i); got.IsOne() != m0.IsOne() {
+				t.Errorf("seed=%v, got=%v, want=%v", c.seed, got, m0)
+			}
+		}
+
+		// repeated measurement is deterministic
+		if got := tab.Measure(0); got.IsOne() != m0.IsOne() {
+			t.Errorf("seed=%v, got=%v, want=%v", c.seed, got, m0)
+		}
+	}
+}
+
+func BenchmarkGHZ(b *testing.B) {
+	n := 1000
+	for range b.N {
+		t := stabilizer.Zero(n)
+		t.H(0)
+		for i := 1; i < n; i++ {
+			t.CNOT(i-1, i)
+		}
+
+		for i := range n {
+			t.Measure(i)
+		}
+	}
+}
+
+// expectation returns <qb|P|qb> for the signed pauli string P such as "-XZ".
+func expectation(qb *qubit.Qubit, pauli string) complex128 {
+	list := make([]*matrix.Matrix, 0, len(pauli)-1)
+	for _, p := range pauli[1:] {
+		switch p {
+		case 'I':
+			list = append(list, gate.I())
+		case 'X':
+			list = append(list, gate.X())
+		case 'Y':
+			list = append(list, gate.Y())
+		case 'Z':
+			list = append(list, gate.Z())
+		}
+	}
+
+	v := qubit.New(qb.Amplitude()...).Apply(matrix.TensorProduct(list...))
+	e := qb.InnerProduct(v)
+	if pauli[0] == '-' {
+		return -e
+	}
+
+	return e
+}
+
+// overlap returns |<v|w>| for the normalized vectors v and w.
+func overlap(v, w []complex128) float64 {
+	var sum, nv, nw complex128
+	for i := range v {
+		sum += cmplx.Conj(v[i]) * w[i]
+		nv += cmplx.Conj(v[i]) * v[i]
+		nw += cmplx.Conj(w[i]) * w[i]
+	}
+
+	return cmplx.Abs(sum) / math.Sqrt(real(nv)*real(nw))
+}
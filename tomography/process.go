@@ -0,0 +1,155 @@
+package tomography
+
+import (
+	"fmt"
+	"math/cmplx"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/math/matrix"
+)
+
+// ProcessCounts is the counts of the state tomography of the output for each input state.
+// For example, ProcessCounts["0+"] is the counts for the input state |0>|+>.
+type ProcessCounts map[string]Counts
+
+// preparation is the one-qubit input state of the label.
+var preparation = map[rune]string{
+	'0': "Z0",
+	'1': "Z1",
+	'+': "X0",
+	'i': "Y0",
+}
+
+// Inputs returns the 4**k informationally complete input states of k qubits such as "00", "01", "0+", ..., "ii".
+// The one-qubit input states are |0>, |1>, |+> and |+i>.
+func Inputs(k int) []string {
+	return words(k, "01+i")
+}
+
+// Prepare returns the circuit that prepares the input state on the qubits in the zero state.
+// input[i] is the state of qb[i]. X prepares |1>, H prepares |+>, and H and S prepare |+i>.
+func Prepare(input string, qb ...q.Qubit) *circuit.Circuit {
+	if len(input) != len(qb) {
+		panic(fmt.Errorf("%w: %q for %d qubits", ErrInvalidInput, input, len(qb)))
+	}
+
+	c := circuit.New()
+	for i, s := range input {
+		switch s {
+		case '0':
+		case '1':
+			c.X(qb[i])
+		case '+':
+			c.H(qb[i])
+		case 'i':
+			c.H(qb[i]).S(qb[i])
+		default:
+			panic(fmt.Errorf("%w: %q", ErrInvalidInput, input))
+		}
+	}
+
+	return c
+}
+
+// CollectProcess runs the process tomography of the operation on k qubits and returns the counts.
+// For each input state, it prepares the input on k new qubits of newQ(), applies the operation,
+// and collects the counts of the state tomography of the output with shots for each basis.
+// The noise model of the simulator also applies to the gates of Prepare and Circuit.
+func CollectProcess(k, shots int, newQ func() *q.Q, op func(qsim *q.Q, qb []q.Qubit)) ProcessCounts {
+	counts := make(ProcessCounts)
+	for _, in := range Inputs(k) {
+		counts[in] = Collect(k, shots, func() (*q.Q, []q.Qubit) {
+			qsim := newQ()
+			qb := qsim.Zeros(k)
+
+			Prepare(in, qb...).Run(qsim)
+			op(qsim, qb)
+			return qsim, qb
+		})
+	}
+
+	return counts
+}
+
+// Choi returns the Choi matrix reconstructed from the counts by linear inversion.
+// The output of each input state is reconstructed by MLE,
+// and |i><j| is expanded in the input states to get sum(|i><j| x E(|i><j|)).
+// It has the same layout as noise.Channel.Choi, and noise.FromChoi converts it to the Kraus operators.
+func Choi(counts ProcessCounts, eps ...float64) *matrix.Matrix {
+	if len(counts) < 1 {
+		panic(ErrNoCounts)
+	}
+
+	var k int
+	for in := range counts {
+		k = len(in)
+		break
+	}
+
+	d := 1 << k
+	inputs := Inputs(k)
+
+	// a[r][s] is the r-th element of the row-major vectorization of the s-th input state.
+	a := matrix.Zero(d*d, d*d)
+	for s, in := range inputs {
+		rho := matrix.Identity(1)
+		for _, c := range in {
+			rho = rho.TensorProduct(outer(eigenstate[preparation[c]]))
+		}
+
+		for r := range d * d {
+			a.Set(r, s, rho.At(r/d, r%d))
+		}
+	}
+
+	// |i><j| = sum(inv[s][i*d+j] * rho_s)
+	inv := a.Inverse()
+
+	out := make([]*matrix.Matrix, len(inputs))
+	for s, in := range inputs {
+		out[s] = MLE(counts[in], eps...).Underlying()
+	}
+
+	choi := matrix.Zero(d*d, d*d)
+	for i := range d {
+		for j := range d {
+			for s := range inputs {
+				c := inv.At(s, i*d+j)
+				for x := range d {
+					for y := range d {
+						choi.AddAt(i*d+x, j*d+y, c*out[s].At(x, y))
+					}
+				}
+			}
+		}
+	}
+
+	return choi
+}
+
+// ProcessFidelity returns the process fidelity between the Choi matrix and the unitary u.
+// It is <<u|choi|u>> / d**2, where |u>> is sum(|i> x u|i>), and it is 1 if the Choi matrix is of u.
+func ProcessFidelity(choi, u *matrix.Matrix) float64 {
+	d, _ := u.Dimension()
+	if rows, _ := choi.Dimension(); rows != d*d {
+		panic(fmt.Errorf("%w: %dx%d Choi matrix for %dx%d unitary", ErrInvalidDimension, rows, rows, d, d))
+	}
+
+	// |u>>[i*d+a] = u[a][i]
+	v := make([]complex128, d*d)
+	for i := range d {
+		for a := range d {
+			v[i*d+a] = u.At(a, i)
+		}
+	}
+
+	var sum complex128
+	for r := range d * d {
+		for c := range d * d {
+			sum += cmplx.Conj(v[r]) * choi.At(r, c) * v[c]
+		}
+	}
+
+	return real(sum) / float64(d*d)
+}
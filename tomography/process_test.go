@@ -0,0 +1,119 @@
+package tomography_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
+	"github.com/itsubaki/q/tomography"
+)
+
+func ExampleInputs() {
+	fmt.Println(tomography.Inputs(1))
+	fmt.Println(len(tomography.Inputs(2)))
+
+	// Output:
+	// [0 1 + i]
+	// 16
+}
+
+func ExampleChoi() {
+	m := noise.NewModel().Add(noise.Rule{
+		Channel: noise.Depolarizing(0.1),
+		Gate:    []string{"Y"},
+	})
+
+	var seed uint64
+	counts := tomography.CollectProcess(1, 1000, func() *q.Q {
+		seed++
+
+		qsim := q.New(q.WithDensity(), q.WithNoise(m))
+		qsim.Rand = rand.Const(seed)
+		return qsim
+	}, func(qsim *q.Q, qb []q.Qubit) {
+		qsim.Y(qb[0])
+	})
+
+	choi := tomography.Choi(counts)
+	fmt.Printf("%.2f\n", tomography.ProcessFidelity(choi, gate.Y()))
+	fmt.Printf("%.2f\n", noise.NewChannel(gate.Y()).Compose(noise.Depolarizing(0.1)).ProcessFidelity(gate.Y()))
+
+	// Output:
+	// 0.89
+	// 0.90
+}
+
+func TestProcessFidelity(t *testing.T) {
+	cases := []struct {
+		c *noise.Channel
+		u *matrix.Matrix
+	}{
+		{noise.NewChannel(gate.H()), gate.H()},
+		{noise.NewChannel(gate.H()), gate.X()},
+		{noise.Depolarizing(0.3), gate.I()},
+		{noise.AmplitudeDamping(0.3), gate.I()},
+		{noise.NewChannel(gate.CNOT(2, 0, 1)).Compose(noise.TwoQubitDepolarizing(0.2)), gate.CNOT(2, 0, 1)},
+	}
+
+	for _, c := range cases {
+		got := tomography.ProcessFidelity(c.c.Choi(), c.u)
+		want := c.c.ProcessFidelity(c.u)
+		if math.Abs(got-want) > 1e-13 {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
+
+func TestChoi(t *testing.T) {
+	dep := noise.Depolarizing(0.1)
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.AmplitudeDamping(0.2), Gate: []string{"Y"}},
+		noise.Rule{Channel: dep, Gate: []string{"ControlledNot"}},
+	)
+
+	cases := []struct {
+		k    int
+		op   func(qsim *q.Q, qb []q.Qubit)
+		u    *matrix.Matrix
+		want *noise.Channel
+	}{
+		{
+			1,
+			func(qsim *q.Q, qb []q.Qubit) { qsim.Y(qb[0]) },
+			gate.Y(),
+			noise.NewChannel(gate.Y()).Compose(noise.AmplitudeDamping(0.2)),
+		},
+		{
+			2,
+			func(qsim *q.Q, qb []q.Qubit) { qsim.CNOT(qb[0], qb[1]) },
+			gate.CNOT(2, 0, 1),
+			noise.NewChannel(gate.CNOT(2, 0, 1)).Compose(dep.TensorProduct(dep)),
+		},
+	}
+
+	for _, c := range cases {
+		var seed uint64
+		counts := tomography.CollectProcess(c.k, 300, func() *q.Q {
+			seed++
+
+			qsim := q.New(q.WithDensity(), q.WithNoise(m))
+			qsim.Rand = rand.Const(seed)
+			return qsim
+		}, c.op)
+
+		choi := tomography.Choi(counts)
+		if !choi.IsHermite(1e-10) || math.Abs(real(choi.Trace())-float64(int(1)<<c.k)) > 1e-10 {
+			t.Errorf("got=%v", choi)
+		}
+
+		got, want := tomography.ProcessFidelity(choi, c.u), c.want.ProcessFidelity(c.u)
+		if math.Abs(got-want) > 0.03 {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+	}
+}
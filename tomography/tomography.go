@@ -0,0 +1,287 @@
+package tomography
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strings"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/circuit"
+	"github.com/itsubaki/q/math/epsilon"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/quantum/density"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/qubit"
+)
+
+var (
+	ErrInvalidBasis     = errors.New("invalid basis")
+	ErrInvalidInput     = errors.New("invalid input state")
+	ErrInvalidDimension = errors.New("invalid dimension")
+	ErrNoCounts         = errors.New("no counts")
+)
+
+// Iterations is the maximum number of iterations of MLE.
+var Iterations = 10000
+
+// Counts is the histogram of the outcomes for each measurement basis.
+// For example, Counts["XZ"]["01"] is the number of shots in which
+// qb[0] is measured in the X basis with outcome 0, and qb[1] is measured in the Z basis with outcome 1.
+// The outcome 0 corresponds to the eigenvalue +1 of the Pauli.
+type Counts map[string]map[string]int
+
+// Bases returns the 3**k Pauli measurement bases of k qubits such as "XX", "XY", ..., "ZZ".
+func Bases(k int) []string {
+	return words(k, "XYZ")
+}
+
+// Circuit returns the circuit that measures the qubits in the basis.
+// basis[i] is the Pauli of qb[i]. H is applied before measuring X,
+// S^dagger and H are applied before measuring Y, and nothing is applied before measuring Z.
+func Circuit(basis string, qb ...q.Qubit) *circuit.Circuit {
+	if len(basis) != len(qb) {
+		panic(fmt.Errorf("%w: %q for %d qubits", ErrInvalidBasis, basis, len(qb)))
+	}
+
+	c := circuit.New()
+	for i, p := range basis {
+		switch p {
+		case 'X':
+			c.H(qb[i])
+		case 'Y':
+			c.Apply(gate.S().Dagger(), qb[i]).H(qb[i])
+		case 'Z':
+		default:
+			panic(fmt.Errorf("%w: %q", ErrInvalidBasis, basis))
+		}
+	}
+
+	c.Measure(qb...)
+	return c
+}
+
+// Circuits returns the circuits that measure the qubits in each of Bases(len(qb)).
+func Circuits(qb ...q.Qubit) []*circuit.Circuit {
+	bases := Bases(len(qb))
+
+	out := make([]*circuit.Circuit, len(bases))
+	for i, b := range bases {
+		out[i] = Circuit(b, qb...)
+	}
+
+	return out
+}
+
+// Collect runs the circuits of the k qubits shots times for each basis and returns the counts.
+// prepare returns a new quantum computation simulator in the state and the qubits to measure.
+func Collect(k, shots int, prepare func() (*q.Q, []q.Qubit)) Counts {
+	counts := make(Counts)
+	for _, b := range Bases(k) {
+		counts[b] = make(map[string]int)
+		for range shots {
+			qsim, qb := prepare()
+
+			var sb strings.Builder
+			for _, v := range Circuit(b, qb...).Run(qsim) {
+				fmt.Fprintf(&sb, "%d", v)
+			}
+
+			counts[b][sb.String()]++
+		}
+	}
+
+	return counts
+}
+
+// LinearInversion returns the density matrix reconstructed from the counts by linear inversion.
+// It is sum(<P> * P) / 2**k over the Pauli strings P of k qubits,
+// and <P> is estimated from all the bases that measure the non-identity Paulis of P.
+// The density matrix is Hermitian with unit trace, but it may not be positive semidefinite.
+func LinearInversion(counts Counts) *density.Matrix {
+	k := numQubits(counts)
+	d := 1 << k
+
+	rho := matrix.Zero(d, d)
+	for _, s := range words(k, "IXYZ") {
+		rho = rho.Add(pauli(s).Mul(complex(expectation(counts, s), 0)))
+	}
+
+	return density.FromMatrix(rho.Mul(complex(1/float64(d), 0)))
+}
+
+// MLE returns the density matrix reconstructed from the counts by maximum likelihood estimation.
+// It uses the iterative R*rho*R algorithm starting from the maximally mixed state.
+// R*rho*R/Tr(R*rho*R) keeps rho positive semidefinite with unit trace,
+// and the iteration stops when the elements change less than eps or after Iterations.
+func MLE(counts Counts, eps ...float64) *density.Matrix {
+	k := numQubits(counts)
+	d := 1 << k
+
+	// projectors and the frequencies of the outcomes.
+	// The bases are in the order of Bases(k) and the outcomes are sorted, so the sums do not depend on the map order.
+	proj := make([]*matrix.Matrix, 0)
+	freq := make([]float64, 0)
+	for _, b := range Bases(k) {
+		hist := counts[b]
+
+		var total int
+		for _, v := range hist {
+			total += v
+		}
+
+		if total == 0 {
+			continue
+		}
+
+		for _, o := range slices.Sorted(maps.Keys(hist)) {
+			proj = append(proj, projector(b, o))
+			freq = append(freq, float64(hist[o])/float64(total))
+		}
+	}
+
+	rho := matrix.Identity(d).Mul(complex(1/float64(d), 0))
+	for range Iterations {
+		r := matrix.Zero(d, d)
+		for i, p := range proj {
+			prob := real(rho.MatMul(p).Trace())
+			if prob < epsilon.E13() {
+				continue
+			}
+
+			r = r.Add(p.Mul(complex(freq[i]/prob, 0)))
+		}
+
+		next := matrix.MatMul(r, rho, r)
+		next = next.Mul(1 / next.Trace())
+
+		done := next.Equals(rho, epsilon.E13(eps...))
+		rho = next
+		if done {
+			break
+		}
+	}
+
+	return density.FromMatrix(rho)
+}
+
+// Fidelity returns the fidelity <psi|rho|psi> of the density matrix and the pure target state.
+func Fidelity(rho *density.Matrix, target *qubit.Qubit) float64 {
+	return rho.Probability(target)
+}
+
+// expectation returns the expectation value of the Pauli string s estimated from the counts.
+func expectation(counts Counts, s string) float64 {
+	var sum float64
+	var total int
+	for b, hist := range counts {
+		if !compatible(b, s) {
+			continue
+		}
+
+		for o, v := range hist {
+			sign := 1.0
+			for i := range s {
+				if s[i] != 'I' && o[i] == '1' {
+					sign = -sign
+				}
+			}
+
+			sum += sign * float64(v)
+			total += v
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return sum / float64(total)
+}
+
+// compatible returns true if the basis b measures the non-identity Paulis of s.
+func compatible(b, s string) bool {
+	for i := range s {
+		if s[i] != 'I' && s[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// projector returns the projector onto the eigenstate of the outcome o in the basis b.
+func projector(b, o string) *matrix.Matrix {
+	p := matrix.Identity(1)
+	for i := range b {
+		p = p.TensorProduct(outer(eigenstate[b[i:i+1]+o[i:i+1]]))
+	}
+
+	return p
+}
+
+// eigenstate is the eigenstate of the Pauli with the eigenvalue +1 for the outcome 0, and -1 for 1.
+var eigenstate = map[string][]complex128{
+	"Z0": {1, 0},
+	"Z1": {0, 1},
+	"X0": {1 / math.Sqrt2, 1 / math.Sqrt2},
+	"X1": {1 / math.Sqrt2, -1 / math.Sqrt2},
+	"Y0": {1 / math.Sqrt2, 1i / math.Sqrt2},
+	"Y1": {1 / math.Sqrt2, -1i / math.Sqrt2},
+}
+
+// outer returns |v><v| of the one-qubit state v.
+func outer(v []complex128) *matrix.Matrix {
+	return matrix.New(
+		[]complex128{v[0] * cmplx.Conj(v[0]), v[0] * cmplx.Conj(v[1])},
+		[]complex128{v[1] * cmplx.Conj(v[0]), v[1] * cmplx.Conj(v[1])},
+	)
+}
+
+// pauli returns the matrix of the Pauli string s such as "IXZ".
+func pauli(s string) *matrix.Matrix {
+	p := matrix.Identity(1)
+	for _, c := range s {
+		switch c {
+		case 'I':
+			p = p.TensorProduct(gate.I())
+		case 'X':
+			p = p.TensorProduct(gate.X())
+		case 'Y':
+			p = p.TensorProduct(gate.Y())
+		case 'Z':
+			p = p.TensorProduct(gate.Z())
+		}
+	}
+
+	return p
+}
+
+// words returns the strings of length k over the letters in lexicographic order.
+func words(k int, letters string) []string {
+	out := []string{""}
+	for range k {
+		next := make([]string, 0, len(out)*len(letters))
+		for _, s := range out {
+			for _, c := range letters {
+				next = append(next, s+string(c))
+			}
+		}
+
+		out = next
+	}
+
+	return out
+}
+
+// numQubits returns the number of qubits of the counts.
+func numQubits(counts Counts) int {
+	for b := range counts {
+		return len(b)
+	}
+
+	panic(ErrNoCounts)
+}
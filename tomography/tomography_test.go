@@ -0,0 +1,226 @@
+package tomography_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/math/matrix"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/density"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
+	"github.com/itsubaki/q/quantum/qubit"
+	"github.com/itsubaki/q/tomography"
+)
+
+func ExampleBases() {
+	fmt.Println(tomography.Bases(1))
+	fmt.Println(tomography.Bases(2))
+
+	// Output:
+	// [X Y Z]
+	// [XX XY XZ YX YY YZ ZX ZY ZZ]
+}
+
+func ExampleCircuit() {
+	c := tomography.Circuit("XYZ", 0, 1, 2)
+	for _, op := range c.Ops {
+		fmt.Println(op.Name, op.Qubits)
+	}
+
+	// Output:
+	// H [0]
+	// Apply [1]
+	// H [1]
+	// Measure [0 1 2]
+}
+
+func ExampleMLE() {
+	var seed uint64
+	counts := tomography.Collect(2, 1000, func() (*q.Q, []q.Qubit) {
+		seed++
+
+		qsim := q.New()
+		qsim.Rand = rand.Const(seed)
+
+		q0 := qsim.Zero()
+		q1 := qsim.Zero()
+		qsim.H(q0).CNOT(q0, q1)
+
+		return qsim, []q.Qubit{q0, q1}
+	})
+
+	bell := qubit.New(1, 0, 0, 1)
+	rho := tomography.MLE(counts)
+	fmt.Printf("%.2f\n", tomography.Fidelity(rho, bell))
+	fmt.Printf("%.2f\n", rho.Trace())
+
+	// Output:
+	// 1.00
+	// 1.00
+}
+
+func ExampleLinearInversion() {
+	counts := tomography.Counts{
+		"X": {"0": 500, "1": 500},
+		"Y": {"0": 500, "1": 500},
+		"Z": {"0": 1000},
+	}
+
+	rho := tomography.LinearInversion(counts)
+	for _, r := range rho.Underlying().Real() {
+		fmt.Printf("%.2f\n", r)
+	}
+
+	// Output:
+	// [1.00 0.00]
+	// [0.00 0.00]
+}
+
+// exact returns the counts of shots with the exact probabilities of rho.
+func exact(rho *density.Matrix, shots int) tomography.Counts {
+	n := rho.NumQubits()
+	rotate := map[rune]*matrix.Matrix{
+		'X': gate.H(),
+		'Y': gate.H().MatMul(gate.S().Dagger()),
+		'Z': gate.I(),
+	}
+
+	counts := make(tomography.Counts)
+	for _, b := range tomography.Bases(n) {
+		m := rho.Clone()
+		for i, p := range b {
+			m = m.ApplyOn(rotate[p], density.Qubit(i))
+		}
+
+		counts[b] = make(map[string]int)
+		for i, p := range m.Diagonal() {
+			counts[b][fmt.Sprintf("%0*b", n, i)] = int(math.Round(p * float64(shots)))
+		}
+	}
+
+	return counts
+}
+
+func TestLinearInversion(t *testing.T) {
+	cases := []struct {
+		rho *density.Matrix
+	}{
+		{density.NewPureState(qubit.Zero().Apply(gate.U(0.3, 0.4, 0.5)))},
+		{density.NewPureState(qubit.New(1, 0, 0, 1))},
+		{density.NewPureState(qubit.New(1, 0, 0, 1)).Depolarizing(0.2).AmplitudeDamping(0.3, 1)},
+		{density.NewPureState(qubit.New(1, 0, 0, 0, 0, 0, 0, 1).ApplyOn(gate.U(0.1, 0.2, 0.3), 2))},
+	}
+
+	for _, c := range cases {
+		got := tomography.LinearInversion(exact(c.rho, 1000000))
+		if !got.Underlying().Equals(c.rho.Underlying(), 1e-3) {
+			t.Errorf("got=%v, want=%v", got, c.rho)
+		}
+	}
+}
+
+func TestMLE(t *testing.T) {
+	cases := []struct {
+		rho *density.Matrix
+	}{
+		{density.NewPureState(qubit.Zero().Apply(gate.U(0.3, 0.4, 0.5)))},
+		{density.NewPureState(qubit.New(1, 0, 0, 1)).Depolarizing(0.2).AmplitudeDamping(0.3, 1)},
+	}
+
+	for _, c := range cases {
+		got := tomography.MLE(exact(c.rho, 1000000), 1e-10)
+		if !got.Underlying().Equals(c.rho.Underlying(), 1e-2) {
+			t.Errorf("got=%v, want=%v", got, c.rho)
+		}
+
+		if math.Abs(got.Trace()-1) > 1e-10 || !got.IsHermite(1e-10) {
+			t.Errorf("got=%v", got)
+		}
+	}
+}
+
+func TestMLE_deterministic(t *testing.T) {
+	rho := density.NewPureState(qubit.New(1, 0, 0, 1)).Depolarizing(0.2).AmplitudeDamping(0.3, 1)
+	counts := exact(rho, 1000)
+
+	want := tomography.MLE(counts).Underlying()
+	for range 10 {
+		got := tomography.MLE(counts).Underlying()
+		for i := range want.Data {
+			if got.Data[i] != want.Data[i] {
+				t.Errorf("got=%v, want=%v", got.Data[i], want.Data[i])
+			}
+		}
+	}
+}
+
+func TestMLE_noise(t *testing.T) {
+	m := noise.NewModel().Add(noise.Rule{
+		Channel: noise.Depolarizing(0.1),
+		Gate:    []string{"ControlledNot"},
+	})
+
+	var seed uint64
+	counts := tomography.Collect(2, 2000, func() (*q.Q, []q.Qubit) {
+		seed++
+
+		qsim := q.New(q.WithDensity(), q.WithNoise(m))
+		qsim.Rand = rand.Const(seed)
+
+		q0 := qsim.Zero()
+		q1 := qsim.Zero()
+		qsim.H(q0).CNOT(q0, q1)
+
+		return qsim, []q.Qubit{q0, q1}
+	})
+
+	want := density.NewPureState(qubit.New(1, 0, 0, 1)).Depolarizing(0.1)
+	bell := qubit.New(1, 0, 0, 1)
+
+	lin := tomography.LinearInversion(counts)
+	mle := tomography.MLE(counts)
+	for _, got := range []*density.Matrix{lin, mle} {
+		if math.Abs(tomography.Fidelity(got, bell)-tomography.Fidelity(want, bell)) > 0.03 {
+			t.Errorf("got=%v, want=%v", tomography.Fidelity(got, bell), tomography.Fidelity(want, bell))
+		}
+	}
+
+	// MLE is positive semidefinite
+	for _, b := range []*qubit.Qubit{
+		qubit.Zero(2),
+		qubit.New(1, 0, 0, -1),
+		qubit.New(0, 1, 1, 0),
+		qubit.New(0, 1, -1, 0),
+	} {
+		if p := mle.Probability(b); p < -1e-10 {
+			t.Errorf("got=%v", p)
+		}
+	}
+}
+
+func TestCircuit(t *testing.T) {
+	cases := []struct {
+		basis string
+		qb    []q.Qubit
+	}{
+		{"XY", []q.Qubit{0}},
+		{"XA", []q.Qubit{0, 1}},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, ok := recover().(error)
+				if !ok || !errors.Is(err, tomography.ErrInvalidBasis) {
+					t.Errorf("got=%v, want=%v", err, tomography.ErrInvalidBasis)
+				}
+			}()
+
+			tomography.Circuit(c.basis, c.qb...)
+		}()
+	}
+}
@@ -0,0 +1,35 @@
+package q
+
+// Average runs n quantum trajectories, and returns the average of the observable.
+// run returns the simulator of the i-th trajectory,
+// and each trajectory should use its own random numbers such as rand.Const(uint64(i)).
+func Average(n int, run func(i int) *Q, observable func(qsim *Q) []float64) []float64 {
+	var avg []float64
+	for i := range n {
+		v := observable(run(i))
+		if avg == nil {
+			avg = make([]float64, len(v))
+		}
+
+		for j := range v {
+			avg[j] += v[j] / float64(n)
+		}
+	}
+
+	return avg
+}
+
+// Histogram runs n quantum trajectories, and returns the histogram of the outcomes
+// of measuring qubits shots times in each trajectory.
+// The key is the binary string of the outcome, and qb[0] is the most significant bit.
+// If no qubit is given, it samples all qubits.
+func Histogram(n, shots int, run func(i int) *Q, qb ...Qubit) map[string]int {
+	hist := make(map[string]int)
+	for i := range n {
+		for k, v := range run(i).Sample(shots, qb...) {
+			hist[k] += v
+		}
+	}
+
+	return hist
+}
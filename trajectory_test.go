@@ -0,0 +1,88 @@
+package q_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/itsubaki/q"
+	"github.com/itsubaki/q/math/rand"
+	"github.com/itsubaki/q/quantum/gate"
+	"github.com/itsubaki/q/quantum/noise"
+)
+
+func ExampleAverage() {
+	m := noise.NewModel().Add(noise.Rule{
+		Channel: noise.BitFlip(0.1),
+		Gate:    []string{"ControlledNot"},
+	})
+
+	got := q.Average(1000, func(i int) *q.Q {
+		qsim := q.New(q.WithNoise(m))
+		qsim.Rand = rand.Const(uint64(i))
+
+		q0 := qsim.Zero()
+		q1 := qsim.Zero()
+		qsim.H(q0).CNOT(q0, q1)
+
+		return qsim
+	}, (*q.Q).Probability)
+
+	fmt.Printf("%.2f\n", got)
+
+	// Output:
+	// [0.40 0.11 0.11 0.40]
+}
+
+func ExampleHistogram() {
+	m := noise.NewModel().AddReadout(0.1, 0.2)
+
+	hist := q.Histogram(10, 100, func(i int) *q.Q {
+		qsim := q.New(q.WithNoise(m))
+		qsim.Rand = rand.Const(uint64(i))
+
+		q0 := qsim.Zero()
+		q1 := qsim.One()
+		qsim.CNOT(q1, q0)
+
+		return qsim
+	})
+
+	fmt.Println(hist)
+
+	// Output:
+	// map[00:52 01:161 10:151 11:636]
+}
+
+func TestAverage(t *testing.T) {
+	m := noise.NewModel().Add(
+		noise.Rule{Channel: noise.Depolarizing(0.1), Arity: 1},
+		noise.Rule{Channel: noise.Unitary(0.2, gate.CNOT(2, 1, 0)), Gate: []string{"ControlledNot"}},
+	)
+
+	circuit := func(qsim *q.Q) *q.Q {
+		qb := qsim.Zeros(3)
+		qsim.H(qb[0]).RY(0.7, qb[2])
+		qsim.CNOT(qb[0], qb[1]).CNOT(qb[2], qb[1])
+		qsim.ApplyChannel(noise.PhaseFlip(0.3), qb[2])
+		qsim.H(qb[2])
+
+		return qsim
+	}
+
+	z := func(qsim *q.Q) []float64 {
+		p := qsim.Marginal(0)
+		return []float64{p[0] - p[1]}
+	}
+
+	want := z(circuit(q.New(q.WithDensity(), q.WithNoise(m))))
+	got := q.Average(2000, func(i int) *q.Q {
+		qsim := q.New(q.WithNoise(m))
+		qsim.Rand = rand.Const(uint64(i))
+		return circuit(qsim)
+	}, z)
+
+	if math.Abs(got[0]-want[0]) > 0.05 {
+		t.Errorf("got=%v, want=%v", got, want)
+	}
+}